@@ -13,6 +13,8 @@ import (
 
 	"github.com/runtime-metrics-course/internal/agent"
 	"github.com/runtime-metrics-course/internal/logger"
+	"github.com/runtime-metrics-course/internal/models"
+	"github.com/runtime-metrics-course/internal/tlsconfig"
 )
 
 var (
@@ -22,12 +24,23 @@ var (
 )
 
 type AgentConfig struct {
-	Host           string        `json:"address"`
-	SecretKey      string        `json:"key"`
-	CryptoKeyPath  string        `json:"crypto_key"`
-	PollInterval   time.Duration `json:"poll_interval"`
-	ReportInterval time.Duration `json:"report_interval"`
-	RateLimit      int           `json:"rate_limit"`
+	Host           string                           `json:"address"`
+	Transport      string                           `json:"transport"`
+	GRPCAddress    string                           `json:"grpc_address"`
+	SecretKey      string                           `json:"key"`
+	CryptoKeyPath  string                           `json:"crypto_key"`
+	PollInterval   time.Duration                    `json:"poll_interval"`
+	ReportInterval time.Duration                    `json:"report_interval"`
+	RateLimit      int                              `json:"rate_limit"`
+	Labels         map[string]string                `json:"labels"`
+	PauseBuckets   []float64                        `json:"gc_pause_buckets"`
+	TLSCert        string                           `json:"tls_cert"`
+	TLSKey         string                           `json:"tls_key"`
+	TLSCA          string                           `json:"tls_ca"`
+	Collectors     map[string]agent.CollectorConfig `json:"collectors"`
+	OutboxDir      string                           `json:"outbox_dir"`
+	OutboxMaxSize  int64                            `json:"outbox_max_size"`
+	OutboxMaxAge   time.Duration                    `json:"outbox_max_age"`
 }
 
 func printBuildInfo() {
@@ -48,8 +61,18 @@ func main() {
 		log.Fatal(err)
 	}
 
+	tlsCfg := tlsconfig.Config{
+		CertFile: cfg.TLSCert,
+		KeyFile:  cfg.TLSKey,
+		CAFile:   cfg.TLSCA,
+	}
+
 	if !strings.Contains(cfg.Host, "http") {
-		cfg.Host = "http://" + cfg.Host
+		if tlsCfg.Enabled() {
+			cfg.Host = "https://" + cfg.Host
+		} else {
+			cfg.Host = "http://" + cfg.Host
+		}
 	}
 
 	_, err = url.Parse(cfg.Host)
@@ -59,11 +82,20 @@ func main() {
 
 	agentConfig := agent.Config{
 		Host:           cfg.Host,
+		Transport:      cfg.Transport,
+		GRPCAddress:    cfg.GRPCAddress,
 		SecretKey:      cfg.SecretKey,
 		CryptoKeyPath:  cfg.CryptoKeyPath,
 		PollInterval:   cfg.PollInterval,
 		ReportInterval: cfg.ReportInterval,
 		RateLimit:      cfg.RateLimit,
+		Labels:         cfg.Labels,
+		PauseBuckets:   cfg.PauseBuckets,
+		TLS:            tlsCfg,
+		Collectors:     cfg.Collectors,
+		OutboxDir:      cfg.OutboxDir,
+		OutboxMaxSize:  cfg.OutboxMaxSize,
+		OutboxMaxAge:   cfg.OutboxMaxAge,
 	}
 
 	if err := agent.StartAgent(agentConfig); err != nil {
@@ -78,6 +110,8 @@ func LoadConfig() (*AgentConfig, error) {
 
 	cfg := &AgentConfig{
 		Host:           "localhost:8080",
+		Transport:      agent.TransportHTTP,
+		GRPCAddress:    "localhost:3200",
 		PollInterval:   2 * time.Second,
 		ReportInterval: 10 * time.Second,
 		RateLimit:      10,
@@ -103,6 +137,12 @@ func LoadConfig() (*AgentConfig, error) {
 		if fileCfg.Host != "" {
 			cfg.Host = fileCfg.Host
 		}
+		if fileCfg.Transport != "" {
+			cfg.Transport = fileCfg.Transport
+		}
+		if fileCfg.GRPCAddress != "" {
+			cfg.GRPCAddress = fileCfg.GRPCAddress
+		}
 		if fileCfg.PollInterval != 0 {
 			cfg.PollInterval = fileCfg.PollInterval
 		}
@@ -118,27 +158,112 @@ func LoadConfig() (*AgentConfig, error) {
 		if fileCfg.RateLimit != 0 {
 			cfg.RateLimit = fileCfg.RateLimit
 		}
+		if len(fileCfg.Labels) != 0 {
+			cfg.Labels = fileCfg.Labels
+		}
+		if len(fileCfg.PauseBuckets) != 0 {
+			cfg.PauseBuckets = fileCfg.PauseBuckets
+		}
+		if fileCfg.TLSCert != "" {
+			cfg.TLSCert = fileCfg.TLSCert
+		}
+		if fileCfg.TLSKey != "" {
+			cfg.TLSKey = fileCfg.TLSKey
+		}
+		if fileCfg.TLSCA != "" {
+			cfg.TLSCA = fileCfg.TLSCA
+		}
+		if len(fileCfg.Collectors) != 0 {
+			cfg.Collectors = fileCfg.Collectors
+		}
+		if fileCfg.OutboxDir != "" {
+			cfg.OutboxDir = fileCfg.OutboxDir
+		}
+		if fileCfg.OutboxMaxSize != 0 {
+			cfg.OutboxMaxSize = fileCfg.OutboxMaxSize
+		}
+		if fileCfg.OutboxMaxAge != 0 {
+			cfg.OutboxMaxAge = fileCfg.OutboxMaxAge
+		}
 	}
 
+	var labels, collectors string
+
 	flag.StringVar(&configFile, "c", "", "Path to config file")
 	flag.StringVar(&configFile, "config", "", "Path to config file")
 	flag.StringVar(&cfg.Host, "a", cfg.Host, "server config host:port")
+	flag.StringVar(&cfg.Transport, "transport", cfg.Transport, "transport to report metrics: http or grpc")
+	flag.StringVar(&cfg.GRPCAddress, "grpc-address", cfg.GRPCAddress, "gRPC server host:port")
 	flag.StringVar(&cfg.SecretKey, "k", cfg.SecretKey, "encrypt key")
 	flag.StringVar(&cfg.CryptoKeyPath, "crypto-key", cfg.CryptoKeyPath, "путь к файлу с публичным ключем")
+	flag.StringVar(&cfg.TLSCert, "tls-cert", cfg.TLSCert, "client TLS certificate (PEM) for mutual TLS")
+	flag.StringVar(&cfg.TLSKey, "tls-key", cfg.TLSKey, "client TLS private key (PEM)")
+	flag.StringVar(&cfg.TLSCA, "tls-ca", cfg.TLSCA, "CA bundle (PEM) to verify the server certificate")
+	flag.StringVar(&cfg.OutboxDir, "outbox-dir", cfg.OutboxDir, "directory to queue undelivered metrics in (disabled if empty)")
+	flag.Int64Var(&cfg.OutboxMaxSize, "outbox-max-size", cfg.OutboxMaxSize, "maximum outbox size in bytes")
+	flag.DurationVar(&cfg.OutboxMaxAge, "outbox-max-age", cfg.OutboxMaxAge, "maximum age of queued metrics")
 	flag.DurationVar(&cfg.PollInterval, "p", cfg.PollInterval, "poll interval")
 	flag.DurationVar(&cfg.ReportInterval, "r", cfg.ReportInterval, "report interval")
 	flag.IntVar(&cfg.RateLimit, "l", cfg.RateLimit, "rate limit")
+	flag.StringVar(&labels, "labels", "", "labels attached to every metric (host=web-1,dc=eu)")
+	flag.StringVar(&collectors, "collectors", "", "comma separated collectors to run, the others are disabled ("+strings.Join(agent.Collectors(), ",")+")")
 	flag.Parse()
 
+	if envLabels := os.Getenv("LABELS"); envLabels != "" {
+		labels = envLabels
+	}
+	if labels != "" {
+		parsed, err := parseLabels(labels)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Labels = parsed
+	}
+
+	if envCollectors := os.Getenv("COLLECTORS"); envCollectors != "" {
+		collectors = envCollectors
+	}
+	if collectors != "" {
+		cfg.Collectors = enableCollectors(cfg.Collectors, splitList(collectors))
+	}
+
 	if envHost := os.Getenv("ADDRESS"); envHost != "" {
 		cfg.Host = envHost
 	}
+	if envTransport := os.Getenv("TRANSPORT"); envTransport != "" {
+		cfg.Transport = envTransport
+	}
+	if envGRPC := os.Getenv("GRPC_ADDRESS"); envGRPC != "" {
+		cfg.GRPCAddress = envGRPC
+	}
 	if envKey := os.Getenv("KEY"); envKey != "" {
 		cfg.SecretKey = envKey
 	}
 	if envCryptoKey := os.Getenv("CRYPTO_KEY"); envCryptoKey != "" {
 		cfg.CryptoKeyPath = envCryptoKey
 	}
+	if envCert := os.Getenv("TLS_CERT"); envCert != "" {
+		cfg.TLSCert = envCert
+	}
+	if envTLSKey := os.Getenv("TLS_KEY"); envTLSKey != "" {
+		cfg.TLSKey = envTLSKey
+	}
+	if envCA := os.Getenv("TLS_CA"); envCA != "" {
+		cfg.TLSCA = envCA
+	}
+	if envOutbox := os.Getenv("OUTBOX_DIR"); envOutbox != "" {
+		cfg.OutboxDir = envOutbox
+	}
+	if envOutboxSize := os.Getenv("OUTBOX_MAX_SIZE"); envOutboxSize != "" {
+		if val, err := strconv.ParseInt(envOutboxSize, 10, 64); err == nil {
+			cfg.OutboxMaxSize = val
+		}
+	}
+	if envOutboxAge := os.Getenv("OUTBOX_MAX_AGE"); envOutboxAge != "" {
+		if dur, err := time.ParseDuration(envOutboxAge); err == nil {
+			cfg.OutboxMaxAge = dur
+		}
+	}
 	if envPollInt := os.Getenv("POLL_INTERVAL"); envPollInt != "" {
 		if dur, err := time.ParseDuration(envPollInt); err == nil {
 			cfg.PollInterval = dur
@@ -155,5 +280,66 @@ func LoadConfig() (*AgentConfig, error) {
 		}
 	}
 
+	if cfg.Transport != agent.TransportHTTP && cfg.Transport != agent.TransportGRPC {
+		return nil, fmt.Errorf("unknown transport %q, expected %s or %s", cfg.Transport, agent.TransportHTTP, agent.TransportGRPC)
+	}
+	if err := agent.ValidatePauseBuckets(cfg.PauseBuckets); err != nil {
+		return nil, err
+	}
+	// Labels are added to every series key, so names the server rejects
+	// would make every report fail
+	if err := models.ValidateLabels("labels", cfg.Labels); err != nil {
+		return nil, err
+	}
+
 	return cfg, nil
 }
+
+// parseLabels parses a comma separated list of name=value pairs
+func parseLabels(s string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid label %q, expected name=value", pair)
+		}
+		labels[name] = value
+	}
+	return labels, nil
+}
+
+// enableCollectors enables exactly the named collectors, keeping the
+// configured options of every collector
+func enableCollectors(config map[string]agent.CollectorConfig, names []string) map[string]agent.CollectorConfig {
+	enabled := make(map[string]bool, len(names))
+	for _, name := range names {
+		enabled[name] = true
+	}
+
+	result := make(map[string]agent.CollectorConfig)
+	for _, name := range agent.Collectors() {
+		conf := config[name]
+		on := enabled[name]
+		conf.Enabled = &on
+		result[name] = conf
+	}
+	// Unknown names are kept, so that the agent reports them
+	for name := range enabled {
+		if _, ok := result[name]; !ok {
+			on := true
+			result[name] = agent.CollectorConfig{Enabled: &on}
+		}
+	}
+	return result
+}
+
+// splitList splits a comma separated list, dropping empty items
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"flag"
@@ -9,6 +10,7 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -16,9 +18,13 @@ import (
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/pressly/goose"
+	"github.com/runtime-metrics-course/internal/alerting"
+	"github.com/runtime-metrics-course/internal/forward"
 	"github.com/runtime-metrics-course/internal/logger"
 	"github.com/runtime-metrics-course/internal/server"
+	"github.com/runtime-metrics-course/internal/statsd"
 	"github.com/runtime-metrics-course/internal/storage"
+	"github.com/runtime-metrics-course/internal/tlsconfig"
 )
 
 var (
@@ -31,10 +37,35 @@ type ServerConfig struct {
 	Address       string        `json:"address"`
 	SecretKey     string        `json:"key"`
 	CryptoKey     string        `json:"crypto_key"`
+	TrustedSubnet string        `json:"trusted_subnet"`
 	StoreInterval time.Duration `json:"store_interval"`
 	FilePath      string        `json:"store_file"`
 	Restore       bool          `json:"restore"`
 	DatabaseDSN   string        `json:"database_dsn"`
+
+	TLSCert          string `json:"tls_cert"`
+	TLSKey           string `json:"tls_key"`
+	TLSCA            string `json:"tls_ca"`
+	TLSRequireClient bool   `json:"tls_require_client_cert"`
+
+	History          bool          `json:"history"`
+	HistoryRetention time.Duration `json:"history_retention"`
+
+	GRPCAddress string `json:"grpc_address"`
+
+	AlertRules      string        `json:"alert_rules"`
+	AlertInterval   time.Duration `json:"alert_interval"`
+	AlertWebhooks   []string      `json:"alert_webhooks"`
+	AlertWebhookKey string        `json:"alert_webhook_key"`
+
+	StatsDAddress       string        `json:"statsd_address"`
+	StatsDFlushInterval time.Duration `json:"statsd_flush_interval"`
+
+	ForwardURL       string        `json:"forward_url"`
+	ForwardSource    string        `json:"forward_source"`
+	ForwardKey       string        `json:"forward_key"`
+	ForwardCryptoKey string        `json:"forward_crypto_key"`
+	ForwardInterval  time.Duration `json:"forward_interval"`
 }
 
 func printBuildInfo() {
@@ -69,6 +100,9 @@ func main() {
 		FilePath: cfg.FilePath,
 		Restore:  cfg.Restore,
 		Conn:     conn,
+
+		History:          cfg.History,
+		HistoryRetention: cfg.HistoryRetention,
 	}
 
 	sm, err := storage.NewStorageManager(storageCfg)
@@ -78,7 +112,81 @@ func main() {
 
 	sm.SaverRun()
 
-	if err := server.InitServer(cfg.Address, cfg.SecretKey, cfg.CryptoKey); err != nil {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if cfg.ForwardURL != "" {
+		forwarder, err := forward.New(forward.Config{
+			URL:       cfg.ForwardURL,
+			Source:    cfg.ForwardSource,
+			SecretKey: cfg.ForwardKey,
+			CryptoKey: cfg.ForwardCryptoKey,
+			Interval:  cfg.ForwardInterval,
+		})
+		if err != nil {
+			logger.Log.Fatal(err.Error())
+		}
+		sm.Wrap(forwarder.Wrap)
+		go forwarder.Run(ctx)
+	}
+
+	serverCfg := server.Config{
+		Address:       cfg.Address,
+		SecretKey:     cfg.SecretKey,
+		CryptoKeyPath: cfg.CryptoKey,
+		TrustedSubnet: cfg.TrustedSubnet,
+		GRPCAddress:   cfg.GRPCAddress,
+		TLS: tlsconfig.Config{
+			CertFile:          cfg.TLSCert,
+			KeyFile:           cfg.TLSKey,
+			CAFile:            cfg.TLSCA,
+			RequireClientCert: cfg.TLSRequireClient,
+		},
+	}
+
+	if cfg.AlertRules != "" {
+		rules, err := alerting.LoadRules(cfg.AlertRules)
+		if err != nil {
+			logger.Log.Fatal(err.Error())
+		}
+		st, err := sm.GetStorage()
+		if err != nil {
+			logger.Log.Fatal(err.Error())
+		}
+		serverCfg.Alerts = alerting.NewEngine(rules, st, cfg.AlertInterval)
+		if len(cfg.AlertWebhooks) != 0 {
+			notifier := alerting.NewWebhookNotifier(cfg.AlertWebhooks, cfg.AlertWebhookKey)
+			serverCfg.Alerts.SetNotifier(notifier)
+			go notifier.Run(ctx)
+		}
+		go serverCfg.Alerts.Run(ctx)
+	}
+
+	if cfg.StatsDAddress != "" {
+		st, err := sm.GetStorage()
+		if err != nil {
+			logger.Log.Fatal(err.Error())
+		}
+		statsdServer := statsd.NewServer(statsd.Config{
+			Address:       cfg.StatsDAddress,
+			FlushInterval: cfg.StatsDFlushInterval,
+		}, st)
+		go func() {
+			if err := statsdServer.ListenAndServe(ctx); err != nil {
+				logger.Log.Fatal(err.Error())
+			}
+		}()
+	}
+
+	if cfg.GRPCAddress != "" {
+		go func() {
+			if err := server.InitGRPCServer(serverCfg); err != nil {
+				logger.Log.Fatal(err.Error())
+			}
+		}()
+	}
+
+	if err := server.InitServer(serverCfg); err != nil {
 		logger.Log.Fatal(err.Error())
 	}
 
@@ -97,6 +205,14 @@ func LoadConfig() (*ServerConfig, error) {
 		StoreInterval: 300 * time.Second,
 		FilePath:      "metrics.json",
 		Restore:       true,
+
+		HistoryRetention:    time.Hour,
+		AlertInterval:       15 * time.Second,
+		StatsDFlushInterval: statsd.DefaultFlushInterval,
+		ForwardInterval:     10 * time.Second,
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		cfg.ForwardSource = hostname
 	}
 
 	var configFile string
@@ -134,8 +250,61 @@ func LoadConfig() (*ServerConfig, error) {
 		if fileCfg.SecretKey != "" {
 			cfg.SecretKey = fileCfg.SecretKey
 		}
+		if fileCfg.TrustedSubnet != "" {
+			cfg.TrustedSubnet = fileCfg.TrustedSubnet
+		}
+		if fileCfg.TLSCert != "" {
+			cfg.TLSCert = fileCfg.TLSCert
+		}
+		if fileCfg.TLSKey != "" {
+			cfg.TLSKey = fileCfg.TLSKey
+		}
+		if fileCfg.TLSCA != "" {
+			cfg.TLSCA = fileCfg.TLSCA
+		}
+		cfg.TLSRequireClient = fileCfg.TLSRequireClient
+		if fileCfg.HistoryRetention != 0 {
+			cfg.HistoryRetention = fileCfg.HistoryRetention
+		}
+		if fileCfg.GRPCAddress != "" {
+			cfg.GRPCAddress = fileCfg.GRPCAddress
+		}
+		if fileCfg.AlertRules != "" {
+			cfg.AlertRules = fileCfg.AlertRules
+		}
+		if fileCfg.AlertInterval != 0 {
+			cfg.AlertInterval = fileCfg.AlertInterval
+		}
+		if len(fileCfg.AlertWebhooks) != 0 {
+			cfg.AlertWebhooks = fileCfg.AlertWebhooks
+		}
+		if fileCfg.AlertWebhookKey != "" {
+			cfg.AlertWebhookKey = fileCfg.AlertWebhookKey
+		}
+		if fileCfg.StatsDAddress != "" {
+			cfg.StatsDAddress = fileCfg.StatsDAddress
+		}
+		if fileCfg.StatsDFlushInterval != 0 {
+			cfg.StatsDFlushInterval = fileCfg.StatsDFlushInterval
+		}
+		if fileCfg.ForwardURL != "" {
+			cfg.ForwardURL = fileCfg.ForwardURL
+		}
+		if fileCfg.ForwardSource != "" {
+			cfg.ForwardSource = fileCfg.ForwardSource
+		}
+		if fileCfg.ForwardKey != "" {
+			cfg.ForwardKey = fileCfg.ForwardKey
+		}
+		if fileCfg.ForwardCryptoKey != "" {
+			cfg.ForwardCryptoKey = fileCfg.ForwardCryptoKey
+		}
+		if fileCfg.ForwardInterval != 0 {
+			cfg.ForwardInterval = fileCfg.ForwardInterval
+		}
 
 		cfg.Restore = fileCfg.Restore
+		cfg.History = fileCfg.History
 	}
 
 	flag.StringVar(&configFile, "c", "", "Path to config file")
@@ -144,11 +313,32 @@ func LoadConfig() (*ServerConfig, error) {
 	flag.StringVar(&cfg.SecretKey, "k", cfg.SecretKey, "ключ шифрования")
 	flag.DurationVar(&cfg.StoreInterval, "i", cfg.StoreInterval, "Интервал сохранения в секундах (0 = синхронное сохранение)")
 	flag.StringVar(&cfg.CryptoKey, "crypto-key", cfg.CryptoKey, "путь к файлу с приватным ключом")
+	flag.StringVar(&cfg.TrustedSubnet, "t", cfg.TrustedSubnet, "доверенная подсеть в формате CIDR")
+	flag.StringVar(&cfg.TLSCert, "tls-cert", cfg.TLSCert, "путь к TLS сертификату сервера (PEM)")
+	flag.StringVar(&cfg.TLSKey, "tls-key", cfg.TLSKey, "путь к приватному ключу TLS сертификата (PEM)")
+	flag.StringVar(&cfg.TLSCA, "tls-ca", cfg.TLSCA, "путь к CA для проверки клиентских сертификатов (PEM)")
+	flag.BoolVar(&cfg.TLSRequireClient, "tls-require-client-cert", cfg.TLSRequireClient, "требовать клиентский сертификат (mTLS)")
 	flag.StringVar(&cfg.FilePath, "f", cfg.FilePath, "Путь до файла хранения метрик")
 	flag.BoolVar(&cfg.Restore, "r", cfg.Restore, "Восстанавливать метрики при старте")
 	flag.StringVar(&cfg.DatabaseDSN, "d", cfg.DatabaseDSN, "DB DSN")
+	flag.BoolVar(&cfg.History, "history", cfg.History, "Записывать историю изменений метрик")
+	flag.DurationVar(&cfg.HistoryRetention, "history-retention", cfg.HistoryRetention, "Срок хранения истории метрик (0 = бессрочно)")
+	flag.StringVar(&cfg.GRPCAddress, "grpc-address", cfg.GRPCAddress, "адрес gRPC сервера (пусто = gRPC отключен)")
+	flag.StringVar(&cfg.AlertRules, "alert-rules", cfg.AlertRules, "Путь до файла с правилами оповещений")
+	flag.DurationVar(&cfg.AlertInterval, "alert-interval", cfg.AlertInterval, "Интервал проверки правил оповещений")
+	flag.StringVar(&cfg.StatsDAddress, "statsd-address", cfg.StatsDAddress, "UDP адрес приема метрик StatsD (пусто = отключен)")
+	flag.DurationVar(&cfg.StatsDFlushInterval, "statsd-flush-interval", cfg.StatsDFlushInterval, "Интервал записи метрик StatsD в хранилище")
+	flag.StringVar(&cfg.ForwardURL, "forward-url", cfg.ForwardURL, "адрес вышестоящего сервера для пересылки метрик (пусто = отключено)")
+	flag.StringVar(&cfg.ForwardSource, "forward-source", cfg.ForwardSource, "значение метки source пересылаемых метрик")
+	flag.StringVar(&cfg.ForwardKey, "forward-key", cfg.ForwardKey, "ключ подписи метрик для вышестоящего сервера")
+	flag.StringVar(&cfg.ForwardCryptoKey, "forward-crypto-key", cfg.ForwardCryptoKey, "путь к файлу с публичным ключом вышестоящего сервера")
+	flag.DurationVar(&cfg.ForwardInterval, "forward-interval", cfg.ForwardInterval, "Интервал пересылки метрик (0 = после каждой записи)")
+	flag.StringVar(&cfg.AlertWebhookKey, "alert-webhook-key", cfg.AlertWebhookKey, "ключ подписи оповещений для вебхуков (пусто = без подписи)")
+	webhooks := flag.String("alert-webhooks", strings.Join(cfg.AlertWebhooks, ","), "URL вебхуков для оповещений через запятую")
 	flag.Parse()
 
+	cfg.AlertWebhooks = splitList(*webhooks)
+
 	if envAddr := os.Getenv("ADDRESS"); envAddr != "" {
 		cfg.Address = envAddr
 	}
@@ -158,6 +348,23 @@ func LoadConfig() (*ServerConfig, error) {
 	if envCryptoKey := os.Getenv("CRYPTO_KEY"); envCryptoKey != "" {
 		cfg.CryptoKey = envCryptoKey
 	}
+	if envSubnet := os.Getenv("TRUSTED_SUBNET"); envSubnet != "" {
+		cfg.TrustedSubnet = envSubnet
+	}
+	if envCert := os.Getenv("TLS_CERT"); envCert != "" {
+		cfg.TLSCert = envCert
+	}
+	if envTLSKey := os.Getenv("TLS_KEY"); envTLSKey != "" {
+		cfg.TLSKey = envTLSKey
+	}
+	if envCA := os.Getenv("TLS_CA"); envCA != "" {
+		cfg.TLSCA = envCA
+	}
+	if envRequire := os.Getenv("TLS_REQUIRE_CLIENT_CERT"); envRequire != "" {
+		if val, err := strconv.ParseBool(envRequire); err == nil {
+			cfg.TLSRequireClient = val
+		}
+	}
 	if envStoreInt := os.Getenv("STORE_INTERVAL"); envStoreInt != "" {
 		if val, err := strconv.Atoi(envStoreInt); err == nil {
 			cfg.StoreInterval = time.Duration(val) * time.Second
@@ -174,10 +381,86 @@ func LoadConfig() (*ServerConfig, error) {
 	if envDSN := os.Getenv("DATABASE_DSN"); envDSN != "" {
 		cfg.DatabaseDSN = envDSN
 	}
+	if envHistory := os.Getenv("HISTORY"); envHistory != "" {
+		if val, err := strconv.ParseBool(envHistory); err == nil {
+			cfg.History = val
+		}
+	}
+	if envRetention := os.Getenv("HISTORY_RETENTION"); envRetention != "" {
+		if dur, err := time.ParseDuration(envRetention); err == nil {
+			cfg.HistoryRetention = dur
+		}
+	}
+
+	if envGRPC := os.Getenv("GRPC_ADDRESS"); envGRPC != "" {
+		cfg.GRPCAddress = envGRPC
+	}
+	if envRules := os.Getenv("ALERT_RULES"); envRules != "" {
+		cfg.AlertRules = envRules
+	}
+	if envInterval := os.Getenv("ALERT_INTERVAL"); envInterval != "" {
+		if dur, err := time.ParseDuration(envInterval); err == nil {
+			cfg.AlertInterval = dur
+		}
+	}
+
+	if envWebhooks := os.Getenv("ALERT_WEBHOOKS"); envWebhooks != "" {
+		cfg.AlertWebhooks = splitList(envWebhooks)
+	}
+	if envWebhookKey := os.Getenv("ALERT_WEBHOOK_KEY"); envWebhookKey != "" {
+		cfg.AlertWebhookKey = envWebhookKey
+	}
+
+	if envStatsD := os.Getenv("STATSD_ADDRESS"); envStatsD != "" {
+		cfg.StatsDAddress = envStatsD
+	}
+	if envFlush := os.Getenv("STATSD_FLUSH_INTERVAL"); envFlush != "" {
+		if dur, err := time.ParseDuration(envFlush); err == nil {
+			cfg.StatsDFlushInterval = dur
+		}
+	}
+	if envForward := os.Getenv("FORWARD_URL"); envForward != "" {
+		cfg.ForwardURL = envForward
+	}
+	if envSource := os.Getenv("FORWARD_SOURCE"); envSource != "" {
+		cfg.ForwardSource = envSource
+	}
+	if envForwardKey := os.Getenv("FORWARD_KEY"); envForwardKey != "" {
+		cfg.ForwardKey = envForwardKey
+	}
+	if envForwardCryptoKey := os.Getenv("FORWARD_CRYPTO_KEY"); envForwardCryptoKey != "" {
+		cfg.ForwardCryptoKey = envForwardCryptoKey
+	}
+	if envForwardInterval := os.Getenv("FORWARD_INTERVAL"); envForwardInterval != "" {
+		if dur, err := time.ParseDuration(envForwardInterval); err == nil {
+			cfg.ForwardInterval = dur
+		}
+	}
+
+	if cfg.AlertInterval <= 0 {
+		return nil, fmt.Errorf("alert interval must be positive, got %s", cfg.AlertInterval)
+	}
+	if cfg.ForwardInterval < 0 {
+		return nil, fmt.Errorf("forward interval must not be negative, got %s", cfg.ForwardInterval)
+	}
+	if cfg.StatsDFlushInterval <= 0 {
+		return nil, fmt.Errorf("statsd flush interval must be positive, got %s", cfg.StatsDFlushInterval)
+	}
 
 	return cfg, nil
 }
 
+// splitList splits a comma separated list, dropping empty items
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func initDB(dsn string) (*sql.DB, error) {
 	conn, err := sql.Open("pgx", dsn)
 	if err != nil {
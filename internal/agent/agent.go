@@ -5,35 +5,50 @@ package agent
 import (
 	"context"
 	"crypto/rsa"
-	"crypto/x509"
-	"os"
+	"crypto/tls"
 	"time"
 
+	"github.com/runtime-metrics-course/internal/encryption"
 	"github.com/runtime-metrics-course/internal/logger"
 	"github.com/runtime-metrics-course/internal/models"
+	"github.com/runtime-metrics-course/internal/outbox"
+	"github.com/runtime-metrics-course/internal/tlsconfig"
 )
 
 // Config contains agent configuration parameters.
 // Fields can be set via environment variables (see env tags).
 type Config struct {
-	Host           string         // Server address to report metrics to
-	SecretKey      string         // Secret key for request signing
-	CryptoKeyPath  string         // Path to public key
-	PollInterval   time.Duration  // How often to collect metrics
-	ReportInterval time.Duration  // How often to send metrics
-	RateLimit      int            // Maximum concurrent requests
-	PablicKey      *rsa.PublicKey // Public key for encrypt
+	Host           string                     // Server address to report metrics to
+	Transport      string                     // TransportHTTP (default) or TransportGRPC
+	GRPCAddress    string                     // gRPC server address (host:port), used with TransportGRPC
+	SecretKey      string                     // Secret key for request signing
+	CryptoKeyPath  string                     // Path to the server's RSA public key (PEM) for body encryption
+	PollInterval   time.Duration              // How often to collect metrics
+	ReportInterval time.Duration              // How often to send metrics
+	RateLimit      int                        // Maximum concurrent requests
+	Labels         models.Labels              // Labels attached to every reported metric (e.g. host)
+	PauseBuckets   []float64                  // GC pause histogram bucket bounds in ns (DefaultPauseBuckets if empty)
+	TLS            tlsconfig.Config           // Server verification (CAFile) and client certificate for HTTPS/gRPC
+	Collectors     map[string]CollectorConfig // Collector settings by name (see RegisterCollector)
+	OutboxDir      string                     // Directory of the on-disk queue of undelivered metrics; empty disables it
+	OutboxMaxSize  int64                      // Maximum outbox size in bytes (outbox.DefaultMaxSize if zero)
+	OutboxMaxAge   time.Duration              // Queued metrics older than this are dropped (outbox.DefaultMaxAge if zero)
+	PablicKey      *rsa.PublicKey             // Public key for encrypt
 	Ctx            context.Context
 }
 
-// Task represents a metric reporting task containing the metric to be sent.
+// Task represents a metric reporting task containing a batch of metrics sent in one request.
 type Task struct {
-	Metric models.MetricJSON // Metric data in JSON format
+	Metrics []models.MetricJSON // Metrics in JSON format
+	source  *Registry           // Registry the metrics were taken from, to restore them if they cannot be delivered
 }
 
 // Global configuration instance
 var cfg Config
 
+// tlsClientConfig is the TLS configuration of connections to the server, nil without TLS
+var tlsClientConfig *tls.Config
+
 // StartAgent initializes and runs the metrics collection and reporting agent.
 // Parameters:
 //   - conf: Agent configuration
@@ -42,8 +57,8 @@ var cfg Config
 //   - error: if initialization fails
 //
 // The agent runs two main loops:
-//   - Poll loop: collects system metrics at regular intervals
-//   - Report loop: sends collected metrics to server
+//   - Poll loop: collects system metrics into a Registry at regular intervals
+//   - Report loop: sends a snapshot of the registry to the server as one batch
 //
 // Example:
 //
@@ -58,7 +73,54 @@ var cfg Config
 //	}
 func StartAgent(conf Config) error {
 	cfg = conf
-	cfg.PablicKey = getPublicKey(cfg.CryptoKeyPath)
+	if cfg.CryptoKeyPath != "" {
+		key, err := encryption.LoadPublicKey(cfg.CryptoKeyPath)
+		if err != nil {
+			return err
+		}
+		cfg.PablicKey = key
+	}
+	if cfg.TLS.Enabled() {
+		tlsCfg, err := tlsconfig.Client(cfg.TLS)
+		if err != nil {
+			return err
+		}
+		tlsClientConfig = tlsCfg
+	}
+	if cfg.OutboxDir != "" {
+		queue, err := outbox.Open(outbox.Config{
+			Dir:     cfg.OutboxDir,
+			MaxSize: cfg.OutboxMaxSize,
+			MaxAge:  cfg.OutboxMaxAge,
+		})
+		if err != nil {
+			return err
+		}
+		pending = queue
+		defer func() {
+			if err := pending.Close(); err != nil {
+				logger.Log.Error(err.Error())
+			}
+		}()
+	}
+
+	var sender *grpcSender
+	if cfg.Transport == TransportGRPC {
+		var err error
+		if sender, err = newGRPCSender(cfg.GRPCAddress); err != nil {
+			return err
+		}
+		defer sender.Close()
+	}
+
+	collectors, err := newCollectors(cfg.Collectors)
+	if err != nil {
+		return err
+	}
+
+	// Collected metrics are aggregated between reports
+	registry := NewRegistry(cfg.Labels)
+
 	// Initialize tickers for periodic operations
 	pollTicker := time.NewTicker(cfg.PollInterval)
 	reportTicker := time.NewTicker(cfg.ReportInterval)
@@ -67,9 +129,10 @@ func StartAgent(conf Config) error {
 		reportTicker.Stop()
 	}()
 
-	// Channel for metric reporting tasks
-	taskChan := make(chan Task)
+	// Channel for metric reporting tasks, holding one report waiting for the worker
+	taskChan := make(chan Task, 1)
 	defer close(taskChan)
+	startWorkerPool(cfg.Ctx, cfg.RateLimit, taskChan, sender)
 
 	// Main agent loop
 	for {
@@ -81,27 +144,10 @@ func StartAgent(conf Config) error {
 			return nil
 		case <-pollTicker.C:
 			// Collect metrics in separate goroutines
-			go CollectRuntimeMetrics(taskChan)
-			go CollectGoupsutiMetrics(taskChan)
+			collect(cfg.Ctx, collectors, registry)
 		case <-reportTicker.C:
-			// Start workers to send metrics
-			go startWorkerPool(cfg.Ctx, cfg.RateLimit, taskChan)
+			// Send the metrics aggregated since the previous report
+			report(registry, taskChan)
 		}
 	}
 }
-
-func getPublicKey(CryptKeyPath string) *rsa.PublicKey {
-	keyBytes, err := os.ReadFile(CryptKeyPath)
-	if err != nil {
-		logger.Log.Sugar().Error(err)
-		return nil
-	}
-
-	key, err := x509.ParsePKCS1PublicKey(keyBytes)
-	if err != nil {
-		logger.Log.Sugar().Error(err)
-		return nil
-	}
-
-	return key
-}
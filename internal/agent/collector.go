@@ -1,9 +1,14 @@
 package agent
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"math/rand"
 	"runtime"
+	"sort"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/runtime-metrics-course/internal/models"
@@ -11,14 +16,47 @@ import (
 	"github.com/shirou/gopsutil/mem"
 )
 
-func CollectRuntimeMetrics(ch chan<- Task) {
+// DefaultPauseBuckets are the GC pause histogram bucket bounds in nanoseconds (10µs..100ms)
+var DefaultPauseBuckets = []float64{1e4, 5e4, 1e5, 5e5, 1e6, 5e6, 1e7, 5e7, 1e8}
+
+// ValidatePauseBuckets checks that GC pause bucket bounds are finite and
+// strictly increasing, as the server rejects other histograms
+func ValidatePauseBuckets(buckets []float64) error {
+	for i, bound := range buckets {
+		if math.IsNaN(bound) || math.IsInf(bound, 0) {
+			return fmt.Errorf("GC pause bucket bound %v is not finite", bound)
+		}
+		if i > 0 && bound <= buckets[i-1] {
+			return errors.New("GC pause bucket bounds must be strictly increasing")
+		}
+	}
+	return nil
+}
+
+// gcPauses remembers the number of GC cycles already reported in the pause histogram
+var gcPauses struct {
+	mu        sync.Mutex
+	lastNumGC uint32
+}
+
+// memStatCounters turns the monotonic MemStats fields into counter deltas.
+// They count from the start of the agent process, so the first poll reports
+// everything counted so far.
+var memStatCounters = struct {
+	mu     sync.Mutex
+	deltas *counterDeltas
+}{deltas: newCounterDeltasFromZero()}
+
+// CollectRuntimeMetrics records Go runtime memory statistics, the PollCount
+// counter and the GC pause histogram. Monotonic statistics such as NumGC and
+// Mallocs are recorded as counters of the increments since the previous poll.
+func CollectRuntimeMetrics(r *Registry) {
 	var memStats runtime.MemStats
 	runtime.ReadMemStats(&memStats)
 
 	memStat := map[string]float64{
 		"Alloc":         float64(memStats.Alloc),
 		"BuckHashSys":   float64(memStats.BuckHashSys),
-		"Frees":         float64(memStats.Frees),
 		"GCCPUFraction": memStats.GCCPUFraction,
 		"GCSys":         float64(memStats.GCSys),
 		"HeapAlloc":     float64(memStats.HeapAlloc),
@@ -28,41 +66,118 @@ func CollectRuntimeMetrics(ch chan<- Task) {
 		"HeapReleased":  float64(memStats.HeapReleased),
 		"HeapSys":       float64(memStats.HeapSys),
 		"LastGC":        float64(memStats.LastGC),
-		"Lookups":       float64(memStats.Lookups),
 		"MCacheInuse":   float64(memStats.MCacheInuse),
 		"MCacheSys":     float64(memStats.MCacheSys),
 		"MSpanInuse":    float64(memStats.MSpanInuse),
 		"MSpanSys":      float64(memStats.MSpanSys),
-		"Mallocs":       float64(memStats.Mallocs),
 		"NextGC":        float64(memStats.NextGC),
-		"NumForcedGC":   float64(memStats.NumForcedGC),
-		"NumGC":         float64(memStats.NumGC),
 		"OtherSys":      float64(memStats.OtherSys),
-		"PauseTotalNs":  float64(memStats.PauseTotalNs),
 		"StackInuse":    float64(memStats.StackInuse),
 		"StackSys":      float64(memStats.StackSys),
 		"Sys":           float64(memStats.Sys),
-		"TotalAlloc":    float64(memStats.TotalAlloc),
 		"RandomValue":   rand.Float64(),
 	}
 
 	for name, value := range memStat {
-		ch <- Task{Metric: models.MetricJSON{ID: name, MType: models.Gauge, Value: &value}}
+		r.Record(models.MetricJSON{ID: name, MType: models.Gauge, Value: &value})
 	}
 
+	pollCount := int64(1)
+	r.Record(models.MetricJSON{ID: "PollCount", MType: models.Counter, Delta: &pollCount})
+	recordMemStatCounters(r, &memStats)
+
+	if metric, ok := gcPauseHistogram(&memStats, cfg.PauseBuckets); ok {
+		r.Record(metric)
+	}
 }
 
-func CollectGoupsutiMetrics(ch chan<- Task) {
+// recordMemStatCounters records the increments of the monotonic MemStats fields
+func recordMemStatCounters(r *Registry, memStats *runtime.MemStats) {
+	memStatCounters.mu.Lock()
+	defer memStatCounters.mu.Unlock()
+	defer memStatCounters.deltas.next()
 
-	v, _ := mem.VirtualMemory()
-	total := float64(v.Total)
-	free := float64(v.Free)
-	cpuPercents, _ := cpu.Percent(time.Second, false)
-	for i, cpuUtilization := range cpuPercents {
-		ch <- Task{Metric: models.MetricJSON{ID: fmt.Sprintf("CPUutilization %d", i), MType: models.Gauge, Value: &cpuUtilization}}
+	for name, value := range map[string]uint64{
+		"Frees":        memStats.Frees,
+		"Lookups":      memStats.Lookups,
+		"Mallocs":      memStats.Mallocs,
+		"NumForcedGC":  uint64(memStats.NumForcedGC),
+		"NumGC":        uint64(memStats.NumGC),
+		"PauseTotalNs": memStats.PauseTotalNs,
+		"TotalAlloc":   memStats.TotalAlloc,
+	} {
+		if delta, ok := memStatCounters.deltas.delta(name, value); ok {
+			r.Record(models.MetricJSON{ID: name, MType: models.Counter, Delta: &delta})
+		}
 	}
+}
 
-	ch <- Task{Metric: models.MetricJSON{ID: "TotalMemory", MType: models.Gauge, Value: &total}}
-	ch <- Task{Metric: models.MetricJSON{ID: "FreeMemory", MType: models.Gauge, Value: &free}}
+// gcPauseHistogram builds a histogram of GC pauses that happened since the
+// previous call from the circular MemStats.PauseNs buffer. Returns false if
+// there were no new GC cycles.
+func gcPauseHistogram(memStats *runtime.MemStats, buckets []float64) (models.MetricJSON, bool) {
+	if len(buckets) == 0 {
+		buckets = DefaultPauseBuckets
+	}
+
+	gcPauses.mu.Lock()
+	newCycles := memStats.NumGC - gcPauses.lastNumGC
+	if memStats.NumGC < gcPauses.lastNumGC {
+		newCycles = 0
+	}
+	gcPauses.lastNumGC = memStats.NumGC
+	gcPauses.mu.Unlock()
+
+	if newCycles == 0 {
+		return models.MetricJSON{}, false
+	}
+	// PauseNs only keeps the most recent pauses
+	if newCycles > uint32(len(memStats.PauseNs)) {
+		newCycles = uint32(len(memStats.PauseNs))
+	}
+
+	counts := make([]uint64, len(buckets)+1)
+	var sum float64
+	for i := uint32(0); i < newCycles; i++ {
+		pause := float64(memStats.PauseNs[(memStats.NumGC-i+255)%256])
+		counts[sort.SearchFloat64s(buckets, pause)]++
+		sum += pause
+	}
+
+	return models.MetricJSON{
+		ID:      "GCPauseNs",
+		MType:   models.Histogram,
+		Buckets: buckets,
+		Counts:  counts,
+		Sum:     &sum,
+	}, true
+}
+
+// CollectGoupsutiMetrics records system memory and per CPU utilization.
+// Values that cannot be read are left out and their errors are returned.
+func CollectGoupsutiMetrics(r *Registry) error {
+	var errs []error
+	if v, err := mem.VirtualMemory(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to read virtual memory: %w", err))
+	} else {
+		total := float64(v.Total)
+		free := float64(v.Free)
+		r.Record(models.MetricJSON{ID: "TotalMemory", MType: models.Gauge, Value: &total})
+		r.Record(models.MetricJSON{ID: "FreeMemory", MType: models.Gauge, Value: &free})
+	}
+
+	cpuPercents, err := cpu.Percent(time.Second, false)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("failed to read CPU utilization: %w", err))
+	}
+	for i, cpuUtilization := range cpuPercents {
+		r.Record(models.MetricJSON{
+			ID:     "CPUutilization",
+			MType:  models.Gauge,
+			Value:  &cpuUtilization,
+			Labels: models.Labels{"cpu": strconv.Itoa(i)},
+		})
+	}
 
+	return errors.Join(errs...)
 }
@@ -0,0 +1,43 @@
+package agent
+
+import (
+	"math"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/runtime-metrics-course/internal/models"
+)
+
+func TestCollectRuntimeMetrics_Counters(t *testing.T) {
+	r := NewRegistry(nil)
+	CollectRuntimeMetrics(r)
+	r.Snapshot()
+
+	runtime.GC()
+	CollectRuntimeMetrics(r)
+	CollectRuntimeMetrics(r)
+
+	counters := make(map[string]int64)
+	for _, m := range r.Snapshot() {
+		if m.MType == models.Counter {
+			counters[m.ID] = *m.Delta
+		}
+	}
+	assert.Equal(t, int64(2), counters["PollCount"])
+	require.Contains(t, counters, "NumGC")
+	assert.GreaterOrEqual(t, counters["NumGC"], int64(1), "increments since the previous snapshot only")
+	assert.Equal(t, int64(1), counters["NumForcedGC"])
+	assert.Positive(t, counters["Mallocs"])
+}
+
+func TestValidatePauseBuckets(t *testing.T) {
+	assert.NoError(t, ValidatePauseBuckets(nil))
+	assert.NoError(t, ValidatePauseBuckets(DefaultPauseBuckets))
+	assert.Error(t, ValidatePauseBuckets([]float64{1e4, 1e4}), "bounds not strictly increasing")
+	assert.Error(t, ValidatePauseBuckets([]float64{1e5, 1e4}))
+	assert.Error(t, ValidatePauseBuckets([]float64{1e4, math.NaN()}))
+	assert.Error(t, ValidatePauseBuckets([]float64{1e4, math.Inf(1)}))
+}
@@ -0,0 +1,182 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/runtime-metrics-course/internal/logger"
+)
+
+// Built-in collector names
+const (
+	CollectorRuntime        = "runtime"         // Go runtime memory statistics (CollectRuntimeMetrics)
+	CollectorRuntimeMetrics = "runtime_metrics" // All runtime/metrics values (RuntimeMetricsCollector)
+	CollectorSystem         = "system"          // System memory and CPU utilization (CollectGoupsutiMetrics)
+	CollectorHost           = "host"            // Load average, swap and uptime (CollectHostMetrics)
+	CollectorProcess        = "process"         // Resource usage of selected processes (ProcessCollector)
+	CollectorDisk           = "disk"            // Filesystem usage and disk I/O (DiskCollector)
+	CollectorNetwork        = "network"         // Network interface counters and TCP connections (NetworkCollector)
+)
+
+// Collector collects metrics into the registry on every poll
+type Collector interface {
+	Collect(ctx context.Context, r *Registry) error
+}
+
+// CollectorFunc adapts an ordinary function to the Collector interface
+type CollectorFunc func(ctx context.Context, r *Registry) error
+
+// Collect calls f(ctx, r)
+func (f CollectorFunc) Collect(ctx context.Context, r *Registry) error {
+	return f(ctx, r)
+}
+
+// CollectorFactory creates a collector from its options in the agent
+// configuration. Options are nil if none are configured.
+type CollectorFactory func(options json.RawMessage) (Collector, error)
+
+// CollectorConfig enables, disables and configures a collector
+type CollectorConfig struct {
+	Enabled *bool           `json:"enabled,omitempty"` // Whether the collector runs, the registered default if nil
+	Options json.RawMessage `json:"options,omitempty"` // Collector specific options passed to its factory
+}
+
+// registration is a registered collector
+type registration struct {
+	factory CollectorFactory
+	enabled bool
+}
+
+// registered holds the collectors available to the agent by name
+var registered = struct {
+	sync.RWMutex
+	collectors map[string]registration
+}{collectors: make(map[string]registration)}
+
+func init() {
+	RegisterCollector(CollectorRuntime, true, func(json.RawMessage) (Collector, error) {
+		return CollectorFunc(func(_ context.Context, r *Registry) error {
+			CollectRuntimeMetrics(r)
+			return nil
+		}), nil
+	})
+	RegisterCollector(CollectorSystem, true, func(json.RawMessage) (Collector, error) {
+		return CollectorFunc(func(_ context.Context, r *Registry) error {
+			return CollectGoupsutiMetrics(r)
+		}), nil
+	})
+	RegisterCollector(CollectorHost, true, func(json.RawMessage) (Collector, error) {
+		return CollectorFunc(CollectHostMetrics), nil
+	})
+}
+
+// RegisterCollector makes a collector available to the agent under name.
+// Collectors registered with enabledByDefault run unless disabled in
+// Config.Collectors, the others only when enabled there.
+//
+// RegisterCollector is meant to be called from init functions, before
+// StartAgent. It panics if the name is already registered or factory is nil.
+//
+// Example:
+//
+//	func init() {
+//	    agent.RegisterCollector("queue", true, func(json.RawMessage) (agent.Collector, error) {
+//	        return agent.CollectorFunc(func(ctx context.Context, r *agent.Registry) error {
+//	            depth := float64(queue.Len())
+//	            r.Record(models.MetricJSON{ID: "QueueDepth", MType: models.Gauge, Value: &depth})
+//	            return nil
+//	        }), nil
+//	    })
+//	}
+func RegisterCollector(name string, enabledByDefault bool, factory CollectorFactory) {
+	if factory == nil {
+		panic("agent: RegisterCollector factory is nil")
+	}
+
+	registered.Lock()
+	defer registered.Unlock()
+	if _, ok := registered.collectors[name]; ok {
+		panic("agent: RegisterCollector called twice for collector " + name)
+	}
+	registered.collectors[name] = registration{factory: factory, enabled: enabledByDefault}
+}
+
+// Collectors returns the sorted names of all registered collectors
+func Collectors() []string {
+	registered.RLock()
+	defer registered.RUnlock()
+	return collectorNames()
+}
+
+// collectorNames returns the sorted names of all registered collectors.
+// The caller must hold the registered lock.
+func collectorNames() []string {
+	names := make([]string, 0, len(registered.collectors))
+	for name := range registered.collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// namedCollector is an enabled collector with its registered name
+type namedCollector struct {
+	Collector
+	name    string
+	running *atomic.Bool // Set while a Collect call is in progress
+}
+
+// newCollectors creates the collectors enabled by the configuration.
+// Returns an error for unknown collector names and invalid options.
+func newCollectors(config map[string]CollectorConfig) ([]namedCollector, error) {
+	registered.RLock()
+	defer registered.RUnlock()
+
+	for name := range config {
+		if _, ok := registered.collectors[name]; !ok {
+			return nil, fmt.Errorf("unknown collector %q", name)
+		}
+	}
+
+	var collectors []namedCollector
+	for _, name := range collectorNames() {
+		reg := registered.collectors[name]
+		conf := config[name]
+		enabled := reg.enabled
+		if conf.Enabled != nil {
+			enabled = *conf.Enabled
+		}
+		if !enabled {
+			continue
+		}
+
+		c, err := reg.factory(conf.Options)
+		if err != nil {
+			return nil, fmt.Errorf("collector %s: %w", name, err)
+		}
+		collectors = append(collectors, namedCollector{Collector: c, name: name, running: new(atomic.Bool)})
+	}
+	return collectors, nil
+}
+
+// collect runs every collector in its own goroutine, logging failures.
+// A collector whose previous run has not finished yet is skipped, so a slow
+// collector does not pile up goroutines across polls.
+func collect(ctx context.Context, collectors []namedCollector, r *Registry) {
+	for _, c := range collectors {
+		if !c.running.CompareAndSwap(false, true) {
+			logger.Log.Sugar().Warnf("collector %s skipped: previous run still in progress", c.name)
+			continue
+		}
+		go func(c namedCollector) {
+			defer c.running.Store(false)
+			if err := c.Collect(ctx, r); err != nil {
+				logger.Log.Sugar().Errorf("collector %s failed: %v", c.name, err)
+			}
+		}(c)
+	}
+}
@@ -0,0 +1,155 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/runtime-metrics-course/internal/models"
+)
+
+// testCollector records a gauge with the value from its options
+type testCollector struct {
+	Value float64 `json:"value"`
+}
+
+func (c *testCollector) Collect(_ context.Context, r *Registry) error {
+	r.Record(gaugeJSON("Test", c.Value))
+	return nil
+}
+
+func init() {
+	RegisterCollector("test", false, func(options json.RawMessage) (Collector, error) {
+		c := &testCollector{Value: 1}
+		if options != nil {
+			if err := json.Unmarshal(options, c); err != nil {
+				return nil, err
+			}
+		}
+		return c, nil
+	})
+}
+
+func enabled(on bool) *bool {
+	return &on
+}
+
+func names(collectors []namedCollector) []string {
+	var result []string
+	for _, c := range collectors {
+		result = append(result, c.name)
+	}
+	return result
+}
+
+func TestNewCollectors(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    map[string]CollectorConfig
+		contains  []string
+		excludes  []string
+		expectErr bool
+	}{
+		{
+			name:     "Defaults",
+			contains: []string{CollectorRuntime, CollectorSystem},
+			excludes: []string{"test"},
+		},
+		{
+			name: "Enable and disable by name",
+			config: map[string]CollectorConfig{
+				"test":          {Enabled: enabled(true)},
+				CollectorSystem: {Enabled: enabled(false)},
+			},
+			contains: []string{CollectorRuntime, "test"},
+			excludes: []string{CollectorSystem},
+		},
+		{
+			name:      "Unknown collector",
+			config:    map[string]CollectorConfig{"unknown": {Enabled: enabled(true)}},
+			expectErr: true,
+		},
+		{
+			name:      "Invalid options",
+			config:    map[string]CollectorConfig{"test": {Enabled: enabled(true), Options: json.RawMessage(`"x"`)}},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collectors, err := newCollectors(tt.config)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			got := names(collectors)
+			assert.Subset(t, got, tt.contains)
+			for _, name := range tt.excludes {
+				assert.NotContains(t, got, name)
+			}
+		})
+	}
+}
+
+func TestNewCollectors_Options(t *testing.T) {
+	config := make(map[string]CollectorConfig)
+	for _, name := range Collectors() {
+		config[name] = CollectorConfig{Enabled: enabled(false)}
+	}
+	config["test"] = CollectorConfig{Enabled: enabled(true), Options: json.RawMessage(`{"value": 42}`)}
+
+	collectors, err := newCollectors(config)
+	require.NoError(t, err)
+	require.Len(t, collectors, 1)
+
+	r := NewRegistry(nil)
+	require.NoError(t, collectors[0].Collect(context.Background(), r))
+	assert.Equal(t, []models.MetricJSON{gaugeJSON("Test", 42)}, r.Snapshot())
+}
+
+func TestRegisterCollector_Duplicate(t *testing.T) {
+	assert.Panics(t, func() {
+		RegisterCollector(CollectorRuntime, true, func(json.RawMessage) (Collector, error) {
+			return nil, errors.New("not used")
+		})
+	})
+	assert.Panics(t, func() {
+		RegisterCollector("nil", true, nil)
+	})
+	assert.Contains(t, Collectors(), "test")
+}
+
+// blockingCollector counts its runs and blocks until release is closed
+type blockingCollector struct {
+	runs    atomic.Int32
+	release chan struct{}
+}
+
+func (c *blockingCollector) Collect(context.Context, *Registry) error {
+	c.runs.Add(1)
+	<-c.release
+	return nil
+}
+
+func TestCollect_SkipsRunning(t *testing.T) {
+	c := &blockingCollector{release: make(chan struct{})}
+	collectors := []namedCollector{{Collector: c, name: "blocking", running: new(atomic.Bool)}}
+	r := NewRegistry(nil)
+
+	collect(context.Background(), collectors, r)
+	collect(context.Background(), collectors, r)
+	require.Eventually(t, func() bool { return c.runs.Load() == 1 }, time.Second, time.Millisecond)
+
+	close(c.release)
+	require.Eventually(t, func() bool { return !collectors[0].running.Load() }, time.Second, time.Millisecond)
+	collect(context.Background(), collectors, r)
+	require.Eventually(t, func() bool { return c.runs.Load() == 2 }, time.Second, time.Millisecond)
+}
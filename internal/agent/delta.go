@@ -0,0 +1,49 @@
+package agent
+
+// counterDeltas turns cumulative counter readings, such as bytes read by a
+// process since it started, into the increments reported as models.Counter
+// deltas. It is not safe for concurrent use.
+type counterDeltas struct {
+	prev     map[string]uint64 // Readings of the previous poll
+	seen     map[string]uint64 // Readings of the current poll
+	fromZero bool              // First readings count from zero instead of setting the baseline
+}
+
+func newCounterDeltas() *counterDeltas {
+	return &counterDeltas{
+		prev: make(map[string]uint64),
+		seen: make(map[string]uint64),
+	}
+}
+
+// newCounterDeltasFromZero creates deltas of counters that start at zero
+// together with the agent, such as the Go runtime counters of the agent
+// process, so that the first reading is reported as a whole
+func newCounterDeltasFromZero() *counterDeltas {
+	d := newCounterDeltas()
+	d.fromZero = true
+	return d
+}
+
+// delta records a reading and returns the increase since the previous poll.
+// The first reading of a key only sets the baseline and returns false,
+// unless the deltas count from zero.
+// A reading below the previous one means the counter was reset (e.g. the
+// process restarted), so the whole reading is the increase.
+func (d *counterDeltas) delta(key string, value uint64) (int64, bool) {
+	d.seen[key] = value
+	prev, ok := d.prev[key]
+	if !ok && !d.fromZero {
+		return 0, false
+	}
+	if value < prev {
+		return int64(value), true
+	}
+	return int64(value - prev), true
+}
+
+// next finishes a poll; keys without a reading in it are forgotten
+func (d *counterDeltas) next() {
+	d.prev, d.seen = d.seen, d.prev
+	clear(d.seen)
+}
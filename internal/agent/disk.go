@@ -0,0 +1,143 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/runtime-metrics-course/internal/models"
+	"github.com/shirou/gopsutil/disk"
+)
+
+// DiskOptions are the options of the disk collector. Every list holds glob
+// patterns (see filepath.Match, "*" does not match "/"); empty include lists
+// select everything.
+type DiskOptions struct {
+	Mounts         []string `json:"mounts,omitempty"`          // Mount points to report usage of, e.g. "/", "/data"
+	ExcludeMounts  []string `json:"exclude_mounts,omitempty"`  // Mount points left out, e.g. "/snap/*"
+	Devices        []string `json:"devices,omitempty"`         // Block devices to report I/O of, e.g. "sd*", "nvme*"
+	ExcludeDevices []string `json:"exclude_devices,omitempty"` // Block devices left out, e.g. "loop*"
+}
+
+// DiskCollector reports filesystem usage per mount point, labeled "mount":
+//
+//   - DiskTotal, DiskUsed, DiskFree - space in bytes
+//   - DiskInodesTotal, DiskInodesUsed, DiskInodesFree - inodes
+//
+// and I/O counters per block device, labeled "device":
+//
+//   - DiskReads, DiskWrites - completed operations
+//   - DiskReadBytes, DiskWriteBytes - transferred bytes
+//   - DiskIOTime - milliseconds spent doing I/O
+//
+// Only physical filesystems are reported (see disk.Partitions).
+type DiskCollector struct {
+	mounts  nameFilter
+	devices nameFilter
+
+	mu sync.Mutex
+	io *counterDeltas
+}
+
+func init() {
+	RegisterCollector(CollectorDisk, true, func(options json.RawMessage) (Collector, error) {
+		var opts DiskOptions
+		if options != nil {
+			if err := json.Unmarshal(options, &opts); err != nil {
+				return nil, err
+			}
+		}
+		return NewDiskCollector(opts)
+	})
+}
+
+// NewDiskCollector creates a disk collector.
+// Returns an error if a pattern is malformed.
+func NewDiskCollector(opts DiskOptions) (*DiskCollector, error) {
+	mounts, err := newNameFilter(opts.Mounts, opts.ExcludeMounts)
+	if err != nil {
+		return nil, fmt.Errorf("mounts: %w", err)
+	}
+	devices, err := newNameFilter(opts.Devices, opts.ExcludeDevices)
+	if err != nil {
+		return nil, fmt.Errorf("devices: %w", err)
+	}
+	return &DiskCollector{mounts: mounts, devices: devices, io: newCounterDeltas()}, nil
+}
+
+// Collect records usage of the selected mount points and I/O of the selected devices
+func (c *DiskCollector) Collect(ctx context.Context, r *Registry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return errors.Join(c.collectUsage(ctx, r), c.collectIO(ctx, r))
+}
+
+// collectUsage records filesystem usage per mount point
+func (c *DiskCollector) collectUsage(ctx context.Context, r *Registry) error {
+	partitions, err := disk.PartitionsWithContext(ctx, false)
+	if err != nil {
+		return fmt.Errorf("failed to list partitions: %w", err)
+	}
+
+	var errs []error
+	seen := make(map[string]struct{}, len(partitions))
+	for _, p := range partitions {
+		if _, ok := seen[p.Mountpoint]; ok || !c.mounts.match(p.Mountpoint) {
+			continue
+		}
+		seen[p.Mountpoint] = struct{}{}
+
+		usage, err := disk.UsageWithContext(ctx, p.Mountpoint)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to read usage of %s: %w", p.Mountpoint, err))
+			continue
+		}
+
+		labels := models.Labels{"mount": p.Mountpoint}
+		for id, value := range map[string]uint64{
+			"DiskTotal":       usage.Total,
+			"DiskUsed":        usage.Used,
+			"DiskFree":        usage.Free,
+			"DiskInodesTotal": usage.InodesTotal,
+			"DiskInodesUsed":  usage.InodesUsed,
+			"DiskInodesFree":  usage.InodesFree,
+		} {
+			v := float64(value)
+			r.Record(models.MetricJSON{ID: id, MType: models.Gauge, Value: &v, Labels: labels})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// collectIO records I/O counter increments per device since the previous poll
+func (c *DiskCollector) collectIO(ctx context.Context, r *Registry) error {
+	defer c.io.next()
+
+	counters, err := disk.IOCountersWithContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read disk I/O counters: %w", err)
+	}
+
+	for device, io := range counters {
+		if !c.devices.match(device) {
+			continue
+		}
+
+		labels := models.Labels{"device": device}
+		for id, value := range map[string]uint64{
+			"DiskReads":      io.ReadCount,
+			"DiskWrites":     io.WriteCount,
+			"DiskReadBytes":  io.ReadBytes,
+			"DiskWriteBytes": io.WriteBytes,
+			"DiskIOTime":     io.IoTime,
+		} {
+			if delta, ok := c.io.delta(device+"/"+id, value); ok {
+				r.Record(models.MetricJSON{ID: id, MType: models.Counter, Delta: &delta, Labels: labels})
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/runtime-metrics-course/internal/models"
+)
+
+func TestNameFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		include  []string
+		exclude  []string
+		selected []string
+		skipped  []string
+	}{
+		{
+			name:     "Everything by default",
+			selected: []string{"/", "/data", "sda"},
+		},
+		{
+			name:     "Include list",
+			include:  []string{"/", "/data*"},
+			selected: []string{"/", "/data", "/data2"},
+			skipped:  []string{"/boot", "/data/nested"},
+		},
+		{
+			name:     "Exclude wins over include",
+			include:  []string{"sd*", "nvme*"},
+			exclude:  []string{"sdb"},
+			selected: []string{"sda", "nvme0n1"},
+			skipped:  []string{"sdb", "loop0"},
+		},
+		{
+			name:     "Exclude only",
+			exclude:  []string{"loop*", "/snap/*"},
+			selected: []string{"sda", "/"},
+			skipped:  []string{"loop3", "/snap/core"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := newNameFilter(tt.include, tt.exclude)
+			require.NoError(t, err)
+			for _, name := range tt.selected {
+				assert.True(t, f.match(name), name)
+			}
+			for _, name := range tt.skipped {
+				assert.False(t, f.match(name), name)
+			}
+		})
+	}
+}
+
+func TestNewDiskCollector_InvalidPattern(t *testing.T) {
+	_, err := NewDiskCollector(DiskOptions{ExcludeMounts: []string{"[/"}})
+	assert.Error(t, err)
+	_, err = NewDiskCollector(DiskOptions{Devices: []string{"[sd"}})
+	assert.Error(t, err)
+}
+
+func TestDiskCollector_Collect(t *testing.T) {
+	c, err := NewDiskCollector(DiskOptions{Mounts: []string{"/"}, ExcludeDevices: []string{"*"}})
+	require.NoError(t, err)
+
+	r := NewRegistry(nil)
+	require.NoError(t, c.Collect(context.Background(), r))
+	require.NoError(t, c.Collect(context.Background(), r))
+
+	for _, m := range r.Snapshot() {
+		// Every device is excluded, only usage of the root filesystem is reported
+		assert.Equal(t, models.Gauge, m.MType, m.ID)
+		assert.Equal(t, models.Labels{"mount": "/"}, m.Labels, m.ID)
+	}
+}
+
+func TestDiskCollector_IOCounters(t *testing.T) {
+	c, err := NewDiskCollector(DiskOptions{Mounts: []string{"/no-such-mount"}})
+	require.NoError(t, err)
+
+	r := NewRegistry(nil)
+	require.NoError(t, c.Collect(context.Background(), r))
+	for _, m := range r.Snapshot() {
+		assert.NotEqual(t, models.Counter, m.MType, "the first poll only sets the baseline")
+	}
+
+	require.NoError(t, c.Collect(context.Background(), r))
+	for _, m := range r.Snapshot() {
+		assert.Equal(t, models.Counter, m.MType, m.ID)
+		assert.GreaterOrEqual(t, *m.Delta, int64(0), m.ID)
+		assert.NotEmpty(t, m.Labels["device"], m.ID)
+	}
+}
@@ -8,6 +8,7 @@
 //   - URL-encoded (text)
 //   - JSON
 //   - Batch updates
+//   - Protobuf over gRPC (Config.Transport = TransportGRPC)
 //
 // * Flexible configuration:
 //   - Collection/reporting intervals
@@ -18,6 +19,7 @@
 //   - Gzip compression
 //   - Retry mechanism
 //   - Worker pools
+//   - On-disk outbox of undelivered metrics, replayed in order (Config.OutboxDir)
 //
 // Usage Example:
 //
@@ -34,7 +36,8 @@
 //
 // Core Components:
 //
-// * Collector - metrics collection (runtime and system)
+// * Collector - metrics collection, registered by name (RegisterCollector)
+// * Registry - aggregation of collected metrics between reports
 // * Sender - metrics reporting
 // * WorkerPool - concurrent request handling
 // * RetryMechanism - failed request retries
@@ -44,11 +47,43 @@
 // Runtime metrics include:
 // * Alloc - current memory allocations
 // * HeapInuse - active heap memory
-// * NumGC - garbage collection cycles
+// * PollCount - counter of polls
+// * NumGC, NumForcedGC, Mallocs, Frees, Lookups, TotalAlloc, PauseTotalNs -
+// counters of the increments since the previous poll
 // * And others (see runtime.MemStats)
+// * GCPauseNs - histogram of GC pauses (from runtime.MemStats.PauseNs)
+//
+// The "runtime_metrics" collector (see RuntimeMetricsCollector) reports all
+// runtime/metrics values as go_* metrics, e.g. go_gc_pauses_seconds and
+// go_sched_latencies_seconds histograms, without stopping the world.
 //
 // System metrics include:
 // * CPUutilization - CPU usage
 // * TotalMemory - total system memory
 // * FreeMemory - available memory
+//
+// Host metrics ("host" collector, see CollectHostMetrics) include:
+// * Load1, Load5, Load15 - load averages
+// * SwapTotal, SwapUsed, SwapFree - swap space
+// * Uptime, BootTime - seconds since boot and boot time
+//
+// Disk metrics ("disk" collector, see DiskCollector) include:
+// * DiskTotal, DiskUsed, DiskFree, DiskInodes* - usage per mount point
+// * DiskReads, DiskWrites, DiskReadBytes, DiskWriteBytes, DiskIOTime - I/O counters per device
+//
+// Network metrics ("network" collector, see NetworkCollector) include:
+// * NetBytesSent, NetBytesRecv, NetPacketsSent, NetPacketsRecv - counters per interface
+// * NetErrorsIn, NetErrorsOut, NetDropsIn, NetDropsOut - counters per interface
+// * TCPConnections - TCP connections by state
+//
+// Process metrics ("process" collector, disabled by default) include:
+// * ProcessCPUPercent, ProcessRSS, ProcessOpenFDs, ProcessThreads
+// * ProcessReadBytes, ProcessWriteBytes - I/O counters
+// See ProcessCollector; processes are selected in the collector options:
+//
+//	"collectors": {"process": {"enabled": true, "options": {"processes": [
+//	    {"name": "nginx", "process": "nginx"},
+//	    {"name": "app", "cmdline": "java .*app\\.jar"},
+//	    {"name": "db", "pid_file": "/run/postgresql/postgres.pid"}
+//	]}}}
 package agent
@@ -0,0 +1,41 @@
+package agent
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// nameFilter selects names, such as mount points or devices, by include and
+// exclude lists of glob patterns (see filepath.Match)
+type nameFilter struct {
+	include []string // Patterns of selected names, all names if empty
+	exclude []string // Patterns of names left out even if included
+}
+
+// newNameFilter creates a filter, returning an error for malformed patterns
+func newNameFilter(include, exclude []string) (nameFilter, error) {
+	for _, pattern := range append(append([]string(nil), include...), exclude...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nameFilter{}, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nameFilter{include: include, exclude: exclude}, nil
+}
+
+// match reports whether the name is selected by the filter
+func (f nameFilter) match(name string) bool {
+	for _, pattern := range f.exclude {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, pattern := range f.include {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
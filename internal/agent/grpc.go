@@ -0,0 +1,201 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/runtime-metrics-course/internal/client"
+	"github.com/runtime-metrics-course/internal/logger"
+	"github.com/runtime-metrics-course/internal/middleware"
+	"github.com/runtime-metrics-course/internal/models"
+	pb "github.com/runtime-metrics-course/internal/proto"
+	"github.com/runtime-metrics-course/internal/resilience"
+	"github.com/runtime-metrics-course/internal/storage"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Transport names for Config.Transport
+const (
+	TransportHTTP = "http" // JSON over HTTP (default)
+	TransportGRPC = "grpc" // Protobuf over gRPC
+)
+
+// grpcTimeout limits a single gRPC call
+const grpcTimeout = 5 * time.Second
+
+// grpcSender delivers metrics to the server over gRPC
+type grpcSender struct {
+	conn   *grpc.ClientConn
+	client pb.MetricsClient
+	realIP string // Local address sent as X-Real-IP metadata
+}
+
+// newGRPCSender creates a sender connected to the gRPC server at address,
+// over TLS when configured. The connection is established lazily on the first call.
+func newGRPCSender(address string) (*grpcSender, error) {
+	creds := insecure.NewCredentials()
+	if tlsClientConfig != nil {
+		creds = credentials.NewTLS(tlsClientConfig)
+	}
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gRPC client: %w", err)
+	}
+	return &grpcSender{conn: conn, client: pb.NewMetricsClient(conn), realIP: client.OutboundIP(address)}, nil
+}
+
+// outgoing attaches the X-Real-IP metadata to the call context
+func (s *grpcSender) outgoing(ctx context.Context) context.Context {
+	if s.realIP == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, middleware.RealIPHeader, s.realIP)
+}
+
+// Close closes the connection to the server
+func (s *grpcSender) Close() error {
+	return s.conn.Close()
+}
+
+// Send stores a batch of metrics with the unary UpdateMetrics call,
+// retrying while the server is unavailable
+func (s *grpcSender) Send(ctx context.Context, metrics []models.MetricJSON) error {
+	req := &pb.UpdateMetricsRequest{Metrics: make([]*pb.Metric, 0, len(metrics))}
+	for _, m := range metrics {
+		req.Metrics = append(req.Metrics, pb.FromModel(m))
+	}
+
+	return resilience.Retry(ctx, func() error {
+		callCtx, cancel := context.WithTimeout(s.outgoing(ctx), grpcTimeout)
+		defer cancel()
+		_, err := s.client.UpdateMetrics(callCtx, req)
+		return grpcError(err)
+	})
+}
+
+// Stream stores metrics with the client-streaming StreamMetrics call
+func (s *grpcSender) Stream(ctx context.Context, metrics []models.MetricJSON) error {
+	stream, err := s.client.StreamMetrics(s.outgoing(ctx))
+	if err != nil {
+		return grpcError(err)
+	}
+	for _, m := range metrics {
+		if err := stream.Send(pb.FromModel(m)); err != nil {
+			// The real error is reported by CloseAndRecv
+			break
+		}
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return grpcError(err)
+	}
+	if int(resp.Accepted) != len(metrics) {
+		return fmt.Errorf("server accepted %d of %d metrics", resp.Accepted, len(metrics))
+	}
+	return nil
+}
+
+// sendJSON sends a JSON encoded batch of metrics, as stored in the outbox
+func (s *grpcSender) sendJSON(data []byte) error {
+	var metrics []models.MetricJSON
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		// A corrupted batch can never be sent
+		logger.Log.Sugar().Errorf("dropping invalid outbox batch: %v", err)
+		return nil
+	}
+	err := s.Send(context.Background(), metrics)
+	if isGRPCRejected(err) {
+		logger.Log.Sugar().Errorf("server rejected outbox batch: %v", err)
+		return nil
+	}
+	return err
+}
+
+// isGRPCRejected reports whether the server rejected a call, so sending it
+// again cannot succeed
+func isGRPCRejected(err error) bool {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.PermissionDenied, codes.Unauthenticated:
+		return true
+	}
+	return false
+}
+
+// grpcError marks errors of an unavailable server as transient for resilience.Retry
+func grpcError(err error) error {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted:
+		return resilience.Transient(err)
+	}
+	return err
+}
+
+// grpcWorker processes metric sending tasks like worker, sending every task
+// as one UpdateMetrics call
+func grpcWorker(ctx context.Context, tasks <-chan Task, limiter *rate.Limiter, sender *grpcSender) {
+	for task := range tasks {
+		if err := limiter.Wait(ctx); err != nil {
+			return
+		}
+
+		if err := replayOutbox(ctx, sender.sendJSON); err != nil {
+			logger.Log.Error(err.Error())
+			retain(task)
+			continue
+		}
+
+		logger.Log.Info("Worker sending metrics over gRPC", zap.Int("count", len(task.Metrics)))
+		if err := sender.Send(ctx, task.Metrics); err != nil {
+			logger.Log.Error(err.Error())
+			if !isGRPCRejected(err) {
+				retain(task)
+			}
+		}
+	}
+}
+
+// SendAllGRPC streams all metrics of the storage to the gRPC server at address.
+//
+// Parameters:
+//   - storage: Storage interface to get metrics from
+//   - address: gRPC server address (host:port)
+//
+// Returns:
+//   - error: if the stream fails or the server rejects metrics
+func SendAllGRPC(storage storage.StorageIface, address string) error {
+	sender, err := newGRPCSender(address)
+	if err != nil {
+		return err
+	}
+	defer sender.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), grpcTimeout)
+	defer cancel()
+
+	metrics, err := storage.GetMetrics(ctx)
+	if err != nil {
+		return err
+	}
+
+	all := make([]models.MetricJSON, 0, len(metrics.Gauges)+len(metrics.Counters))
+	for key, v := range metrics.Gauges {
+		if m, err := models.MarshalMetricToJSON(models.Gauge, key, v); err == nil {
+			all = append(all, *m)
+		}
+	}
+	for key, v := range metrics.Counters {
+		if m, err := models.MarshalMetricToJSON(models.Counter, key, v); err == nil {
+			all = append(all, *m)
+		}
+	}
+	return sender.Stream(ctx, all)
+}
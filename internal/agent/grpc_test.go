@@ -0,0 +1,114 @@
+package agent
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/runtime-metrics-course/internal/middleware"
+	"github.com/runtime-metrics-course/internal/models"
+	pb "github.com/runtime-metrics-course/internal/proto"
+	"github.com/runtime-metrics-course/internal/server"
+	"github.com/runtime-metrics-course/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// startTestGRPCServer serves the metrics service on a random local port
+func startTestGRPCServer(t *testing.T, st storage.StorageIface, opts ...grpc.ServerOption) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := grpc.NewServer(opts...)
+	pb.RegisterMetricsServer(srv, server.NewMetricsServer(st))
+	go srv.Serve(listener)
+	t.Cleanup(srv.Stop)
+
+	return listener.Addr().String()
+}
+
+func TestGRPCSender(t *testing.T) {
+	st := storage.NewMemStorage()
+	sender, err := newGRPCSender(startTestGRPCServer(t, st))
+	require.NoError(t, err)
+	defer sender.Close()
+
+	value := 2.5
+	delta := int64(4)
+	metrics := []models.MetricJSON{
+		{ID: "Alloc", MType: models.Gauge, Value: &value},
+		{ID: "PollCount", MType: models.Counter, Delta: &delta, Labels: models.Labels{"host": "a"}},
+	}
+
+	ctx := context.Background()
+	require.NoError(t, sender.Send(ctx, metrics))
+	require.NoError(t, sender.Stream(ctx, metrics))
+
+	stored, err := st.GetMetrics(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2.5, stored.Gauges["Alloc"])
+	assert.Equal(t, int64(8), stored.Counters[`PollCount{host="a"}`])
+}
+
+func TestGRPCSender_InvalidMetric(t *testing.T) {
+	sender, err := newGRPCSender(startTestGRPCServer(t, storage.NewMemStorage()))
+	require.NoError(t, err)
+	defer sender.Close()
+
+	value := 1.0
+	err = sender.Send(context.Background(), []models.MetricJSON{
+		{ID: "Alloc", MType: models.Gauge, Value: &value, Labels: models.Labels{"bad=name": "x"}},
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestSendAllGRPC(t *testing.T) {
+	ctx := context.Background()
+	source := storage.NewMemStorage()
+	require.NoError(t, source.UpdateGauge(ctx, `CPUutilization{cpu="0"}`, 12))
+	require.NoError(t, source.UpdateCounter(ctx, "PollCount", 5))
+
+	target := storage.NewMemStorage()
+	require.NoError(t, SendAllGRPC(source, startTestGRPCServer(t, target)))
+
+	stored, err := target.GetMetrics(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 12.0, stored.Gauges[`CPUutilization{cpu="0"}`])
+	assert.Equal(t, int64(5), stored.Counters["PollCount"])
+}
+
+func TestGRPCSender_TrustedSubnet(t *testing.T) {
+	tests := []struct {
+		name     string
+		subnet   string
+		wantCode codes.Code
+	}{
+		{name: "Trusted", subnet: "127.0.0.0/8", wantCode: codes.OK},
+		{name: "Untrusted", subnet: "10.0.0.0/8", wantCode: codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subnet, err := middleware.NewTrustedSubnetMiddleware(tt.subnet)
+			require.NoError(t, err)
+
+			address := startTestGRPCServer(t, storage.NewMemStorage(),
+				grpc.UnaryInterceptor(subnet.UnaryInterceptor),
+				grpc.StreamInterceptor(subnet.StreamInterceptor),
+			)
+			sender, err := newGRPCSender(address)
+			require.NoError(t, err)
+			defer sender.Close()
+
+			value := 1.0
+			metrics := []models.MetricJSON{{ID: "Alloc", MType: models.Gauge, Value: &value}}
+			assert.Equal(t, tt.wantCode, status.Code(sender.Send(context.Background(), metrics)))
+			assert.Equal(t, tt.wantCode, status.Code(sender.Stream(context.Background(), metrics)))
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/runtime-metrics-course/internal/models"
+	"github.com/shirou/gopsutil/host"
+	"github.com/shirou/gopsutil/load"
+	"github.com/shirou/gopsutil/mem"
+)
+
+// CollectHostMetrics records host load, swap and uptime gauges:
+//
+//   - Load1, Load5, Load15 - load averages over 1, 5 and 15 minutes
+//   - SwapTotal, SwapUsed, SwapFree - swap space in bytes
+//   - Uptime - seconds since boot
+//   - BootTime - boot time in seconds since the Unix epoch
+//
+// Values that cannot be read are left out and their errors are returned.
+func CollectHostMetrics(ctx context.Context, r *Registry) error {
+	gauge := func(id string, value float64) {
+		r.Record(models.MetricJSON{ID: id, MType: models.Gauge, Value: &value})
+	}
+
+	var errs []error
+	if avg, err := load.AvgWithContext(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to read load average: %w", err))
+	} else {
+		gauge("Load1", avg.Load1)
+		gauge("Load5", avg.Load5)
+		gauge("Load15", avg.Load15)
+	}
+
+	if swap, err := mem.SwapMemoryWithContext(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to read swap memory: %w", err))
+	} else {
+		gauge("SwapTotal", float64(swap.Total))
+		gauge("SwapUsed", float64(swap.Used))
+		gauge("SwapFree", float64(swap.Free))
+	}
+
+	if uptime, err := host.UptimeWithContext(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to read uptime: %w", err))
+	} else {
+		gauge("Uptime", float64(uptime))
+	}
+
+	if bootTime, err := host.BootTimeWithContext(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to read boot time: %w", err))
+	} else {
+		gauge("BootTime", float64(bootTime))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,22 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectHostMetrics(t *testing.T) {
+	r := NewRegistry(nil)
+	require.NoError(t, CollectHostMetrics(context.Background(), r))
+
+	values := gauges(r.Snapshot())
+	for _, id := range []string{"Load1", "Load5", "Load15", "SwapTotal", "SwapUsed", "SwapFree", "Uptime", "BootTime"} {
+		assert.Contains(t, values, id)
+	}
+	assert.Positive(t, values["Uptime"])
+	assert.Positive(t, values["BootTime"])
+	assert.LessOrEqual(t, values["SwapUsed"], values["SwapTotal"])
+}
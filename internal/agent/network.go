@@ -0,0 +1,140 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/runtime-metrics-course/internal/models"
+	"github.com/shirou/gopsutil/net"
+)
+
+// tcpStates are the TCP connection states always reported, so that the
+// gauge of a state drops to zero once its last connection is gone
+var tcpStates = []string{
+	"ESTABLISHED", "SYN_SENT", "SYN_RECV", "FIN_WAIT1", "FIN_WAIT2", "TIME_WAIT",
+	"CLOSE", "CLOSE_WAIT", "LAST_ACK", "LISTEN", "CLOSING",
+}
+
+// NetworkOptions are the options of the network collector
+type NetworkOptions struct {
+	Interfaces        []string `json:"interfaces,omitempty"`         // Glob patterns of interfaces to report, all if empty
+	ExcludeInterfaces []string `json:"exclude_interfaces,omitempty"` // Glob patterns of interfaces left out, e.g. "veth*"
+	DisableTCP        bool     `json:"disable_tcp,omitempty"`        // Do not report TCP connection counts
+}
+
+// NetworkCollector reports counters per network interface, labeled "interface":
+//
+//   - NetBytesSent, NetBytesRecv
+//   - NetPacketsSent, NetPacketsRecv
+//   - NetErrorsIn, NetErrorsOut
+//   - NetDropsIn, NetDropsOut
+//
+// The counters are the increments since the previous poll. The first poll
+// only reads the baseline.
+//
+// TCPConnections gauges count the TCP connections by state, labeled "state".
+type NetworkCollector struct {
+	interfaces nameFilter
+	tcp        bool
+
+	mu       sync.Mutex
+	counters *counterDeltas
+}
+
+func init() {
+	RegisterCollector(CollectorNetwork, true, func(options json.RawMessage) (Collector, error) {
+		var opts NetworkOptions
+		if options != nil {
+			if err := json.Unmarshal(options, &opts); err != nil {
+				return nil, err
+			}
+		}
+		return NewNetworkCollector(opts)
+	})
+}
+
+// NewNetworkCollector creates a network collector.
+// Returns an error if a pattern is malformed.
+func NewNetworkCollector(opts NetworkOptions) (*NetworkCollector, error) {
+	interfaces, err := newNameFilter(opts.Interfaces, opts.ExcludeInterfaces)
+	if err != nil {
+		return nil, fmt.Errorf("interfaces: %w", err)
+	}
+	return &NetworkCollector{interfaces: interfaces, tcp: !opts.DisableTCP, counters: newCounterDeltas()}, nil
+}
+
+// Collect records interface counters and TCP connection counts
+func (c *NetworkCollector) Collect(ctx context.Context, r *Registry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := c.collectInterfaces(ctx, r)
+	if c.tcp {
+		err = errors.Join(err, collectTCPStates(ctx, r))
+	}
+	return err
+}
+
+// collectInterfaces records counter increments per interface since the previous poll
+func (c *NetworkCollector) collectInterfaces(ctx context.Context, r *Registry) error {
+	defer c.counters.next()
+
+	stats, err := net.IOCountersWithContext(ctx, true)
+	if err != nil {
+		return fmt.Errorf("failed to read network counters: %w", err)
+	}
+
+	for _, s := range stats {
+		if !c.interfaces.match(s.Name) {
+			continue
+		}
+
+		labels := models.Labels{"interface": s.Name}
+		for id, value := range map[string]uint64{
+			"NetBytesSent":   s.BytesSent,
+			"NetBytesRecv":   s.BytesRecv,
+			"NetPacketsSent": s.PacketsSent,
+			"NetPacketsRecv": s.PacketsRecv,
+			"NetErrorsIn":    s.Errin,
+			"NetErrorsOut":   s.Errout,
+			"NetDropsIn":     s.Dropin,
+			"NetDropsOut":    s.Dropout,
+		} {
+			if delta, ok := c.counters.delta(s.Name+"/"+id, value); ok {
+				r.Record(models.MetricJSON{ID: id, MType: models.Counter, Delta: &delta, Labels: labels})
+			}
+		}
+	}
+	return nil
+}
+
+// collectTCPStates records the number of TCP connections in every state
+func collectTCPStates(ctx context.Context, r *Registry) error {
+	conns, err := net.ConnectionsWithoutUidsWithContext(ctx, "tcp")
+	if err != nil {
+		return fmt.Errorf("failed to read TCP connections: %w", err)
+	}
+
+	counts := make(map[string]float64, len(tcpStates))
+	for _, state := range tcpStates {
+		counts[state] = 0
+	}
+	for _, conn := range conns {
+		if conn.Status != "" && conn.Status != "NONE" {
+			counts[conn.Status]++
+		}
+	}
+
+	for state, count := range counts {
+		r.Record(models.MetricJSON{
+			ID:     "TCPConnections",
+			MType:  models.Gauge,
+			Value:  &count,
+			Labels: models.Labels{"state": state},
+		})
+	}
+	return nil
+}
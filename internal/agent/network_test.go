@@ -0,0 +1,51 @@
+package agent
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/runtime-metrics-course/internal/models"
+)
+
+func TestNewNetworkCollector_InvalidPattern(t *testing.T) {
+	_, err := NewNetworkCollector(NetworkOptions{Interfaces: []string{"[eth"}})
+	assert.Error(t, err)
+}
+
+func TestNetworkCollector_Interfaces(t *testing.T) {
+	c, err := NewNetworkCollector(NetworkOptions{Interfaces: []string{"lo"}, DisableTCP: true})
+	require.NoError(t, err)
+
+	r := NewRegistry(nil)
+	require.NoError(t, c.Collect(context.Background(), r))
+	assert.Empty(t, r.Snapshot(), "the first poll only reads the baseline")
+
+	require.NoError(t, c.Collect(context.Background(), r))
+	for _, m := range r.Snapshot() {
+		assert.Equal(t, models.Counter, m.MType, m.ID)
+		assert.Equal(t, models.Labels{"interface": "lo"}, m.Labels, m.ID)
+		assert.GreaterOrEqual(t, *m.Delta, int64(0), m.ID)
+	}
+}
+
+func TestNetworkCollector_TCPStates(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	c, err := NewNetworkCollector(NetworkOptions{ExcludeInterfaces: []string{"*"}})
+	require.NoError(t, err)
+
+	r := NewRegistry(nil)
+	require.NoError(t, c.Collect(context.Background(), r))
+
+	values := gauges(r.Snapshot())
+	for _, state := range tcpStates {
+		assert.Contains(t, values, models.SeriesKey("TCPConnections", models.Labels{"state": state}))
+	}
+	assert.GreaterOrEqual(t, values[models.SeriesKey("TCPConnections", models.Labels{"state": "LISTEN"})], 1.0)
+}
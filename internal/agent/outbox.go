@@ -0,0 +1,65 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/runtime-metrics-course/internal/client"
+	"github.com/runtime-metrics-course/internal/logger"
+	"github.com/runtime-metrics-course/internal/models"
+	"github.com/runtime-metrics-course/internal/outbox"
+)
+
+// pending keeps batches that could not be delivered, nil if the outbox is disabled
+var pending *outbox.Outbox
+
+// queueMetrics stores undelivered metrics in the outbox as one JSON batch.
+// Returns false if the outbox is disabled or the batch could not be stored.
+func queueMetrics(metrics []models.MetricJSON) bool {
+	if pending == nil {
+		return false
+	}
+
+	data, err := json.Marshal(metrics)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return false
+	}
+	if err := pending.Append(data); err != nil {
+		logger.Log.Sugar().Errorf("failed to queue %d metrics: %v", len(metrics), err)
+		return false
+	}
+	return true
+}
+
+// retain keeps the metrics of a task that could not be delivered, exactly
+// once: in the outbox if it is enabled, otherwise back in the registry they
+// were taken from, so that counter increments are sent with the next report.
+// Gauges are not restored to the registry, newer values replace them anyway.
+func retain(task Task) {
+	if queueMetrics(task.Metrics) {
+		return
+	}
+	if task.source != nil {
+		task.source.Restore(task.Metrics)
+	}
+}
+
+// replayOutbox sends the queued batches in order with send. It returns the
+// error of the first batch that could not be sent; that batch and the newer
+// ones stay queued. Returns nil immediately if the outbox is disabled or empty.
+func replayOutbox(ctx context.Context, send func(batch []byte) error) error {
+	if pending == nil || pending.Empty() {
+		return nil
+	}
+
+	return pending.Replay(ctx, func(batch []byte) error {
+		err := send(batch)
+		if client.IsRejected(err) {
+			// Replaying a batch the server rejects would block the outbox forever
+			logger.Log.Sugar().Errorf("server rejected outbox batch: %v", err)
+			return nil
+		}
+		return err
+	})
+}
@@ -0,0 +1,226 @@
+package agent
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+
+	"github.com/runtime-metrics-course/internal/client"
+	"github.com/runtime-metrics-course/internal/models"
+	"github.com/runtime-metrics-course/internal/outbox"
+)
+
+func TestSendRequest_Status(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		expectErr bool
+		rejected  bool
+	}{
+		{name: "OK", status: http.StatusOK},
+		{name: "Bad request", status: http.StatusBadRequest, expectErr: true, rejected: true},
+		{name: "Server error", status: http.StatusServiceUnavailable, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer ts.Close()
+
+			err := sendRequest(context.Background(), ts.Client(), ts.URL, []byte(`[]`), "")
+			assert.Equal(t, tt.expectErr, err != nil)
+			assert.Equal(t, tt.rejected, client.IsRejected(err))
+		})
+	}
+}
+
+func TestReplayOutbox_Disabled(t *testing.T) {
+	called := false
+	err := replayOutbox(context.Background(), func([]byte) error {
+		called = true
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.False(t, called)
+}
+
+func TestReplayOutbox_DropsRejected(t *testing.T) {
+	usePending(t)
+	queueMetrics([]models.MetricJSON{gaugeJSON("A", 1)})
+	queueMetrics([]models.MetricJSON{gaugeJSON("B", 2)})
+
+	var sent int
+	err := replayOutbox(context.Background(), func([]byte) error {
+		sent++
+		return &client.StatusError{Code: http.StatusBadRequest}
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, sent)
+	assert.True(t, pending.Empty())
+}
+
+func TestReplayOutbox_KeepsFailed(t *testing.T) {
+	usePending(t)
+	queueMetrics([]models.MetricJSON{gaugeJSON("A", 1)})
+
+	errDown := errors.New("server is down")
+	err := replayOutbox(context.Background(), func([]byte) error { return errDown })
+	assert.ErrorIs(t, err, errDown)
+	assert.False(t, pending.Empty())
+}
+
+func TestWorker_Outbox(t *testing.T) {
+	usePending(t)
+
+	var mu sync.Mutex
+	var received [][]models.MetricJSON
+	down := true
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		gz, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(gz)
+		require.NoError(t, err)
+
+		assert.Equal(t, "/updates/", r.URL.Path)
+		var batch []models.MetricJSON
+		require.NoError(t, json.Unmarshal(body, &batch))
+		received = append(received, batch)
+	}))
+	defer ts.Close()
+
+	prev := cfg
+	cfg = Config{Host: ts.URL}
+	defer func() { cfg = prev }()
+
+	runWorker := func(batches ...[]models.MetricJSON) {
+		tasks := make(chan Task, len(batches))
+		for _, batch := range batches {
+			tasks <- Task{Metrics: batch}
+		}
+		close(tasks)
+		worker(context.Background(), tasks, rate.NewLimiter(rate.Inf, 1))
+	}
+
+	// The server is down: both batches are queued
+	runWorker([]models.MetricJSON{gaugeJSON("A", 1)}, []models.MetricJSON{gaugeJSON("B", 2)})
+	assert.False(t, pending.Empty())
+	assert.Empty(t, received)
+
+	// The server is back: the queue is replayed in order before the new batch
+	mu.Lock()
+	down = false
+	mu.Unlock()
+	runWorker([]models.MetricJSON{gaugeJSON("C", 3), gaugeJSON("D", 4)})
+
+	require.Len(t, received, 3)
+	assert.Equal(t, "A", received[0][0].ID)
+	assert.Equal(t, "B", received[1][0].ID)
+	require.Len(t, received[2], 2)
+	assert.Equal(t, "C", received[2][0].ID)
+	assert.Equal(t, "D", received[2][1].ID)
+	assert.True(t, pending.Empty())
+}
+
+func TestRetain(t *testing.T) {
+	t.Run("Outbox disabled", func(t *testing.T) {
+		r := NewRegistry(nil)
+		r.Record(counterJSON("PollCount", 2))
+		retain(Task{Metrics: r.Snapshot(), source: r})
+
+		assert.Equal(t, []models.MetricJSON{counterJSON("PollCount", 2)}, r.Snapshot())
+	})
+
+	t.Run("Outbox enabled", func(t *testing.T) {
+		usePending(t)
+		r := NewRegistry(nil)
+		r.Record(counterJSON("PollCount", 2))
+		retain(Task{Metrics: r.Snapshot(), source: r})
+
+		assert.False(t, pending.Empty())
+		assert.Empty(t, r.Snapshot(), "queued increments must not be sent twice")
+	})
+}
+
+func TestWorker_RestoresCounters(t *testing.T) {
+	var mu sync.Mutex
+	var received []int64
+	down := true
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		gz, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		var batch []models.MetricJSON
+		require.NoError(t, json.NewDecoder(gz).Decode(&batch))
+		for _, m := range batch {
+			received = append(received, *m.Delta)
+		}
+	}))
+	defer ts.Close()
+
+	prev := cfg
+	cfg = Config{Host: ts.URL}
+	defer func() { cfg = prev }()
+
+	r := NewRegistry(nil)
+	runWorker := func() {
+		tasks := make(chan Task, 1)
+		report(r, tasks)
+		close(tasks)
+		worker(context.Background(), tasks, rate.NewLimiter(rate.Inf, 1))
+	}
+
+	// The server is down: the increments go back to the registry
+	r.Record(counterJSON("PollCount", 1), counterJSON("PollCount", 1))
+	runWorker()
+	assert.Empty(t, received)
+
+	mu.Lock()
+	down = false
+	mu.Unlock()
+	r.Record(counterJSON("PollCount", 1))
+	runWorker()
+	runWorker()
+
+	assert.Equal(t, []int64{3}, received)
+}
+
+// usePending enables the outbox in a temporary directory for the test
+func usePending(t *testing.T) {
+	t.Helper()
+	queue, err := outbox.Open(outbox.Config{Dir: t.TempDir()})
+	require.NoError(t, err)
+	pending = queue
+	t.Cleanup(func() {
+		queue.Close()
+		pending = nil
+	})
+}
+
+func gaugeJSON(id string, value float64) models.MetricJSON {
+	return models.MetricJSON{ID: id, MType: models.Gauge, Value: &value}
+}
@@ -0,0 +1,271 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/runtime-metrics-course/internal/models"
+	"github.com/shirou/gopsutil/process"
+)
+
+// ProcessMatcher selects the processes reported under one name.
+// Exactly one of PIDFile, Process and Cmdline must be set.
+type ProcessMatcher struct {
+	Name    string `json:"name"`               // Value of the "process" label of the metrics
+	PIDFile string `json:"pid_file,omitempty"` // File containing the PID of the process
+	Process string `json:"process,omitempty"`  // Process name to match exactly (e.g. "nginx")
+	Cmdline string `json:"cmdline,omitempty"`  // Regular expression matched against the command line
+}
+
+// ProcessOptions are the options of the process collector
+type ProcessOptions struct {
+	Processes []ProcessMatcher `json:"processes"`
+}
+
+// processMatcher is a validated ProcessMatcher
+type processMatcher struct {
+	ProcessMatcher
+	cmdline *regexp.Regexp
+}
+
+// ProcessCollector reports resource usage of the processes selected by its
+// matchers, tagged with the matcher name in the "process" label:
+//
+//   - ProcessCount - number of matched processes
+//   - ProcessCPUPercent - CPU usage since the previous poll, 100 per core
+//   - ProcessRSS - resident memory in bytes
+//   - ProcessOpenFDs - open file descriptors
+//   - ProcessThreads - threads
+//   - ProcessReadBytes, ProcessWriteBytes - counters of storage I/O bytes
+//
+// When a matcher selects several processes (e.g. worker processes), their
+// values are summed.
+type ProcessCollector struct {
+	matchers []processMatcher
+
+	mu    sync.Mutex
+	procs map[int32]*process.Process // Processes seen in the previous poll, keeping CPU times between polls
+	io    *counterDeltas
+}
+
+func init() {
+	RegisterCollector(CollectorProcess, false, func(options json.RawMessage) (Collector, error) {
+		var opts ProcessOptions
+		if options != nil {
+			if err := json.Unmarshal(options, &opts); err != nil {
+				return nil, err
+			}
+		}
+		return NewProcessCollector(opts)
+	})
+}
+
+// NewProcessCollector creates a process collector.
+// Returns an error if no processes are configured or a matcher is invalid.
+func NewProcessCollector(opts ProcessOptions) (*ProcessCollector, error) {
+	if len(opts.Processes) == 0 {
+		return nil, errors.New("no processes configured")
+	}
+
+	c := &ProcessCollector{
+		procs: make(map[int32]*process.Process),
+		io:    newCounterDeltas(),
+	}
+	names := make(map[string]struct{}, len(opts.Processes))
+	for _, m := range opts.Processes {
+		if m.Name == "" {
+			return nil, errors.New("process name is required")
+		}
+		if _, ok := names[m.Name]; ok {
+			return nil, fmt.Errorf("duplicate process name %q", m.Name)
+		}
+		names[m.Name] = struct{}{}
+
+		set := 0
+		for _, s := range []string{m.PIDFile, m.Process, m.Cmdline} {
+			if s != "" {
+				set++
+			}
+		}
+		if set != 1 {
+			return nil, fmt.Errorf("process %s: exactly one of pid_file, process and cmdline is required", m.Name)
+		}
+
+		matcher := processMatcher{ProcessMatcher: m}
+		if m.Cmdline != "" {
+			re, err := regexp.Compile(m.Cmdline)
+			if err != nil {
+				return nil, fmt.Errorf("process %s: invalid cmdline pattern: %w", m.Name, err)
+			}
+			matcher.cmdline = re
+		}
+		c.matchers = append(c.matchers, matcher)
+	}
+	return c, nil
+}
+
+// Collect records the metrics of every matcher
+func (c *ProcessCollector) Collect(ctx context.Context, r *Registry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Processes of this poll; the ones that are gone are forgotten afterwards
+	current := make(map[int32]*process.Process)
+	defer func() {
+		c.procs = current
+		c.io.next()
+	}()
+
+	var all []*process.Process
+	var errs []error
+	for _, m := range c.matchers {
+		if m.PIDFile == "" && all == nil {
+			var err error
+			if all, err = c.processes(ctx, current); err != nil {
+				return fmt.Errorf("failed to list processes: %w", err)
+			}
+		}
+
+		procs, err := c.match(ctx, m, all, current)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("process %s: %w", m.Name, err))
+		}
+		c.record(ctx, r, m.Name, procs)
+	}
+	return errors.Join(errs...)
+}
+
+// process returns the process with the given PID, reusing the one of the
+// previous poll to keep its CPU times
+func (c *ProcessCollector) process(pid int32, current map[int32]*process.Process) *process.Process {
+	p, ok := current[pid]
+	if !ok {
+		if p, ok = c.procs[pid]; !ok {
+			p = &process.Process{Pid: pid}
+		}
+		current[pid] = p
+	}
+	return p
+}
+
+// processes returns all running processes
+func (c *ProcessCollector) processes(ctx context.Context, current map[int32]*process.Process) ([]*process.Process, error) {
+	pids, err := process.PidsWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	procs := make([]*process.Process, 0, len(pids))
+	for _, pid := range pids {
+		procs = append(procs, c.process(pid, current))
+	}
+	return procs, nil
+}
+
+// match returns the processes selected by the matcher
+func (c *ProcessCollector) match(ctx context.Context, m processMatcher, all []*process.Process, current map[int32]*process.Process) ([]*process.Process, error) {
+	if m.PIDFile != "" {
+		pid, err := readPIDFile(m.PIDFile)
+		if err != nil {
+			return nil, err
+		}
+		exists, err := process.PidExistsWithContext(ctx, pid)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("process %d from %s is not running", pid, m.PIDFile)
+		}
+		return []*process.Process{c.process(pid, current)}, nil
+	}
+
+	var matched []*process.Process
+	for _, p := range all {
+		if m.Process != "" {
+			// Processes may exit while being listed
+			if name, err := p.NameWithContext(ctx); err == nil && name == m.Process {
+				matched = append(matched, p)
+			}
+			continue
+		}
+		if cmdline, err := p.CmdlineWithContext(ctx); err == nil && m.cmdline.MatchString(cmdline) {
+			matched = append(matched, p)
+		}
+	}
+	return matched, nil
+}
+
+// record sums the resource usage of the processes and records it under name.
+// Values that cannot be read, e.g. the file descriptors of another user's
+// process, are left out.
+func (c *ProcessCollector) record(ctx context.Context, r *Registry, name string, procs []*process.Process) {
+	labels := models.Labels{"process": name}
+	gauge := func(id string, value float64) {
+		r.Record(models.MetricJSON{ID: id, MType: models.Gauge, Value: &value, Labels: labels})
+	}
+	counter := func(id string, delta int64) {
+		r.Record(models.MetricJSON{ID: id, MType: models.Counter, Delta: &delta, Labels: labels})
+	}
+
+	var cpuPercent, rss, fds, threads float64
+	var readBytes, writeBytes int64
+	var hasIO bool
+	for _, p := range procs {
+		if percent, err := p.PercentWithContext(ctx, 0); err == nil {
+			cpuPercent += percent
+		}
+		if mem, err := p.MemoryInfoWithContext(ctx); err == nil {
+			rss += float64(mem.RSS)
+		}
+		if n, err := p.NumFDsWithContext(ctx); err == nil {
+			fds += float64(n)
+		}
+		if n, err := p.NumThreadsWithContext(ctx); err == nil {
+			threads += float64(n)
+		}
+		if io, err := p.IOCountersWithContext(ctx); err == nil {
+			key := name + "/" + strconv.Itoa(int(p.Pid))
+			if delta, ok := c.io.delta(key+"/read", io.ReadBytes); ok {
+				readBytes += delta
+				hasIO = true
+			}
+			if delta, ok := c.io.delta(key+"/write", io.WriteBytes); ok {
+				writeBytes += delta
+				hasIO = true
+			}
+		}
+	}
+
+	gauge("ProcessCount", float64(len(procs)))
+	if len(procs) == 0 {
+		return
+	}
+	gauge("ProcessCPUPercent", cpuPercent)
+	gauge("ProcessRSS", rss)
+	gauge("ProcessOpenFDs", fds)
+	gauge("ProcessThreads", threads)
+	if hasIO {
+		counter("ProcessReadBytes", readBytes)
+		counter("ProcessWriteBytes", writeBytes)
+	}
+}
+
+// readPIDFile reads the process ID from a PID file
+func readPIDFile(path string) (int32, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid PID file %s: %w", path, err)
+	}
+	return int32(pid), nil
+}
@@ -0,0 +1,158 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/runtime-metrics-course/internal/models"
+	"github.com/shirou/gopsutil/process"
+)
+
+func TestNewProcessCollector(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      ProcessOptions
+		expectErr bool
+	}{
+		{
+			name: "Valid matchers",
+			opts: ProcessOptions{Processes: []ProcessMatcher{
+				{Name: "nginx", Process: "nginx"},
+				{Name: "app", Cmdline: `java .*app\.jar`},
+				{Name: "db", PIDFile: "/run/postgres.pid"},
+			}},
+		},
+		{name: "No processes", expectErr: true},
+		{
+			name:      "Missing name",
+			opts:      ProcessOptions{Processes: []ProcessMatcher{{Process: "nginx"}}},
+			expectErr: true,
+		},
+		{
+			name: "Duplicate name",
+			opts: ProcessOptions{Processes: []ProcessMatcher{
+				{Name: "web", Process: "nginx"},
+				{Name: "web", Process: "apache2"},
+			}},
+			expectErr: true,
+		},
+		{
+			name:      "No selector",
+			opts:      ProcessOptions{Processes: []ProcessMatcher{{Name: "web"}}},
+			expectErr: true,
+		},
+		{
+			name:      "Several selectors",
+			opts:      ProcessOptions{Processes: []ProcessMatcher{{Name: "web", Process: "nginx", PIDFile: "/run/nginx.pid"}}},
+			expectErr: true,
+		},
+		{
+			name:      "Invalid cmdline pattern",
+			opts:      ProcessOptions{Processes: []ProcessMatcher{{Name: "web", Cmdline: "("}}},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewProcessCollector(tt.opts)
+			assert.Equal(t, tt.expectErr, err != nil, "error: %v", err)
+		})
+	}
+}
+
+// gauges returns the gauge values of the snapshot by series key
+func gauges(snapshot []models.MetricJSON) map[string]float64 {
+	values := make(map[string]float64)
+	for _, m := range snapshot {
+		if m.MType == models.Gauge {
+			values[models.SeriesKey(m.ID, m.Labels)] = *m.Value
+		}
+	}
+	return values
+}
+
+func TestProcessCollector_Collect(t *testing.T) {
+	self, err := process.NewProcess(int32(os.Getpid()))
+	require.NoError(t, err)
+	name, err := self.Name()
+	require.NoError(t, err)
+
+	pidFile := filepath.Join(t.TempDir(), "test.pid")
+	require.NoError(t, os.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o644))
+
+	c, err := NewProcessCollector(ProcessOptions{Processes: []ProcessMatcher{
+		{Name: "pidfile", PIDFile: pidFile},
+		{Name: "name", Process: name},
+		{Name: "missing", Process: "no-such-process-name"},
+	}})
+	require.NoError(t, err)
+
+	r := NewRegistry(nil)
+	require.NoError(t, c.Collect(context.Background(), r))
+	values := gauges(r.Snapshot())
+
+	for _, matcher := range []string{"pidfile", "name"} {
+		labels := models.Labels{"process": matcher}
+		assert.GreaterOrEqual(t, values[models.SeriesKey("ProcessCount", labels)], 1.0, matcher)
+		assert.Positive(t, values[models.SeriesKey("ProcessRSS", labels)], matcher)
+		assert.Positive(t, values[models.SeriesKey("ProcessThreads", labels)], matcher)
+		assert.Positive(t, values[models.SeriesKey("ProcessOpenFDs", labels)], matcher)
+	}
+	assert.Equal(t, 0.0, values[models.SeriesKey("ProcessCount", models.Labels{"process": "missing"})])
+	_, ok := values[models.SeriesKey("ProcessRSS", models.Labels{"process": "missing"})]
+	assert.False(t, ok)
+}
+
+func TestProcessCollector_MissingPIDFile(t *testing.T) {
+	c, err := NewProcessCollector(ProcessOptions{Processes: []ProcessMatcher{
+		{Name: "gone", PIDFile: filepath.Join(t.TempDir(), "gone.pid")},
+	}})
+	require.NoError(t, err)
+
+	r := NewRegistry(nil)
+	assert.Error(t, c.Collect(context.Background(), r))
+	assert.Equal(t, map[string]float64{`ProcessCount{process="gone"}`: 0}, gauges(r.Snapshot()))
+}
+
+func TestCounterDeltas(t *testing.T) {
+	d := newCounterDeltas()
+
+	_, ok := d.delta("a", 10)
+	assert.False(t, ok, "first reading sets the baseline")
+	d.next()
+
+	delta, ok := d.delta("a", 15)
+	assert.True(t, ok)
+	assert.Equal(t, int64(5), delta)
+	d.next()
+
+	delta, ok = d.delta("a", 3)
+	assert.True(t, ok)
+	assert.Equal(t, int64(3), delta, "counter reset")
+	d.next()
+
+	// Not read in a poll, forgotten
+	d.next()
+	_, ok = d.delta("a", 20)
+	assert.False(t, ok)
+}
+
+func TestCounterDeltas_FromZero(t *testing.T) {
+	d := newCounterDeltasFromZero()
+
+	delta, ok := d.delta("a", 10)
+	assert.True(t, ok)
+	assert.Equal(t, int64(10), delta, "first reading counts from zero")
+	d.next()
+
+	delta, ok = d.delta("a", 15)
+	assert.True(t, ok)
+	assert.Equal(t, int64(5), delta)
+}
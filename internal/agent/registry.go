@@ -0,0 +1,82 @@
+package agent
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/runtime-metrics-course/internal/models"
+)
+
+// Registry aggregates collected metrics between reports.
+//
+// Gauges keep the latest recorded value, counter deltas are summed, histogram
+// bucket counts are added and summary observations are appended. A snapshot
+// resets the accumulated counters, histograms and summaries, while gauges keep
+// their latest value until it is overwritten.
+//
+// Registry is safe for concurrent use.
+type Registry struct {
+	labels models.Labels // Labels added to every recorded metric
+
+	mu      sync.Mutex
+	metrics map[string]models.MetricJSON // Aggregated metrics by type and series key
+}
+
+// NewRegistry creates an empty registry that adds labels to every recorded
+// metric. Labels already set on a metric take precedence.
+func NewRegistry(labels models.Labels) *Registry {
+	return &Registry{labels: labels, metrics: make(map[string]models.MetricJSON)}
+}
+
+// Record adds collected metrics to the registry
+func (r *Registry) Record(metrics ...models.MetricJSON) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, m := range metrics {
+		m = withLabels(m, r.labels)
+		key := m.MType + "/" + models.SeriesKey(m.ID, m.Labels)
+		prev, ok := r.metrics[key]
+		if !ok {
+			r.metrics[key] = models.CopyMetric(m)
+			continue
+		}
+		r.metrics[key] = models.MergeMetric(prev, m)
+	}
+}
+
+// Snapshot returns the aggregated metrics sorted by type and series key and
+// resets the accumulated counters, histograms and summaries
+func (r *Registry) Snapshot() []models.MetricJSON {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := make([]string, 0, len(r.metrics))
+	for key := range r.metrics {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	snapshot := make([]models.MetricJSON, 0, len(keys))
+	for _, key := range keys {
+		m := r.metrics[key]
+		snapshot = append(snapshot, m)
+		if m.MType != models.Gauge {
+			delete(r.metrics, key)
+		}
+	}
+	return snapshot
+}
+
+// Restore adds back counters, histograms and summaries of a snapshot that
+// could not be delivered, so that they are sent with the next snapshot.
+// Gauges are skipped: the registry keeps their latest value anyway.
+func (r *Registry) Restore(metrics []models.MetricJSON) {
+	restored := make([]models.MetricJSON, 0, len(metrics))
+	for _, m := range metrics {
+		if m.MType != models.Gauge {
+			restored = append(restored, m)
+		}
+	}
+	r.Record(restored...)
+}
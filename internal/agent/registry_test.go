@@ -0,0 +1,146 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/runtime-metrics-course/internal/models"
+)
+
+func counterJSON(id string, delta int64) models.MetricJSON {
+	return models.MetricJSON{ID: id, MType: models.Counter, Delta: &delta}
+}
+
+func histogramJSON(buckets []float64, counts []uint64, sum float64) models.MetricJSON {
+	return models.MetricJSON{ID: "GCPauseNs", MType: models.Histogram, Buckets: buckets, Counts: counts, Sum: &sum}
+}
+
+func TestRegistry_Record(t *testing.T) {
+	tests := []struct {
+		name     string
+		recorded []models.MetricJSON
+		expected []models.MetricJSON
+	}{
+		{
+			name:     "Gauge keeps the latest value",
+			recorded: []models.MetricJSON{gaugeJSON("Alloc", 1), gaugeJSON("Alloc", 2)},
+			expected: []models.MetricJSON{gaugeJSON("Alloc", 2)},
+		},
+		{
+			name:     "Counter deltas are summed",
+			recorded: []models.MetricJSON{counterJSON("PollCount", 1), counterJSON("PollCount", 2)},
+			expected: []models.MetricJSON{counterJSON("PollCount", 3)},
+		},
+		{
+			name: "Labels separate series",
+			recorded: []models.MetricJSON{
+				{ID: "CPUutilization", MType: models.Gauge, Value: ptr(1.0), Labels: models.Labels{"cpu": "0"}},
+				{ID: "CPUutilization", MType: models.Gauge, Value: ptr(2.0), Labels: models.Labels{"cpu": "1"}},
+			},
+			expected: []models.MetricJSON{
+				{ID: "CPUutilization", MType: models.Gauge, Value: ptr(1.0), Labels: models.Labels{"cpu": "0"}},
+				{ID: "CPUutilization", MType: models.Gauge, Value: ptr(2.0), Labels: models.Labels{"cpu": "1"}},
+			},
+		},
+		{
+			name: "Histogram counts are added",
+			recorded: []models.MetricJSON{
+				histogramJSON([]float64{10}, []uint64{1, 0}, 5),
+				histogramJSON([]float64{10}, []uint64{2, 1}, 25),
+			},
+			expected: []models.MetricJSON{histogramJSON([]float64{10}, []uint64{3, 1}, 30)},
+		},
+		{
+			name: "Histogram with new buckets replaces the old one",
+			recorded: []models.MetricJSON{
+				histogramJSON([]float64{10}, []uint64{1, 0}, 5),
+				histogramJSON([]float64{20}, []uint64{0, 1}, 25),
+			},
+			expected: []models.MetricJSON{histogramJSON([]float64{20}, []uint64{0, 1}, 25)},
+		},
+		{
+			name: "Summary observations are appended",
+			recorded: []models.MetricJSON{
+				{ID: "Latency", MType: models.Summary, Observations: []float64{1}},
+				{ID: "Latency", MType: models.Summary, Observations: []float64{2, 3}},
+			},
+			expected: []models.MetricJSON{{ID: "Latency", MType: models.Summary, Observations: []float64{1, 2, 3}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry(nil)
+			r.Record(tt.recorded...)
+			assert.Equal(t, tt.expected, r.Snapshot())
+		})
+	}
+}
+
+func TestRegistry_Snapshot(t *testing.T) {
+	r := NewRegistry(nil)
+	r.Record(gaugeJSON("Alloc", 1), counterJSON("PollCount", 1))
+
+	first := r.Snapshot()
+	require.Len(t, first, 2)
+
+	// Counters restart from zero, gauges keep their latest value
+	assert.Equal(t, []models.MetricJSON{gaugeJSON("Alloc", 1)}, r.Snapshot())
+
+	r.Record(counterJSON("PollCount", 5))
+	assert.Equal(t, []models.MetricJSON{counterJSON("PollCount", 5), gaugeJSON("Alloc", 1)}, r.Snapshot())
+
+	// A snapshot is not changed by later records
+	assert.Equal(t, int64(1), *first[0].Delta)
+}
+
+func TestRegistry_DoesNotAliasRecorded(t *testing.T) {
+	r := NewRegistry(nil)
+	counts := []uint64{1, 0}
+	r.Record(histogramJSON([]float64{10}, counts, 1))
+	counts[0] = 100
+
+	snapshot := r.Snapshot()
+	require.Len(t, snapshot, 1)
+	assert.Equal(t, []uint64{1, 0}, snapshot[0].Counts)
+}
+
+func TestRegistry_Restore(t *testing.T) {
+	r := NewRegistry(nil)
+	r.Record(gaugeJSON("Alloc", 1), counterJSON("PollCount", 2))
+	failed := r.Snapshot()
+
+	// Collected while the failed snapshot was being sent
+	r.Record(gaugeJSON("Alloc", 5), counterJSON("PollCount", 1))
+	r.Restore(failed)
+
+	assert.Equal(t, []models.MetricJSON{counterJSON("PollCount", 3), gaugeJSON("Alloc", 5)}, r.Snapshot())
+}
+
+func TestReport(t *testing.T) {
+	r := NewRegistry(nil)
+	tasks := make(chan Task, 1)
+
+	// Nothing collected, nothing to send
+	report(r, tasks)
+	assert.Empty(t, tasks)
+
+	r.Record(counterJSON("PollCount", 1))
+	report(r, tasks)
+	require.Len(t, tasks, 1)
+
+	// The previous report is still queued: the counter keeps aggregating
+	r.Record(counterJSON("PollCount", 2))
+	report(r, tasks)
+	assert.Len(t, tasks, 1)
+
+	assert.Equal(t, []models.MetricJSON{counterJSON("PollCount", 1)}, (<-tasks).Metrics)
+	report(r, tasks)
+	assert.Equal(t, []models.MetricJSON{counterJSON("PollCount", 2)}, (<-tasks).Metrics)
+}
+
+func ptr(v float64) *float64 {
+	return &v
+}
@@ -0,0 +1,154 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"runtime/metrics"
+	"strings"
+	"sync"
+
+	"github.com/runtime-metrics-course/internal/models"
+)
+
+// RuntimeMetricsCollector reports every metric supported by the runtime/metrics
+// package of the running Go version. Unlike CollectRuntimeMetrics it does not
+// stop the world.
+//
+// Metric names are derived from the runtime names with a "go_" prefix, e.g.
+// /gc/heap/allocs:bytes becomes go_gc_heap_allocs_bytes. Values map to:
+//
+//   - cumulative integer metrics - counters of the increments between polls
+//   - other integer and all float metrics - gauges
+//   - Float64Histogram metrics, e.g. /gc/pauses:seconds and
+//     /sched/latencies:seconds - histograms of the observations between polls
+//
+// The runtime counts from the start of the agent process, so the first poll
+// reports everything counted so far. Histograms have no sum, as the runtime
+// only reports bucket counts.
+type RuntimeMetricsCollector struct {
+	mu         sync.Mutex
+	samples    []metrics.Sample
+	cumulative []bool
+	counters   *counterDeltas
+	histograms map[string][]uint64 // Bucket counts of the previous poll
+}
+
+func init() {
+	RegisterCollector(CollectorRuntimeMetrics, true, func(json.RawMessage) (Collector, error) {
+		return NewRuntimeMetricsCollector(), nil
+	})
+}
+
+// NewRuntimeMetricsCollector creates a collector of all supported runtime metrics
+func NewRuntimeMetricsCollector() *RuntimeMetricsCollector {
+	descs := metrics.All()
+	c := &RuntimeMetricsCollector{
+		samples:    make([]metrics.Sample, len(descs)),
+		cumulative: make([]bool, len(descs)),
+		counters:   newCounterDeltasFromZero(),
+		histograms: make(map[string][]uint64),
+	}
+	for i, desc := range descs {
+		c.samples[i].Name = desc.Name
+		c.cumulative[i] = desc.Cumulative
+	}
+	return c
+}
+
+// Collect reads all runtime metrics and records them
+func (c *RuntimeMetricsCollector) Collect(_ context.Context, r *Registry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer c.counters.next()
+
+	metrics.Read(c.samples)
+	for i, sample := range c.samples {
+		id := runtimeMetricName(sample.Name)
+		switch sample.Value.Kind() {
+		case metrics.KindUint64:
+			value := sample.Value.Uint64()
+			if !c.cumulative[i] {
+				gauge := float64(value)
+				r.Record(models.MetricJSON{ID: id, MType: models.Gauge, Value: &gauge})
+				continue
+			}
+			if delta, ok := c.counters.delta(id, value); ok {
+				r.Record(models.MetricJSON{ID: id, MType: models.Counter, Delta: &delta})
+			}
+		case metrics.KindFloat64:
+			value := sample.Value.Float64()
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				continue
+			}
+			r.Record(models.MetricJSON{ID: id, MType: models.Gauge, Value: &value})
+		case metrics.KindFloat64Histogram:
+			if metric, ok := c.histogram(id, sample.Value.Float64Histogram()); ok {
+				r.Record(metric)
+			}
+		}
+	}
+	return nil
+}
+
+// histogram converts a cumulative runtime histogram into a histogram of the
+// observations since the previous poll. Returns false if there were no new
+// observations or the buckets cannot be represented.
+//
+// Runtime buckets are [Buckets[i], Buckets[i+1]) intervals with the outer
+// boundaries possibly infinite; the inner boundaries become the upper bounds
+// and the last runtime bucket becomes the +Inf bucket.
+func (c *RuntimeMetricsCollector) histogram(id string, h *metrics.Float64Histogram) (models.MetricJSON, bool) {
+	if len(h.Counts) == 0 {
+		return models.MetricJSON{}, false
+	}
+	bounds := h.Buckets[1:len(h.Counts)]
+	for _, b := range bounds {
+		if math.IsInf(b, 0) || math.IsNaN(b) {
+			return models.MetricJSON{}, false
+		}
+	}
+
+	prev := c.histograms[id]
+	c.histograms[id] = append(prev[:0:0], h.Counts...)
+	if len(prev) != len(h.Counts) {
+		// First poll: everything observed since the start of the process
+		prev = make([]uint64, len(h.Counts))
+	}
+
+	counts := make([]uint64, len(h.Counts))
+	var total uint64
+	for i, count := range h.Counts {
+		if count >= prev[i] {
+			counts[i] = count - prev[i]
+		}
+		total += counts[i]
+	}
+	if total == 0 {
+		return models.MetricJSON{}, false
+	}
+
+	return models.MetricJSON{
+		ID:      id,
+		MType:   models.Histogram,
+		Buckets: append([]float64(nil), bounds...),
+		Counts:  counts,
+		Count:   &total,
+	}, true
+}
+
+// runtimeMetricName converts a runtime/metrics name into a metric name,
+// e.g. /sched/latencies:seconds into go_sched_latencies_seconds
+func runtimeMetricName(name string) string {
+	var b strings.Builder
+	b.WriteString("go")
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"runtime/metrics"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/runtime-metrics-course/internal/models"
+)
+
+func TestRuntimeMetricName(t *testing.T) {
+	tests := map[string]string{
+		"/gc/heap/allocs:bytes":                   "go_gc_heap_allocs_bytes",
+		"/sched/latencies:seconds":                "go_sched_latencies_seconds",
+		"/gc/heap/allocs-by-size:bytes":           "go_gc_heap_allocs_by_size_bytes",
+		"/cpu/classes/gc/mark/assist:cpu-seconds": "go_cpu_classes_gc_mark_assist_cpu_seconds",
+	}
+	for name, expected := range tests {
+		assert.Equal(t, expected, runtimeMetricName(name))
+	}
+}
+
+func TestRuntimeMetricsCollector_Collect(t *testing.T) {
+	c := NewRuntimeMetricsCollector()
+	r := NewRegistry(nil)
+
+	require.NoError(t, c.Collect(context.Background(), r))
+	first := r.Snapshot()
+	assert.Contains(t, gauges(first), "go_sched_goroutines_goroutines")
+	for _, m := range first {
+		if m.ID == "go_gc_heap_allocs_bytes" {
+			assert.Equal(t, models.Counter, m.MType)
+			assert.Positive(t, *m.Delta, "allocations since the start of the process")
+		}
+	}
+
+	// Allocate and collect garbage, so that cumulative metrics change
+	for i := 0; i < 100; i++ {
+		_ = make([]byte, 1<<16)
+	}
+	runtime.GC()
+
+	require.NoError(t, c.Collect(context.Background(), r))
+	byID := make(map[string]models.MetricJSON)
+	for _, m := range r.Snapshot() {
+		byID[m.ID] = m
+	}
+
+	allocs := byID["go_gc_heap_allocs_bytes"]
+	assert.Equal(t, models.Counter, allocs.MType)
+	require.NotNil(t, allocs.Delta)
+	assert.Positive(t, *allocs.Delta)
+
+	pauses, ok := byID["go_gc_pauses_seconds"]
+	if !ok {
+		// Renamed to /sched/pauses/total/gc:seconds in newer Go versions
+		pauses, ok = byID["go_sched_pauses_total_gc_seconds"]
+	}
+	require.True(t, ok, "GC pause histogram")
+	assert.Equal(t, models.Histogram, pauses.MType)
+	assert.NoError(t, models.ValidateHistogram(pauses.Buckets, pauses.Counts))
+	require.NotNil(t, pauses.Count)
+	assert.Positive(t, *pauses.Count)
+}
+
+func TestRuntimeMetricsCollector_Histogram(t *testing.T) {
+	c := NewRuntimeMetricsCollector()
+	h := &metrics.Float64Histogram{
+		Buckets: []float64{math.Inf(-1), 0, 1, math.Inf(1)},
+		Counts:  []uint64{0, 2, 1},
+	}
+
+	metric, ok := c.histogram("h", h)
+	require.True(t, ok, "the first poll reports everything observed so far")
+	assert.Equal(t, []uint64{0, 2, 1}, metric.Counts)
+
+	_, ok = c.histogram("h", h)
+	assert.False(t, ok, "no new observations")
+
+	h.Counts = []uint64{1, 5, 1}
+	metric, ok = c.histogram("h", h)
+	require.True(t, ok)
+	assert.Equal(t, []float64{0, 1}, metric.Buckets)
+	assert.Equal(t, []uint64{1, 3, 0}, metric.Counts)
+	assert.Equal(t, uint64(4), *metric.Count)
+}
@@ -1,10 +1,7 @@
 package agent
 
 import (
-	"bytes"
 	"context"
-	"crypto/rand"
-	"crypto/rsa"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -12,9 +9,8 @@ import (
 	"path"
 	"time"
 
-	"github.com/runtime-metrics-course/internal/compress"
+	"github.com/runtime-metrics-course/internal/client"
 	"github.com/runtime-metrics-course/internal/logger"
-	"github.com/runtime-metrics-course/internal/middleware"
 	"github.com/runtime-metrics-course/internal/models"
 	"github.com/runtime-metrics-course/internal/resilience"
 	"github.com/runtime-metrics-course/internal/storage"
@@ -32,40 +28,103 @@ const (
 // Parameters:
 //   - rateLimit: Maximum number of requests per second
 //   - tasks: Channel receiving tasks to process
-func startWorkerPool(ctx context.Context, rateLimit int, tasks <-chan Task) {
+//   - grpc: gRPC sender, nil to send over HTTP
+func startWorkerPool(ctx context.Context, rateLimit int, tasks <-chan Task, grpc *grpcSender) {
 	limiter := rate.NewLimiter(rate.Limit(rateLimit), 1)
+	if grpc != nil {
+		go grpcWorker(ctx, tasks, limiter, grpc)
+		return
+	}
 	go worker(ctx, tasks, limiter)
 }
 
-// worker processes metric sending tasks from the channel with rate limiting.
+// report passes a snapshot of the registry to the workers. The report is
+// skipped while the previous ones are still waiting for a worker; the
+// registry keeps aggregating until the next report.
+func report(r *Registry, tasks chan<- Task) {
+	if len(tasks) == cap(tasks) {
+		logger.Log.Warn("Previous report is still being sent, skipping")
+		return
+	}
+
+	metrics := r.Snapshot()
+	if len(metrics) == 0 {
+		return
+	}
+	tasks <- Task{Metrics: metrics, source: r}
+}
+
+// worker sends every task as one batch to the /updates/ endpoint with rate
+// limiting. Undelivered batches are kept in the outbox and replayed first.
 //
 // Parameters:
 //   - tasks: Channel to receive tasks from
 //   - limiter: Rate limiter controlling request frequency
 func worker(ctx context.Context, tasks <-chan Task, limiter *rate.Limiter) {
-	client := &http.Client{Timeout: 5 * time.Second}
+	httpClient := newHTTPClient()
+	updatesURL, err := url.JoinPath(cfg.Host, "/updates/")
+	if err != nil {
+		logger.Log.Error(err.Error())
+		return
+	}
+	sendBatch := func(batch []byte) error {
+		return sendRequest(ctx, httpClient, updatesURL, batch, cfg.SecretKey)
+	}
+
 	for task := range tasks {
 		// Wait for rate limiter allowance
 		if err := limiter.Wait(ctx); err != nil {
 			return
 		}
 
-		data, _ := json.Marshal(task.Metric)
-		logger.Log.Info("Worker sending metric", zap.String("metric", string(data)))
-
-		baseURL, err := url.Parse(cfg.Host)
-		if err != nil {
+		if err := replayOutbox(ctx, sendBatch); err != nil {
 			logger.Log.Error(err.Error())
+			retain(task)
 			continue
 		}
-		baseURL.Path += "/update/"
 
-		err = sendRequest(ctx, client, baseURL.String(), data, cfg.SecretKey)
+		data, err := json.Marshal(task.Metrics)
 		if err != nil {
 			logger.Log.Error(err.Error())
 			continue
 		}
+		logger.Log.Info("Worker sending metrics", zap.Int("count", len(task.Metrics)))
+
+		if err := sendBatch(data); err != nil {
+			logger.Log.Error(err.Error())
+			if !client.IsRejected(err) {
+				retain(task)
+			}
+		}
+	}
+}
+
+// newHTTPClient creates a client for requests to the server, using TLS
+// with the agent's client certificate when configured
+func newHTTPClient() *http.Client {
+	client := &http.Client{Timeout: 5 * time.Second}
+	if tlsClientConfig != nil {
+		client.Transport = &http.Transport{TLSClientConfig: tlsClientConfig}
 	}
+	return client
+}
+
+// withLabels returns a copy of the metric with the given labels added.
+// Labels already set on the metric take precedence.
+func withLabels(metric models.MetricJSON, labels models.Labels) models.MetricJSON {
+	if len(labels) == 0 {
+		return metric
+	}
+
+	merged := make(models.Labels, len(labels)+len(metric.Labels))
+	for k, v := range labels {
+		merged[k] = v
+	}
+	for k, v := range metric.Labels {
+		merged[k] = v
+	}
+	metric.Labels = merged
+	return metric
 }
 
 // SendMetrics sends all metrics to the server using URL-encoded format.
@@ -78,7 +137,7 @@ func worker(ctx context.Context, tasks <-chan Task, limiter *rate.Limiter) {
 // Returns:
 //   - error: if any send operation fails
 func SendMetrics(storage storage.StorageIface, serverAddress, key string) error {
-	client := &http.Client{Timeout: 5 * time.Second}
+	client := newHTTPClient()
 	metrics, err := storage.GetMetrics(context.Background())
 	if err != nil {
 		logger.Log.Sugar().Errorln(err)
@@ -128,7 +187,7 @@ func SendMetrics(storage storage.StorageIface, serverAddress, key string) error
 // Returns:
 //   - error: if any send operation fails
 func SendMetricsJSON(storage storage.StorageIface, serverAddress, key string) error {
-	client := &http.Client{Timeout: 5 * time.Second}
+	client := newHTTPClient()
 	metrics, err := storage.GetMetrics(context.Background())
 	if err != nil {
 		logger.Log.Sugar().Errorln(err)
@@ -190,7 +249,7 @@ func SendMetricsJSON(storage storage.StorageIface, serverAddress, key string) er
 // Returns:
 //   - error: if any send operation fails after retries
 func SendAll(storage storage.StorageIface, serverAddress, key string) error {
-	client := &http.Client{Timeout: 5 * time.Second}
+	client := newHTTPClient()
 	baseURL, err := url.Parse(serverAddress)
 	if err != nil {
 		logger.Log.Error(err.Error())
@@ -237,53 +296,18 @@ func SendAll(storage storage.StorageIface, serverAddress, key string) error {
 	return nil
 }
 
-// sendRequest sends an HTTP request with compression and optional signing.
+// sendRequest sends an HTTP request with compression, optional signing and
+// optional hybrid encryption of the body with the configured public key
+// (see client.Post).
 //
 // Parameters:
-//   - client: HTTP client to use
+//   - httpClient: HTTP client to use
 //   - url: Target URL
 //   - body: Request body (nil for empty)
 //   - key: Secret key for signing (empty for no signing)
 //
 // Returns:
-//   - error: if request fails
-func sendRequest(ctx context.Context, client *http.Client, url string, body []byte, key string) error {
-	var encryptedBody []byte
-	var err error
-
-	if cfg.PablicKey != nil {
-
-		encryptedBody, err = rsa.EncryptPKCS1v15(rand.Reader, cfg.PablicKey, body)
-		if err != nil {
-			return fmt.Errorf("failed to encrypt data: %w", err)
-		}
-	} else {
-		encryptedBody = body
-	}
-	cbody, err := compress.CompressGzip(encryptedBody)
-	if err != nil {
-		return fmt.Errorf("failed to compress request: %w", err)
-	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(cbody))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	if body != nil {
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Content-Encoding", "gzip")
-		if key != "" {
-			req.Header.Set("HashSHA256", middleware.HmacSHA256(body, []byte(key)))
-		}
-	}
-	req.Header.Set("Accept-Encoding", "gzip")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	return nil
+//   - error: if the request fails or the server responds with an error status
+func sendRequest(ctx context.Context, httpClient *http.Client, url string, body []byte, key string) error {
+	return client.Post(ctx, httpClient, url, body, client.Options{Key: key, PublicKey: cfg.PablicKey})
 }
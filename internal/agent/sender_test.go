@@ -1,10 +1,19 @@
 package agent
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"github.com/runtime-metrics-course/internal/encryption"
 )
 
 func TestSendRequest(t *testing.T) {
@@ -52,3 +61,77 @@ func TestSendRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestSendRequest_RealIP(t *testing.T) {
+	var realIP string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		realIP = r.Header.Get("X-Real-IP")
+	}))
+	defer ts.Close()
+
+	if err := sendRequest(context.Background(), ts.Client(), ts.URL, []byte(`[]`), ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if realIP != "127.0.0.1" {
+		t.Errorf("expected X-Real-IP 127.0.0.1, got %q", realIP)
+	}
+}
+
+func TestSendRequest_Encrypted(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var version string
+	var body []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version = r.Header.Get(encryption.VersionHeader)
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		body, _ = io.ReadAll(gz)
+	}))
+	defer ts.Close()
+
+	cfg.PablicKey = &priv.PublicKey
+	defer func() { cfg.PablicKey = nil }()
+
+	// A batch larger than a single RSA block
+	payload := bytes.Repeat([]byte(`{"id":"Alloc","type":"gauge","value":1},`), 100)
+	if err := sendRequest(context.Background(), ts.Client(), ts.URL, payload, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if version != encryption.Version {
+		t.Errorf("expected encryption version %q, got %q", encryption.Version, version)
+	}
+	decrypted, err := encryption.Open(priv, body)
+	if err != nil {
+		t.Fatalf("failed to open envelope: %v", err)
+	}
+	if !bytes.Equal(decrypted, payload) {
+		t.Error("decrypted body differs from the payload")
+	}
+}
+
+func TestSendRequest_TLS(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+	tlsClientConfig = &tls.Config{RootCAs: pool}
+	defer func() { tlsClientConfig = nil }()
+
+	if err := sendRequest(context.Background(), newHTTPClient(), ts.URL, []byte(`[]`), ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tlsClientConfig = &tls.Config{RootCAs: x509.NewCertPool()}
+	if err := sendRequest(context.Background(), newHTTPClient(), ts.URL, []byte(`[]`), ""); err == nil {
+		t.Error("expected certificate verification error")
+	}
+}
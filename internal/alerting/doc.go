@@ -0,0 +1,17 @@
+// Package alerting evaluates alerting rules against the stored metrics.
+//
+// Rules are loaded from a JSON file (see LoadRules) and come in two kinds:
+//   - threshold rules, e.g. HeapAlloc > 524288000 for 2m
+//   - absence rules on counters, e.g. no update to PollCount for 1m
+//
+// Absence rules detect updates as value changes, so they are rejected for
+// gauges, which may keep reporting the same value.
+//
+// The Engine evaluates the rules periodically using StorageIface.GetMetrics.
+// Every metric series matched by a rule gets its own alert, which moves
+// through the pending, firing and resolved states.
+//
+// Alerts that start firing or get resolved are passed to a Notifier;
+// WebhookNotifier POSTs them as JSON payloads to webhook URLs, signed when a
+// webhook key is configured.
+package alerting
@@ -0,0 +1,274 @@
+package alerting
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/runtime-metrics-course/internal/logger"
+	"github.com/runtime-metrics-course/internal/models"
+	"github.com/runtime-metrics-course/internal/storage"
+)
+
+// Alert states
+const (
+	StatePending  = "pending"  // Condition holds but not yet for the rule's For duration
+	StateFiring   = "firing"   // Condition has held for at least the rule's For duration
+	StateResolved = "resolved" // Alert was firing and the condition no longer holds
+)
+
+// ResolvedRetention is how long resolved alerts are still reported
+const ResolvedRetention = 15 * time.Minute
+
+// Alert is the state of a rule for one metric series
+type Alert struct {
+	Labels      models.Labels `json:"labels,omitempty"`      // Labels of the series
+	Value       *float64      `json:"value,omitempty"`       // Last evaluated value (absent for absence alerts without data)
+	FiredAt     *time.Time    `json:"fired_at,omitempty"`    // When the alert started firing
+	ResolvedAt  *time.Time    `json:"resolved_at,omitempty"` // When the alert was resolved
+	ActiveAt    time.Time     `json:"active_at"`             // When the condition started to hold
+	Rule        string        `json:"rule"`                  // Rule name
+	Metric      string        `json:"metric"`                // Metric name (ID)
+	MType       string        `json:"type"`                  // Metric type
+	State       string        `json:"state"`                 // pending, firing or resolved
+	Description string        `json:"description"`           // Human readable rule condition
+}
+
+// seriesState tracks when a counter value last changed, for absence rules
+type seriesState struct {
+	value   float64
+	changed time.Time
+}
+
+// Engine periodically evaluates alerting rules against the metrics storage.
+// It is safe for concurrent use.
+type Engine struct {
+	storage  storage.StorageIface
+	notifier Notifier
+	rules    []Rule
+	interval time.Duration
+	started  time.Time
+
+	mu     sync.RWMutex
+	alerts map[string]*Alert       // Active and recently resolved alerts by rule and series key
+	series map[string]*seriesState // Last seen counter values by series key
+}
+
+// NewEngine creates an engine that evaluates rules every interval
+func NewEngine(rules []Rule, storage storage.StorageIface, interval time.Duration) *Engine {
+	return &Engine{
+		storage:  storage,
+		rules:    rules,
+		interval: interval,
+		started:  time.Now(),
+		alerts:   make(map[string]*Alert),
+		series:   make(map[string]*seriesState),
+	}
+}
+
+// SetNotifier sets the notifier told about alerts that start firing or get
+// resolved. Must be called before Run.
+func (e *Engine) SetNotifier(n Notifier) {
+	e.notifier = n
+}
+
+// Run evaluates the rules every interval until the context is canceled
+func (e *Engine) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := e.Evaluate(ctx, now); err != nil {
+				logger.Log.Sugar().Errorf("alert evaluation failed: %v", err)
+			}
+		}
+	}
+}
+
+// Evaluate reads the current metrics and updates alert states as of now.
+// Alerts that started firing or were resolved are passed to the notifier.
+func (e *Engine) Evaluate(ctx context.Context, now time.Time) error {
+	metrics, err := e.storage.GetMetrics(ctx)
+	if err != nil {
+		return err
+	}
+
+	changed := e.evaluate(metrics, now)
+	if e.notifier != nil && len(changed) != 0 {
+		e.notifier.Notify(ctx, changed)
+	}
+	return nil
+}
+
+// evaluate updates alert states and returns copies of the alerts that
+// started firing or were resolved
+func (e *Engine) evaluate(metrics models.Metrics, now time.Time) []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.observe(metrics, now)
+
+	var changed []Alert
+	active := make(map[string]struct{})
+	for i := range e.rules {
+		rule := &e.rules[i]
+		for _, s := range e.matchSeries(rule, metrics) {
+			id := rule.Name + "/" + s.key
+			if e.holds(rule, s, now) {
+				active[id] = struct{}{}
+				if e.activate(id, rule, s, now) {
+					changed = append(changed, *e.alerts[id])
+				}
+			}
+		}
+	}
+
+	for id, alert := range e.alerts {
+		if _, ok := active[id]; ok {
+			continue
+		}
+		switch alert.State {
+		case StatePending:
+			delete(e.alerts, id)
+		case StateFiring:
+			alert.State = StateResolved
+			resolvedAt := now
+			alert.ResolvedAt = &resolvedAt
+			changed = append(changed, *alert)
+		case StateResolved:
+			if now.Sub(*alert.ResolvedAt) >= ResolvedRetention {
+				delete(e.alerts, id)
+			}
+		}
+	}
+	return changed
+}
+
+// Alerts returns pending, firing and recently resolved alerts sorted by rule and series
+func (e *Engine) Alerts() []Alert {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	ids := make([]string, 0, len(e.alerts))
+	for id := range e.alerts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	alerts := make([]Alert, 0, len(ids))
+	for _, id := range ids {
+		alerts = append(alerts, *e.alerts[id])
+	}
+	return alerts
+}
+
+// series is a metric series matched by a rule
+type series struct {
+	labels models.Labels
+	value  *float64 // nil if the series does not exist
+	key    string
+}
+
+// observe records value changes of all counter series.
+// Every update with a nonzero delta changes a counter, so a changed value
+// marks an update.
+func (e *Engine) observe(metrics models.Metrics, now time.Time) {
+	for key, counter := range metrics.Counters {
+		value := float64(counter)
+		s, ok := e.series[key]
+		if !ok {
+			e.series[key] = &seriesState{value: value, changed: now}
+			continue
+		}
+		if s.value != value {
+			s.value = value
+			s.changed = now
+		}
+	}
+}
+
+// matchSeries returns the series of the rule's metric that carry the rule's labels.
+// An absence rule without any matching series yields one empty series, so the
+// absence of the metric itself can fire.
+func (e *Engine) matchSeries(rule *Rule, metrics models.Metrics) []series {
+	var matched []series
+	add := func(key string, value float64) {
+		name, labels := models.ParseSeriesKey(key)
+		if name != rule.Metric || !hasLabels(labels, rule.Labels) {
+			return
+		}
+		matched = append(matched, series{key: key, labels: labels, value: &value})
+	}
+
+	switch rule.MType {
+	case models.Gauge:
+		for key, value := range metrics.Gauges {
+			add(key, value)
+		}
+	case models.Counter:
+		for key, value := range metrics.Counters {
+			add(key, float64(value))
+		}
+	}
+
+	if len(matched) == 0 && rule.IsAbsence() {
+		matched = append(matched, series{key: models.SeriesKey(rule.Metric, rule.Labels), labels: rule.Labels})
+	}
+	return matched
+}
+
+// holds reports whether the rule condition is true for the series
+func (e *Engine) holds(rule *Rule, s series, now time.Time) bool {
+	if !rule.IsAbsence() {
+		return rule.matches(*s.value)
+	}
+
+	changed := e.started
+	if state, ok := e.series[s.key]; ok && s.value != nil {
+		changed = state.changed
+	}
+	return now.Sub(changed) >= time.Duration(rule.Absent)
+}
+
+// activate moves the alert of a series whose condition holds to pending or firing.
+// Returns true if the alert started firing.
+func (e *Engine) activate(id string, rule *Rule, s series, now time.Time) bool {
+	alert, ok := e.alerts[id]
+	if !ok || alert.State == StateResolved {
+		alert = &Alert{
+			Labels:      s.labels,
+			ActiveAt:    now,
+			Rule:        rule.Name,
+			Metric:      rule.Metric,
+			MType:       rule.MType,
+			State:       StatePending,
+			Description: rule.description(),
+		}
+		e.alerts[id] = alert
+	}
+	alert.Value = s.value
+
+	// Absence rules already waited for their duration before the condition held
+	if alert.State == StatePending && (rule.IsAbsence() || now.Sub(alert.ActiveAt) >= time.Duration(rule.For)) {
+		alert.State = StateFiring
+		firedAt := now
+		alert.FiredAt = &firedAt
+		return true
+	}
+	return false
+}
+
+// hasLabels reports whether labels contain every label of want
+func hasLabels(labels, want models.Labels) bool {
+	for k, v := range want {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
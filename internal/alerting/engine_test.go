@@ -0,0 +1,162 @@
+package alerting
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/runtime-metrics-course/internal/mocks"
+	"github.com/runtime-metrics-course/internal/models"
+	"github.com/runtime-metrics-course/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func threshold(v float64) *float64 {
+	return &v
+}
+
+func TestEngine_ThresholdRule(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMemStorage()
+	rules := []Rule{{
+		Name:      "HighHeap",
+		Metric:    "HeapAlloc",
+		MType:     models.Gauge,
+		Op:        OpGreater,
+		Threshold: threshold(100),
+		For:       Duration(2 * time.Minute),
+	}}
+	engine := NewEngine(rules, st, time.Second)
+	start := time.Now()
+
+	require.NoError(t, st.UpdateGauge(ctx, "HeapAlloc", 50))
+	require.NoError(t, engine.Evaluate(ctx, start))
+	assert.Empty(t, engine.Alerts())
+
+	require.NoError(t, st.UpdateGauge(ctx, "HeapAlloc", 150))
+	require.NoError(t, engine.Evaluate(ctx, start.Add(time.Minute)))
+	alerts := engine.Alerts()
+	require.Len(t, alerts, 1)
+	assert.Equal(t, StatePending, alerts[0].State)
+	assert.Equal(t, 150.0, *alerts[0].Value)
+	assert.Nil(t, alerts[0].FiredAt)
+
+	require.NoError(t, engine.Evaluate(ctx, start.Add(3*time.Minute)))
+	alerts = engine.Alerts()
+	require.Len(t, alerts, 1)
+	assert.Equal(t, StateFiring, alerts[0].State)
+	require.NotNil(t, alerts[0].FiredAt)
+	assert.Equal(t, start.Add(3*time.Minute), *alerts[0].FiredAt)
+
+	require.NoError(t, st.UpdateGauge(ctx, "HeapAlloc", 10))
+	require.NoError(t, engine.Evaluate(ctx, start.Add(4*time.Minute)))
+	alerts = engine.Alerts()
+	require.Len(t, alerts, 1)
+	assert.Equal(t, StateResolved, alerts[0].State)
+	require.NotNil(t, alerts[0].ResolvedAt)
+
+	require.NoError(t, engine.Evaluate(ctx, start.Add(4*time.Minute+ResolvedRetention)))
+	assert.Empty(t, engine.Alerts())
+}
+
+func TestEngine_PendingDroppedWhenConditionClears(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMemStorage()
+	engine := NewEngine([]Rule{{
+		Name:      "HighHeap",
+		Metric:    "HeapAlloc",
+		MType:     models.Gauge,
+		Op:        OpGreaterEqual,
+		Threshold: threshold(100),
+		For:       Duration(time.Minute),
+	}}, st, time.Second)
+	now := time.Now()
+
+	require.NoError(t, st.UpdateGauge(ctx, "HeapAlloc", 100))
+	require.NoError(t, engine.Evaluate(ctx, now))
+	require.Len(t, engine.Alerts(), 1)
+
+	require.NoError(t, st.UpdateGauge(ctx, "HeapAlloc", 99))
+	require.NoError(t, engine.Evaluate(ctx, now.Add(30*time.Second)))
+	assert.Empty(t, engine.Alerts())
+}
+
+func TestEngine_LabeledSeries(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMemStorage()
+	engine := NewEngine([]Rule{{
+		Name:      "BusyCPU",
+		Metric:    "CPUutilization",
+		MType:     models.Gauge,
+		Labels:    models.Labels{"host": "a"},
+		Op:        OpGreater,
+		Threshold: threshold(90),
+	}}, st, time.Second)
+
+	metrics := []models.MetricJSON{
+		{ID: "CPUutilization", MType: models.Gauge, Value: threshold(95), Labels: models.Labels{"host": "a", "cpu": "0"}},
+		{ID: "CPUutilization", MType: models.Gauge, Value: threshold(20), Labels: models.Labels{"host": "a", "cpu": "1"}},
+		{ID: "CPUutilization", MType: models.Gauge, Value: threshold(99), Labels: models.Labels{"host": "b", "cpu": "0"}},
+	}
+	require.NoError(t, st.UpdateAll(ctx, metrics))
+	require.NoError(t, engine.Evaluate(ctx, time.Now()))
+
+	alerts := engine.Alerts()
+	require.Len(t, alerts, 1)
+	assert.Equal(t, StateFiring, alerts[0].State)
+	assert.Equal(t, models.Labels{"host": "a", "cpu": "0"}, alerts[0].Labels)
+}
+
+func TestEngine_AbsenceRule(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMemStorage()
+	engine := NewEngine([]Rule{{
+		Name:   "NoPolls",
+		Metric: "PollCount",
+		MType:  models.Counter,
+		Absent: Duration(time.Minute),
+	}}, st, time.Second)
+	start := engine.started
+
+	// Metric never reported
+	require.NoError(t, engine.Evaluate(ctx, start.Add(30*time.Second)))
+	assert.Empty(t, engine.Alerts())
+	require.NoError(t, engine.Evaluate(ctx, start.Add(time.Minute)))
+	alerts := engine.Alerts()
+	require.Len(t, alerts, 1)
+	assert.Equal(t, StateFiring, alerts[0].State)
+	assert.Nil(t, alerts[0].Value)
+
+	// Metric appears and keeps changing
+	require.NoError(t, st.UpdateCounter(ctx, "PollCount", 1))
+	require.NoError(t, engine.Evaluate(ctx, start.Add(2*time.Minute)))
+	alerts = engine.Alerts()
+	require.Len(t, alerts, 1)
+	assert.Equal(t, StateResolved, alerts[0].State)
+
+	require.NoError(t, st.UpdateCounter(ctx, "PollCount", 1))
+	require.NoError(t, engine.Evaluate(ctx, start.Add(150*time.Second)))
+	require.NoError(t, engine.Evaluate(ctx, start.Add(200*time.Second)))
+	assert.Len(t, engine.Alerts(), 1)
+
+	// Metric stops changing, the resolved alert of the series fires again
+	require.NoError(t, engine.Evaluate(ctx, start.Add(210*time.Second)))
+	alerts = engine.Alerts()
+	require.Len(t, alerts, 1)
+	assert.Equal(t, StateFiring, alerts[0].State)
+	assert.Equal(t, start.Add(210*time.Second), alerts[0].ActiveAt)
+	assert.Equal(t, 2.0, *alerts[0].Value)
+}
+
+func TestEngine_StorageError(t *testing.T) {
+	st := mocks.NewStorageIface(t)
+	st.On("GetMetrics", mock.Anything).Return(models.Metrics{}, errors.New("db down"))
+
+	engine := NewEngine(nil, st, time.Second)
+	err := engine.Evaluate(context.Background(), time.Now())
+	require.Error(t, err)
+	assert.Empty(t, engine.Alerts())
+}
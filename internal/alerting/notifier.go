@@ -0,0 +1,233 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/runtime-metrics-course/internal/logger"
+	"github.com/runtime-metrics-course/internal/middleware"
+	"github.com/runtime-metrics-course/internal/models"
+	"github.com/runtime-metrics-course/internal/resilience"
+)
+
+// notifyQueueSize is the number of notification batches buffered for delivery
+const notifyQueueSize = 64
+
+// resendInterval is how often Run retries notifications no webhook accepted
+const resendInterval = time.Minute
+
+// Notifier receives alerts that started firing or were resolved
+type Notifier interface {
+	Notify(ctx context.Context, alerts []Alert)
+}
+
+// WebhookPayload is the JSON body POSTed to webhook receivers
+type WebhookPayload struct {
+	SentAt time.Time `json:"sent_at"` // When the notification was sent
+	Status string    `json:"status"`  // State of all alerts in the payload: firing or resolved
+	Alerts []Alert   `json:"alerts"`  // Alerts that changed state
+}
+
+// WebhookNotifier delivers alert notifications to webhook URLs.
+//
+// Every payload is signed with HMAC-SHA256 in the HashSHA256 header when a
+// key is set, so receivers can check it came from the server. Failed deliveries
+// (network errors and 5xx/429 responses) are retried with resilience.Retry.
+//
+// A firing notification is sent once per alert; the resolve message is only
+// sent for alerts whose firing notification was sent. An alert counts as
+// notified once at least one webhook accepted it; otherwise it is sent again
+// with the next notification or by Run every resendInterval while it fires.
+// Resolve messages are sent again the same way until a webhook accepts them.
+type WebhookNotifier struct {
+	client *http.Client
+	urls   []string
+	key    []byte
+	queue  chan []Alert
+
+	mu         sync.Mutex
+	notified   map[string]struct{} // Alerts with a firing notification sent
+	unsent     map[string]Alert    // Firing alerts no webhook accepted yet
+	unresolved map[string]Alert    // Resolved alerts no webhook accepted yet
+}
+
+// NewWebhookNotifier creates a notifier posting to urls.
+// An empty key disables payload signing.
+func NewWebhookNotifier(urls []string, key string) *WebhookNotifier {
+	n := &WebhookNotifier{
+		client:     &http.Client{Timeout: 10 * time.Second},
+		urls:       urls,
+		queue:      make(chan []Alert, notifyQueueSize),
+		notified:   make(map[string]struct{}),
+		unsent:     make(map[string]Alert),
+		unresolved: make(map[string]Alert),
+	}
+	if key != "" {
+		n.key = []byte(key)
+	}
+	return n
+}
+
+// Notify queues alerts for delivery without blocking the caller.
+// Alerts are dropped if the queue is full.
+func (n *WebhookNotifier) Notify(ctx context.Context, alerts []Alert) {
+	select {
+	case n.queue <- alerts:
+	default:
+		logger.Log.Sugar().Errorf("alert notification queue is full, %d alerts dropped", len(alerts))
+	}
+}
+
+// Run delivers queued notifications until the context is canceled.
+// Undelivered notifications are retried every resendInterval.
+func (n *WebhookNotifier) Run(ctx context.Context) {
+	ticker := time.NewTicker(resendInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case alerts := <-n.queue:
+			n.Send(ctx, alerts)
+		case <-ticker.C:
+			n.Send(ctx, nil)
+		}
+	}
+}
+
+// Send delivers alerts to every webhook synchronously, skipping duplicates.
+// Firing and resolved alerts are sent in separate payloads; alerts of
+// earlier calls that no webhook accepted are sent again.
+func (n *WebhookNotifier) Send(ctx context.Context, alerts []Alert) {
+	firing, resolved := n.dedup(alerts)
+	for _, payload := range []WebhookPayload{
+		{Status: StateFiring, Alerts: firing},
+		{Status: StateResolved, Alerts: resolved},
+	} {
+		if len(payload.Alerts) == 0 {
+			continue
+		}
+		payload.SentAt = time.Now()
+		delivered := false
+		for _, url := range n.urls {
+			if err := n.post(ctx, url, payload); err != nil {
+				logger.Log.Sugar().Errorf("failed to notify %s: %v", url, err)
+				continue
+			}
+			delivered = true
+		}
+		switch {
+		case !delivered:
+		case payload.Status == StateFiring:
+			n.markNotified(firing)
+		default:
+			n.markResolved(resolved)
+		}
+	}
+}
+
+// dedup adds alerts that were not notified yet to the unsent firing and
+// resolved ones and returns both sets
+func (n *WebhookNotifier) dedup(alerts []Alert) (firing, resolved []Alert) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, a := range alerts {
+		id := a.fingerprint()
+		_, sent := n.notified[id]
+		switch {
+		case a.State == StateFiring && sent:
+			// Fires again before its resolve was delivered, so webhooks
+			// still see it firing
+			delete(n.unresolved, id)
+		case a.State == StateFiring:
+			n.unsent[id] = a
+		case a.State == StateResolved && sent:
+			n.unresolved[id] = a
+		case a.State == StateResolved:
+			// Never notified as firing, so there is nothing to resolve
+			delete(n.unsent, id)
+		}
+	}
+	return sortedAlerts(n.unsent), sortedAlerts(n.unresolved)
+}
+
+// sortedAlerts returns the alerts of m ordered by fingerprint
+func sortedAlerts(m map[string]Alert) []Alert {
+	alerts := make([]Alert, 0, len(m))
+	for _, a := range m {
+		alerts = append(alerts, a)
+	}
+	slices.SortFunc(alerts, func(a, b Alert) int {
+		return strings.Compare(a.fingerprint(), b.fingerprint())
+	})
+	return alerts
+}
+
+// markNotified records firing alerts accepted by at least one webhook
+func (n *WebhookNotifier) markNotified(firing []Alert) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, a := range firing {
+		id := a.fingerprint()
+		n.notified[id] = struct{}{}
+		delete(n.unsent, id)
+	}
+}
+
+// markResolved forgets resolved alerts accepted by at least one webhook
+func (n *WebhookNotifier) markResolved(resolved []Alert) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, a := range resolved {
+		id := a.fingerprint()
+		delete(n.notified, id)
+		delete(n.unresolved, id)
+	}
+}
+
+// post sends a signed payload to a webhook, retrying transient failures
+func (n *WebhookNotifier) post(ctx context.Context, url string, payload WebhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	return resilience.Retry(ctx, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if n.key != nil {
+			req.Header.Set("HashSHA256", middleware.HmacSHA256(body, n.key))
+		}
+
+		resp, err := n.client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode >= http.StatusInternalServerError, resp.StatusCode == http.StatusTooManyRequests:
+			return resilience.Transient(fmt.Errorf("webhook responded with status %d", resp.StatusCode))
+		case resp.StatusCode >= http.StatusBadRequest:
+			return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+		}
+		return nil
+	})
+}
+
+// fingerprint identifies the alert of a rule for one series
+func (a *Alert) fingerprint() string {
+	return a.Rule + "/" + models.SeriesKey(a.Metric, a.Labels)
+}
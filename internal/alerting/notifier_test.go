@@ -0,0 +1,215 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/runtime-metrics-course/internal/middleware"
+	"github.com/runtime-metrics-course/internal/models"
+	"github.com/runtime-metrics-course/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookReceiver records payloads posted to an httptest server
+type webhookReceiver struct {
+	mu       sync.Mutex
+	payloads []WebhookPayload
+	hashes   []string
+	bodies   [][]byte
+	statuses []int // Response statuses returned in order, then 200
+}
+
+func (rcv *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+
+	if len(rcv.statuses) != 0 {
+		status := rcv.statuses[0]
+		rcv.statuses = rcv.statuses[1:]
+		w.WriteHeader(status)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	var payload WebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	rcv.payloads = append(rcv.payloads, payload)
+	rcv.hashes = append(rcv.hashes, r.Header.Get("HashSHA256"))
+	rcv.bodies = append(rcv.bodies, body)
+}
+
+func (rcv *webhookReceiver) received() []WebhookPayload {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	return append([]WebhookPayload(nil), rcv.payloads...)
+}
+
+func firingAlert(rule string, labels models.Labels) Alert {
+	return Alert{Rule: rule, Metric: "HeapAlloc", MType: models.Gauge, Labels: labels, State: StateFiring}
+}
+
+func resolvedAlert(rule string, labels models.Labels) Alert {
+	a := firingAlert(rule, labels)
+	a.State = StateResolved
+	return a
+}
+
+func TestWebhookNotifier_Send(t *testing.T) {
+	rcv := &webhookReceiver{}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	key := "secret"
+	n := NewWebhookNotifier([]string{srv.URL}, key)
+	ctx := context.Background()
+
+	n.Send(ctx, []Alert{firingAlert("HighHeap", nil), firingAlert("HighHeap", models.Labels{"host": "a"})})
+	// Repeated firing notifications are dropped
+	n.Send(ctx, []Alert{firingAlert("HighHeap", nil)})
+	// Resolve of an alert that never fired is dropped
+	n.Send(ctx, []Alert{resolvedAlert("Other", nil)})
+	n.Send(ctx, []Alert{resolvedAlert("HighHeap", nil)})
+	// Only one resolve message is sent
+	n.Send(ctx, []Alert{resolvedAlert("HighHeap", nil)})
+
+	payloads := rcv.received()
+	require.Len(t, payloads, 2)
+	assert.Equal(t, StateFiring, payloads[0].Status)
+	assert.Len(t, payloads[0].Alerts, 2)
+	assert.Equal(t, StateResolved, payloads[1].Status)
+	require.Len(t, payloads[1].Alerts, 1)
+	assert.Equal(t, "HighHeap", payloads[1].Alerts[0].Rule)
+
+	for i, body := range rcv.bodies {
+		assert.Equal(t, middleware.HmacSHA256(body, []byte(key)), rcv.hashes[i])
+	}
+}
+
+func TestWebhookNotifier_Unsigned(t *testing.T) {
+	rcv := &webhookReceiver{}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	NewWebhookNotifier([]string{srv.URL}, "").Send(context.Background(), []Alert{firingAlert("HighHeap", nil)})
+
+	require.Len(t, rcv.received(), 1)
+	assert.Empty(t, rcv.hashes[0])
+}
+
+func TestWebhookNotifier_Retry(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		wantSent int
+	}{
+		{name: "Retried after server error", statuses: []int{http.StatusServiceUnavailable}, wantSent: 1},
+		{name: "Client error is not retried", statuses: []int{http.StatusBadRequest}, wantSent: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rcv := &webhookReceiver{statuses: tt.statuses}
+			srv := httptest.NewServer(rcv)
+			defer srv.Close()
+
+			NewWebhookNotifier([]string{srv.URL}, "").Send(context.Background(), []Alert{firingAlert("HighHeap", nil)})
+			assert.Len(t, rcv.received(), tt.wantSent)
+		})
+	}
+}
+
+func TestWebhookNotifier_ResendUndelivered(t *testing.T) {
+	rcv := &webhookReceiver{statuses: []int{http.StatusBadRequest}}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	n := NewWebhookNotifier([]string{srv.URL}, "")
+	ctx := context.Background()
+
+	n.Send(ctx, []Alert{firingAlert("HighHeap", nil)})
+	require.Empty(t, rcv.received())
+	// The resolve message is not sent for an alert never notified as firing
+	n.Send(ctx, []Alert{firingAlert("Other", nil), resolvedAlert("Other", nil)})
+	n.Send(ctx, nil)
+	n.Send(ctx, nil)
+
+	payloads := rcv.received()
+	require.Len(t, payloads, 1)
+	assert.Equal(t, StateFiring, payloads[0].Status)
+	require.Len(t, payloads[0].Alerts, 1)
+	assert.Equal(t, "HighHeap", payloads[0].Alerts[0].Rule)
+}
+
+func TestWebhookNotifier_ResendResolved(t *testing.T) {
+	rcv := &webhookReceiver{}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	n := NewWebhookNotifier([]string{srv.URL}, "")
+	ctx := context.Background()
+
+	n.Send(ctx, []Alert{firingAlert("HighHeap", nil), firingAlert("Other", nil)})
+	rcv.mu.Lock()
+	rcv.statuses = []int{http.StatusBadRequest}
+	rcv.mu.Unlock()
+	n.Send(ctx, []Alert{resolvedAlert("HighHeap", nil), resolvedAlert("Other", nil)})
+	require.Len(t, rcv.received(), 1)
+	// An alert firing again before its resolve was delivered is not resolved
+	n.Send(ctx, []Alert{firingAlert("Other", nil)})
+	n.Send(ctx, nil)
+
+	payloads := rcv.received()
+	require.Len(t, payloads, 2)
+	assert.Equal(t, StateResolved, payloads[1].Status)
+	require.Len(t, payloads[1].Alerts, 1)
+	assert.Equal(t, "HighHeap", payloads[1].Alerts[0].Rule)
+}
+
+func TestWebhookNotifier_Run(t *testing.T) {
+	rcv := &webhookReceiver{}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	n := NewWebhookNotifier([]string{srv.URL}, "")
+	go n.Run(ctx)
+
+	st := storage.NewMemStorage()
+	require.NoError(t, st.UpdateGauge(ctx, "HeapAlloc", 200))
+	engine := NewEngine([]Rule{{
+		Name:      "HighHeap",
+		Metric:    "HeapAlloc",
+		MType:     models.Gauge,
+		Op:        OpGreater,
+		Threshold: threshold(100),
+	}}, st, time.Second)
+	engine.SetNotifier(n)
+
+	now := time.Now()
+	require.NoError(t, engine.Evaluate(ctx, now))
+	require.NoError(t, engine.Evaluate(ctx, now.Add(time.Second)))
+	require.NoError(t, st.UpdateGauge(ctx, "HeapAlloc", 50))
+	require.NoError(t, engine.Evaluate(ctx, now.Add(2*time.Second)))
+
+	require.Eventually(t, func() bool {
+		return len(rcv.received()) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+
+	payloads := rcv.received()
+	assert.Equal(t, StateFiring, payloads[0].Status)
+	assert.Equal(t, 200.0, *payloads[0].Alerts[0].Value)
+	assert.Equal(t, StateResolved, payloads[1].Status)
+	assert.NotNil(t, payloads[1].Alerts[0].ResolvedAt)
+}
@@ -0,0 +1,180 @@
+package alerting
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/runtime-metrics-course/internal/models"
+)
+
+// Comparison operators supported by threshold rules
+const (
+	OpGreater      = ">"
+	OpGreaterEqual = ">="
+	OpLess         = "<"
+	OpLessEqual    = "<="
+	OpEqual        = "=="
+	OpNotEqual     = "!="
+)
+
+// Duration is a time.Duration that is read from JSON as a string such as "2m"
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string (e.g. "90s") or a number of nanoseconds
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var ns int64
+		if err := json.Unmarshal(data, &ns); err != nil {
+			return fmt.Errorf("invalid duration %s", data)
+		}
+		*d = Duration(ns)
+		return nil
+	}
+
+	dur, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w", s, err)
+	}
+	*d = Duration(dur)
+	return nil
+}
+
+// MarshalJSON writes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Rule describes an alerting condition on a metric.
+//
+// A threshold rule (Op and Threshold set) fires when the metric value
+// satisfies the comparison for at least For. An absence rule (Absent set)
+// fires when the metric has not been updated for Absent. Absence rules apply
+// to counters only: updates are detected as value changes, and a gauge that
+// keeps reporting the same value would look absent.
+//
+// Rules apply to every series of the metric whose labels include Labels.
+type Rule struct {
+	Labels    models.Labels `json:"labels,omitempty"`    // Labels a series must have to match
+	Threshold *float64      `json:"threshold,omitempty"` // Threshold compared with the metric value
+	Name      string        `json:"name"`                // Unique rule name
+	Metric    string        `json:"metric"`              // Metric name (ID)
+	MType     string        `json:"type"`                // Metric type (gauge or counter)
+	Op        string        `json:"op,omitempty"`        // Comparison operator, e.g. ">"
+	For       Duration      `json:"for,omitempty"`       // How long the condition must hold before firing
+	Absent    Duration      `json:"absent,omitempty"`    // Fire when no counter update was seen for this long
+}
+
+// IsAbsence reports whether the rule is an absence rule
+func (r *Rule) IsAbsence() bool {
+	return r.Absent > 0
+}
+
+// Validate checks that the rule is complete and consistent
+func (r *Rule) Validate() error {
+	if r.Name == "" {
+		return errors.New("rule name is required")
+	}
+	if r.Metric == "" {
+		return fmt.Errorf("rule %s: metric is required", r.Name)
+	}
+	if r.MType != models.Gauge && r.MType != models.Counter {
+		return fmt.Errorf("rule %s: unsupported metric type %q", r.Name, r.MType)
+	}
+	if r.IsAbsence() {
+		if r.Op != "" || r.Threshold != nil {
+			return fmt.Errorf("rule %s: absence rules cannot have a threshold", r.Name)
+		}
+		if r.MType != models.Counter {
+			return fmt.Errorf("rule %s: absence rules apply to counters only: a %s reporting a constant value cannot be told apart from one no longer updated", r.Name, r.MType)
+		}
+		return nil
+	}
+	if r.Threshold == nil {
+		return fmt.Errorf("rule %s: threshold or absent is required", r.Name)
+	}
+	switch r.Op {
+	case OpGreater, OpGreaterEqual, OpLess, OpLessEqual, OpEqual, OpNotEqual:
+	default:
+		return fmt.Errorf("rule %s: unsupported operator %q", r.Name, r.Op)
+	}
+	if r.For < 0 {
+		return fmt.Errorf("rule %s: negative for duration", r.Name)
+	}
+	return nil
+}
+
+// matches reports whether the value satisfies the threshold condition
+func (r *Rule) matches(value float64) bool {
+	threshold := *r.Threshold
+	switch r.Op {
+	case OpGreater:
+		return value > threshold
+	case OpGreaterEqual:
+		return value >= threshold
+	case OpLess:
+		return value < threshold
+	case OpLessEqual:
+		return value <= threshold
+	case OpEqual:
+		return value == threshold
+	case OpNotEqual:
+		return value != threshold
+	}
+	return false
+}
+
+// description returns a human readable condition of the rule
+func (r *Rule) description() string {
+	if r.IsAbsence() {
+		return fmt.Sprintf("no update to %s for %s", r.Metric, time.Duration(r.Absent))
+	}
+	desc := fmt.Sprintf("%s %s %g", r.Metric, r.Op, *r.Threshold)
+	if r.For > 0 {
+		desc += " for " + time.Duration(r.For).String()
+	}
+	return desc
+}
+
+// rulesFile is the layout of the rules configuration file
+type rulesFile struct {
+	Rules []Rule `json:"rules"`
+}
+
+// LoadRules reads alerting rules from a JSON file of the form
+//
+//	{"rules": [
+//	    {"name": "HighHeap", "metric": "HeapAlloc", "type": "gauge", "op": ">", "threshold": 524288000, "for": "2m"},
+//	    {"name": "NoPolls", "metric": "PollCount", "type": "counter", "absent": "1m"}
+//	]}
+//
+// Absence rules must have the counter type, as a constant gauge cannot be
+// told apart from one that is no longer updated.
+//
+// Returns an error if the file cannot be read or any rule is invalid.
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %w", err)
+	}
+
+	var file rulesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse rules file: %w", err)
+	}
+
+	names := make(map[string]struct{}, len(file.Rules))
+	for i := range file.Rules {
+		if err := file.Rules[i].Validate(); err != nil {
+			return nil, err
+		}
+		if _, ok := names[file.Rules[i].Name]; ok {
+			return nil, fmt.Errorf("duplicate rule name %q", file.Rules[i].Name)
+		}
+		names[file.Rules[i].Name] = struct{}{}
+	}
+	return file.Rules, nil
+}
@@ -0,0 +1,112 @@
+package alerting
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadRules(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		wantRules int
+		wantErr   string
+	}{
+		{
+			name: "Threshold and absence rules",
+			content: `{"rules": [
+				{"name": "HighHeap", "metric": "HeapAlloc", "type": "gauge", "op": ">", "threshold": 524288000, "for": "2m"},
+				{"name": "NoPolls", "metric": "PollCount", "type": "counter", "absent": "1m"}
+			]}`,
+			wantRules: 2,
+		},
+		{
+			name:    "Invalid JSON",
+			content: `{"rules": [`,
+			wantErr: "failed to parse rules file",
+		},
+		{
+			name:    "Invalid duration",
+			content: `{"rules": [{"name": "r", "metric": "m", "type": "gauge", "op": ">", "threshold": 1, "for": "soon"}]}`,
+			wantErr: "invalid duration",
+		},
+		{
+			name:    "Unknown operator",
+			content: `{"rules": [{"name": "r", "metric": "m", "type": "gauge", "op": "=>", "threshold": 1}]}`,
+			wantErr: "unsupported operator",
+		},
+		{
+			name:    "Missing threshold",
+			content: `{"rules": [{"name": "r", "metric": "m", "type": "gauge", "op": ">"}]}`,
+			wantErr: "threshold or absent is required",
+		},
+		{
+			name:    "Absence rule with threshold",
+			content: `{"rules": [{"name": "r", "metric": "m", "type": "gauge", "op": ">", "threshold": 1, "absent": "1m"}]}`,
+			wantErr: "absence rules cannot have a threshold",
+		},
+		{
+			name:    "Absence rule on a gauge",
+			content: `{"rules": [{"name": "r", "metric": "HeapAlloc", "type": "gauge", "absent": "1m"}]}`,
+			wantErr: "absence rules apply to counters only: a gauge reporting a constant value cannot be told apart from one no longer updated",
+		},
+		{
+			name:    "Unsupported metric type",
+			content: `{"rules": [{"name": "r", "metric": "m", "type": "histogram", "absent": "1m"}]}`,
+			wantErr: "unsupported metric type",
+		},
+		{
+			name: "Duplicate names",
+			content: `{"rules": [
+				{"name": "r", "metric": "m", "type": "counter", "absent": "1m"},
+				{"name": "r", "metric": "n", "type": "counter", "absent": "1m"}
+			]}`,
+			wantErr: "duplicate rule name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rules.json")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o600))
+
+			rules, err := LoadRules(path)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, rules, tt.wantRules)
+		})
+	}
+}
+
+func TestLoadRules_Fields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	content := `{"rules": [{"name": "HighHeap", "metric": "HeapAlloc", "type": "gauge", "labels": {"host": "a"}, "op": ">", "threshold": 100, "for": "2m"}]}`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	rules, err := LoadRules(path)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+
+	rule := rules[0]
+	assert.Equal(t, "HeapAlloc", rule.Metric)
+	assert.Equal(t, "a", rule.Labels["host"])
+	assert.Equal(t, 2*time.Minute, time.Duration(rule.For))
+	assert.Equal(t, 100.0, *rule.Threshold)
+	assert.False(t, rule.IsAbsence())
+	assert.Equal(t, "HeapAlloc > 100 for 2m0s", rule.description())
+}
+
+func TestLoadRules_MissingFile(t *testing.T) {
+	_, err := LoadRules(filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to read rules file")
+}
@@ -0,0 +1,149 @@
+// Package client posts metric batches to the server over HTTP. It is shared
+// by the agent and by servers forwarding metrics upstream, so both send
+// requests the server middleware accepts.
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/runtime-metrics-course/internal/compress"
+	"github.com/runtime-metrics-course/internal/encryption"
+	"github.com/runtime-metrics-course/internal/middleware"
+	"github.com/runtime-metrics-course/internal/resilience"
+)
+
+// maxMessageSize limits the part of an error response kept in StatusError
+const maxMessageSize = 512
+
+// Options control how a request body is protected
+type Options struct {
+	Key       string         // Secret key signing the body with HMAC-SHA256 (empty disables signing)
+	PublicKey *rsa.PublicKey // Server public key encrypting the body (nil sends plaintext)
+}
+
+// Post sends a request to url with compression, optional signing and
+// optional hybrid encryption of the body (see package encryption). The
+// X-Real-IP header carries the local address used to reach the server.
+//
+// Parameters:
+//   - client: HTTP client to use
+//   - url: Target URL
+//   - body: Request body (nil for empty)
+//   - opts: Signing and encryption settings
+//
+// Returns:
+//   - error: a *StatusError for error responses, marked transient for 5xx
+//     (see resilience.Transient), or the error of a failed request
+func Post(ctx context.Context, client *http.Client, url string, body []byte, opts Options) error {
+	payload := body
+	var err error
+	if opts.PublicKey != nil && len(body) != 0 {
+		payload, err = encryption.Seal(opts.PublicKey, body)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt data: %w", err)
+		}
+	}
+	cbody, err := compress.CompressGzip(payload)
+	if err != nil {
+		return fmt.Errorf("failed to compress request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(cbody))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if opts.PublicKey != nil && len(body) != 0 {
+		req.Header.Set(encryption.VersionHeader, encryption.Version)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", "gzip")
+		if opts.Key != "" {
+			req.Header.Set("HashSHA256", middleware.HmacSHA256(body, []byte(opts.Key)))
+		}
+	}
+	req.Header.Set("Accept-Encoding", "gzip")
+	if ip := OutboundIP(req.URL.Host); ip != "" {
+		req.Header.Set(middleware.RealIPHeader, ip)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+	statusErr := &StatusError{Code: resp.StatusCode, Message: readMessage(resp.Body)}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return resilience.Transient(statusErr)
+	}
+	return statusErr
+}
+
+// readMessage returns the first line of an error response body
+func readMessage(body io.Reader) string {
+	line, _ := bufio.NewReader(io.LimitReader(body, maxMessageSize)).ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+// StatusError is returned by Post for error responses of the server
+type StatusError struct {
+	Message string // First line of the response body
+	Code    int    // Response status
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("server responded with status %d", e.Code)
+	}
+	return fmt.Sprintf("server responded with status %d: %s", e.Code, e.Message)
+}
+
+// IsRejected reports whether the server rejected the request itself (4xx),
+// so sending it again unchanged is not expected to succeed
+func IsRejected(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.Code < http.StatusInternalServerError
+}
+
+// outboundIPs caches the local address used to reach a server by its host:port
+var outboundIPs sync.Map
+
+// OutboundIP returns the address of the local interface used to reach the
+// server at hostport (port defaults to 80), for the X-Real-IP header checked
+// by a trusted subnet. Returns an empty string if the route cannot be determined.
+func OutboundIP(hostport string) string {
+	if ip, ok := outboundIPs.Load(hostport); ok {
+		return ip.(string)
+	}
+
+	address := hostport
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "80")
+	}
+
+	// Connecting a UDP socket selects the route without sending any packets
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return ""
+	}
+	defer conn.Close()
+
+	ip := conn.LocalAddr().(*net.UDPAddr).IP.String()
+	outboundIPs.Store(hostport, ip)
+	return ip
+}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/runtime-metrics-course/internal/middleware"
+)
+
+func TestPost(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		wantErr  string
+		rejected bool
+	}{
+		{name: "Success", status: http.StatusOK},
+		{name: "Bad request", status: http.StatusBadRequest, body: "invalid metric: latency\ndetails", wantErr: "server responded with status 400: invalid metric: latency", rejected: true},
+		{name: "Server error", status: http.StatusServiceUnavailable, wantErr: "server responded with status 503"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hash string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+				assert.Equal(t, "127.0.0.1", r.Header.Get(middleware.RealIPHeader))
+				hash = r.Header.Get("HashSHA256")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer ts.Close()
+
+			body := []byte(`[]`)
+			err := Post(context.Background(), ts.Client(), ts.URL, body, Options{Key: "secret"})
+			assert.Equal(t, middleware.HmacSHA256(body, []byte("secret")), hash)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.wantErr)
+			assert.Equal(t, tt.rejected, IsRejected(err))
+
+			var statusErr *StatusError
+			require.True(t, errors.As(err, &statusErr))
+			assert.Equal(t, tt.status, statusErr.Code)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealOpen(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		plaintext []byte
+	}{
+		{name: "Empty", plaintext: []byte{}},
+		{name: "Small", plaintext: []byte(`{"id":"Alloc","type":"gauge","value":1}`)},
+		// Larger than an RSA PKCS #1 v1.5 block could hold
+		{name: "Large batch", plaintext: bytes.Repeat([]byte(`{"id":"Alloc","type":"gauge","value":1},`), 1000)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envelope, err := Seal(&priv.PublicKey, tt.plaintext)
+			require.NoError(t, err)
+			if len(tt.plaintext) != 0 {
+				assert.False(t, bytes.Contains(envelope, tt.plaintext))
+			}
+
+			plaintext, err := Open(priv, envelope)
+			require.NoError(t, err)
+			assert.Equal(t, string(tt.plaintext), string(plaintext))
+		})
+	}
+}
+
+func TestSeal_FreshKeyPerCall(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	first, err := Seal(&priv.PublicKey, []byte("payload"))
+	require.NoError(t, err)
+	second, err := Seal(&priv.PublicKey, []byte("payload"))
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second)
+}
+
+func TestOpen_Invalid(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	envelope, err := Seal(&priv.PublicKey, []byte("payload"))
+	require.NoError(t, err)
+
+	tampered := append([]byte(nil), envelope...)
+	tampered[len(tampered)-1] ^= 0xff
+
+	tests := []struct {
+		name     string
+		key      *rsa.PrivateKey
+		envelope []byte
+		wantErr  error
+	}{
+		{name: "Empty", key: priv, envelope: nil, wantErr: ErrMalformedEnvelope},
+		{name: "Truncated key", key: priv, envelope: envelope[:100], wantErr: ErrMalformedEnvelope},
+		{name: "Truncated body", key: priv, envelope: envelope[:2+256+4], wantErr: ErrMalformedEnvelope},
+		{name: "Tampered ciphertext", key: priv, envelope: tampered},
+		{name: "Wrong key", key: other, envelope: envelope},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Open(tt.key, tt.envelope)
+			require.Error(t, err)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
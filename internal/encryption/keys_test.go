@@ -0,0 +1,115 @@
+package encryption
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeKeyFile writes data to a temporary file and returns its path
+func writeKeyFile(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "key")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func pemBlock(blockType string, der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+}
+
+func TestLoadPublicKey(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	pkix, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkixName("server"), NotAfter: time.Now().Add(time.Hour)}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	require.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ecPKIX, err := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{name: "PKIX PEM", data: pemBlock("PUBLIC KEY", pkix)},
+		{name: "PKCS1 PEM", data: pemBlock("RSA PUBLIC KEY", x509.MarshalPKCS1PublicKey(&priv.PublicKey))},
+		{name: "Certificate PEM", data: pemBlock("CERTIFICATE", cert)},
+		{name: "Legacy raw DER", data: x509.MarshalPKCS1PublicKey(&priv.PublicKey)},
+		{name: "Not RSA", data: pemBlock("PUBLIC KEY", ecPKIX), wantErr: true},
+		{name: "Unsupported block", data: pemBlock("PRIVATE KEY", []byte{1}), wantErr: true},
+		{name: "Garbage", data: []byte("garbage"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := LoadPublicKey(writeKeyFile(t, tt.data))
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, priv.PublicKey.Equal(key))
+		})
+	}
+}
+
+func TestLoadPrivateKey(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(priv)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{name: "PKCS1 PEM", data: pemBlock("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(priv))},
+		{name: "PKCS8 PEM", data: pemBlock("PRIVATE KEY", pkcs8)},
+		{name: "Legacy raw DER", data: x509.MarshalPKCS1PrivateKey(priv)},
+		{name: "Unsupported block", data: pemBlock("PUBLIC KEY", []byte{1}), wantErr: true},
+		{name: "Garbage", data: []byte("garbage"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := LoadPrivateKey(writeKeyFile(t, tt.data))
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, priv.Equal(key))
+		})
+	}
+}
+
+func TestLoadKey_MissingFile(t *testing.T) {
+	_, err := LoadPublicKey(filepath.Join(t.TempDir(), "missing"))
+	require.Error(t, err)
+	_, err = LoadPrivateKey(filepath.Join(t.TempDir(), "missing"))
+	require.Error(t, err)
+}
+
+func pkixName(cn string) pkix.Name {
+	return pkix.Name{CommonName: cn}
+}
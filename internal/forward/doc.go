@@ -0,0 +1,24 @@
+// Package forward ships the metrics accepted by a server to an upstream
+// server, e.g. from regional servers to a central one.
+//
+// A Forwarder wraps the storage of the server (see Forwarder.Wrap): every
+// update the storage accepts is also added to the forwarding buffer. The
+// buffer aggregates updates per series like the agent does between reports:
+// counter deltas are summed, gauges keep the latest value, histogram counts
+// are added and summary observations appended. Forwarder.Run sends the
+// buffer to the upstream /updates/ endpoint every interval, or after every
+// write with a zero interval.
+//
+// Every forwarded metric gets a source label naming the forwarding server,
+// unless it already has one (e.g. when forwarding through several tiers).
+//
+// Sends are retried with resilience.Retry. Metrics that still could not be
+// delivered go back to the buffer, so the server keeps accepting metrics
+// while the upstream is down; the buffer is limited to MaxSeries series.
+// Batches are signed and encrypted like agent requests when SecretKey and
+// CryptoKey are set. A 400 response also keeps the batch, since it is the
+// answer of an upstream that cannot verify or decrypt it, unless the
+// response says the metrics are invalid: the batch is then split to find
+// the invalid metrics, which are dropped while the others are delivered.
+// Other 4xx responses drop the batch.
+package forward
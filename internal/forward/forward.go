@@ -0,0 +1,288 @@
+package forward
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/runtime-metrics-course/internal/client"
+	"github.com/runtime-metrics-course/internal/encryption"
+	"github.com/runtime-metrics-course/internal/logger"
+	"github.com/runtime-metrics-course/internal/models"
+	"github.com/runtime-metrics-course/internal/resilience"
+	"github.com/runtime-metrics-course/internal/storage"
+)
+
+// SourceLabel is the label naming the server a metric was forwarded from
+const SourceLabel = "source"
+
+// DefaultMaxSeries is the default limit of series kept in the buffer
+const DefaultMaxSeries = 100000
+
+// Config contains the forwarder settings
+type Config struct {
+	URL       string        // Upstream server address, e.g. http://central:8080
+	Source    string        // Value of the source label
+	SecretKey string        // Key signing the forwarded batches (empty disables signing)
+	CryptoKey string        // Path to the upstream public key encrypting the batches (empty disables)
+	Interval  time.Duration // How often the buffer is sent (0 sends after every write)
+	MaxSeries int           // Buffer limit, new series are dropped beyond it (DefaultMaxSeries if 0)
+}
+
+// Forwarder buffers accepted metrics and sends them to the upstream server.
+// It is safe for concurrent use.
+type Forwarder struct {
+	cfg       Config
+	endpoint  string
+	publicKey *rsa.PublicKey // Upstream public key, nil sends plaintext
+	client    *http.Client
+	written   chan struct{} // Signals writes when sending after every write
+
+	mu      sync.Mutex
+	buffer  map[string]models.MetricJSON // Aggregated metrics by type and series key
+	dropped int                          // Metrics dropped since the last warning
+}
+
+// New creates a forwarder. Returns an error if the upstream URL or the
+// public key is invalid.
+func New(cfg Config) (*Forwarder, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upstream URL: %w", err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid upstream URL %q: missing host", cfg.URL)
+	}
+	if u.Scheme == "" {
+		u.Scheme = "http"
+	}
+	if cfg.MaxSeries <= 0 {
+		cfg.MaxSeries = DefaultMaxSeries
+	}
+	var publicKey *rsa.PublicKey
+	if cfg.CryptoKey != "" {
+		if publicKey, err = encryption.LoadPublicKey(cfg.CryptoKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Forwarder{
+		cfg:       cfg,
+		publicKey: publicKey,
+		endpoint:  strings.TrimSuffix(u.String(), "/") + "/updates/",
+		client:    &http.Client{Timeout: 30 * time.Second},
+		written:   make(chan struct{}, 1),
+		buffer:    make(map[string]models.MetricJSON),
+	}, nil
+}
+
+// Add adds accepted metrics to the buffer
+func (f *Forwarder) Add(metrics ...models.MetricJSON) {
+	f.mu.Lock()
+	for _, m := range metrics {
+		f.add(m, false)
+	}
+	f.mu.Unlock()
+
+	if f.cfg.Interval <= 0 {
+		select {
+		case f.written <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// add merges a metric into the buffer. Restored metrics do not replace
+// newer gauge values.
+func (f *Forwarder) add(m models.MetricJSON, restored bool) {
+	key := m.MType + "/" + models.SeriesKey(m.ID, m.Labels)
+	prev, ok := f.buffer[key]
+	switch {
+	case !ok && len(f.buffer) >= f.cfg.MaxSeries:
+		f.dropped++
+	case !ok:
+		f.buffer[key] = models.CopyMetric(m)
+	case restored && m.MType == models.Gauge:
+	default:
+		f.buffer[key] = models.MergeMetric(prev, m)
+	}
+}
+
+// Run sends the buffer every interval, or after every write with a zero
+// interval, until the context is canceled. The buffer is sent once more
+// before returning.
+func (f *Forwarder) Run(ctx context.Context) {
+	var tick <-chan time.Time
+	if f.cfg.Interval > 0 {
+		ticker := time.NewTicker(f.cfg.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+			f.Flush(shutdownCtx)
+			cancel()
+			return
+		case <-tick:
+			f.Flush(ctx)
+		case <-f.written:
+			f.Flush(ctx)
+		}
+	}
+}
+
+// Flush sends the buffered metrics upstream. Metrics that could not be
+// delivered go back to the buffer, unless the upstream rejected them.
+// Metrics the upstream refuses as invalid are dropped, so that they do not
+// hold back the other buffered metrics.
+func (f *Forwarder) Flush(ctx context.Context) error {
+	metrics := f.snapshot()
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	undelivered, err := f.deliver(ctx, metrics)
+	if err == nil {
+		return nil
+	}
+
+	if isRejected(err) {
+		logger.Log.Sugar().Errorf("upstream rejected %d forwarded metrics: %v", len(undelivered), err)
+		return err
+	}
+	if isBadRequest(err) {
+		logger.Log.Sugar().Errorf("upstream could not read %d forwarded metrics, keeping them; check the forward key and crypto key: %v", len(undelivered), err)
+		f.restore(undelivered)
+		return err
+	}
+	logger.Log.Sugar().Warnf("failed to forward %d metrics, keeping them: %v", len(undelivered), err)
+	f.restore(undelivered)
+	return err
+}
+
+// deliver sends metrics upstream and returns the ones that were not
+// delivered. The upstream applies a batch all-or-nothing, so a batch refused
+// for invalid metrics is split in halves until the invalid metrics are found
+// and dropped.
+func (f *Forwarder) deliver(ctx context.Context, metrics []models.MetricJSON) ([]models.MetricJSON, error) {
+	// The buffer is keyed without the source label, only the sent copy has it
+	batch := make([]models.MetricJSON, len(metrics))
+	for i, m := range metrics {
+		batch[i] = f.withSource(m)
+	}
+	err := resilience.Retry(ctx, func() error {
+		return f.send(ctx, batch)
+	})
+	switch {
+	case err == nil:
+		return nil, nil
+	case !isInvalid(err):
+		return metrics, err
+	case len(metrics) == 1:
+		logger.Log.Sugar().Errorf("upstream refused forwarded metric %s %s, dropping it: %v",
+			metrics[0].MType, metrics[0].Key(), err)
+		return nil, nil
+	}
+
+	mid := len(metrics) / 2
+	first, err1 := f.deliver(ctx, metrics[:mid])
+	second, err2 := f.deliver(ctx, metrics[mid:])
+	return append(first, second...), errors.Join(err1, err2)
+}
+
+// snapshot takes the buffered metrics and empties the buffer
+func (f *Forwarder) snapshot() []models.MetricJSON {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.dropped != 0 {
+		logger.Log.Sugar().Warnf("forwarding buffer is full: dropped %d metrics", f.dropped)
+		f.dropped = 0
+	}
+
+	keys := make([]string, 0, len(f.buffer))
+	for key := range f.buffer {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	metrics := make([]models.MetricJSON, 0, len(keys))
+	for _, key := range keys {
+		metrics = append(metrics, f.buffer[key])
+	}
+	clear(f.buffer)
+	return metrics
+}
+
+// restore adds undelivered metrics back to the buffer
+func (f *Forwarder) restore(metrics []models.MetricJSON) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, m := range metrics {
+		f.add(m, true)
+	}
+}
+
+// withSource sets the source label unless the metric already has one
+func (f *Forwarder) withSource(m models.MetricJSON) models.MetricJSON {
+	if f.cfg.Source == "" {
+		return m
+	}
+	if _, ok := m.Labels[SourceLabel]; ok {
+		return m
+	}
+	labels := make(models.Labels, len(m.Labels)+1)
+	for k, v := range m.Labels {
+		labels[k] = v
+	}
+	labels[SourceLabel] = f.cfg.Source
+	m.Labels = labels
+	return m
+}
+
+// isRejected reports whether the upstream refused the batch itself, so
+// sending it again cannot succeed. 400 Bad Request is not final, see
+// isBadRequest and isInvalid.
+func isRejected(err error) bool {
+	var se *client.StatusError
+	return client.IsRejected(err) && errors.As(err, &se) && se.Code != http.StatusBadRequest
+}
+
+// isBadRequest reports whether the upstream responded 400 Bad Request for
+// another reason than invalid metrics. It is the response to a batch the
+// upstream cannot decrypt or verify, which is accepted once the keys are
+// configured, so the batch is kept.
+func isBadRequest(err error) bool {
+	var se *client.StatusError
+	return errors.As(err, &se) && se.Code == http.StatusBadRequest && !isInvalid(err)
+}
+
+// isInvalid reports whether the upstream refused a batch for invalid
+// metrics: its /updates/ handler responds 400 with a message starting with
+// storage.ErrInvalidMetric
+func isInvalid(err error) bool {
+	var se *client.StatusError
+	return errors.As(err, &se) && se.Code == http.StatusBadRequest &&
+		strings.HasPrefix(se.Message, storage.ErrInvalidMetric.Error())
+}
+
+// send posts a batch to the upstream /updates/ endpoint
+func (f *Forwarder) send(ctx context.Context, metrics []models.MetricJSON) error {
+	body, err := json.Marshal(metrics)
+	if err != nil {
+		return err
+	}
+	return client.Post(ctx, f.client, f.endpoint, body, client.Options{Key: f.cfg.SecretKey, PublicKey: f.publicKey})
+}
@@ -0,0 +1,331 @@
+package forward
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/runtime-metrics-course/internal/encryption"
+	"github.com/runtime-metrics-course/internal/middleware"
+	"github.com/runtime-metrics-course/internal/models"
+	"github.com/runtime-metrics-course/internal/server"
+	"github.com/runtime-metrics-course/internal/storage"
+)
+
+// upstream is a fake upstream server recording the received batches
+type upstream struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	status  int
+	batches [][]models.MetricJSON
+	hash    string // HashSHA256 header of the last batch
+}
+
+func newUpstream(t *testing.T) *upstream {
+	u := &upstream{status: http.StatusOK}
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		if u.status != http.StatusOK {
+			w.WriteHeader(u.status)
+			return
+		}
+
+		assert.Equal(t, "/updates/", r.URL.Path)
+		gz, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		var batch []models.MetricJSON
+		require.NoError(t, json.NewDecoder(gz).Decode(&batch))
+		u.batches = append(u.batches, batch)
+		u.hash = r.Header.Get("HashSHA256")
+	}))
+	t.Cleanup(u.Close)
+	return u
+}
+
+func (u *upstream) setStatus(status int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.status = status
+}
+
+func (u *upstream) received() [][]models.MetricJSON {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([][]models.MetricJSON(nil), u.batches...)
+}
+
+func counter(id string, delta int64, labels models.Labels) models.MetricJSON {
+	return models.MetricJSON{ID: id, MType: models.Counter, Delta: &delta, Labels: labels}
+}
+
+func gauge(id string, value float64, labels models.Labels) models.MetricJSON {
+	return models.MetricJSON{ID: id, MType: models.Gauge, Value: &value, Labels: labels}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name      string
+		url       string
+		expectErr bool
+	}{
+		{name: "URL", url: "http://central:8080"},
+		{name: "Host and port", url: "central:8080", expectErr: true},
+		{name: "No host", url: "http://", expectErr: true},
+		{name: "Malformed", url: "http://[::1", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(Config{URL: tt.url})
+			assert.Equal(t, tt.expectErr, err != nil, "error: %v", err)
+		})
+	}
+}
+
+func TestForwarder_Wrap(t *testing.T) {
+	ctx := context.Background()
+	up := newUpstream(t)
+	f, err := New(Config{URL: up.URL, Source: "dc1", Interval: time.Hour})
+	require.NoError(t, err)
+
+	st := f.Wrap(storage.NewMemStorage())
+	require.NoError(t, st.UpdateCounter(ctx, `requests{path="/"}`, 2))
+	require.NoError(t, st.UpdateAll(ctx, []models.MetricJSON{
+		counter("requests", 3, models.Labels{"path": "/"}),
+		gauge("load", 1, nil),
+		gauge("load", 2, nil),
+		gauge("relayed", 5, models.Labels{SourceLabel: "dc2"}),
+	}))
+	require.NoError(t, st.UpdateGauge(ctx, "temperature", 21.5))
+
+	// The wrapped storage is updated as well
+	metrics, err := st.GetMetrics(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), metrics.Counters[`requests{path="/"}`])
+
+	require.NoError(t, f.Flush(ctx))
+	require.Len(t, up.received(), 1)
+	assert.Equal(t, []models.MetricJSON{
+		counter("requests", 5, models.Labels{"path": "/", SourceLabel: "dc1"}),
+		gauge("load", 2, models.Labels{SourceLabel: "dc1"}),
+		gauge("relayed", 5, models.Labels{SourceLabel: "dc2"}),
+		gauge("temperature", 21.5, models.Labels{SourceLabel: "dc1"}),
+	}, up.received()[0])
+
+	// Nothing new, nothing sent
+	require.NoError(t, f.Flush(ctx))
+	assert.Len(t, up.received(), 1)
+}
+
+func TestForwarder_WrapInvalidBatch(t *testing.T) {
+	ctx := context.Background()
+	up := newUpstream(t)
+	f, err := New(Config{URL: up.URL, Interval: time.Hour})
+	require.NoError(t, err)
+
+	// The storage applies batches all-or-nothing, so the local and upstream
+	// totals stay equal when a batch fails
+	st := f.Wrap(storage.NewMemStorage())
+	err = st.UpdateAll(ctx, []models.MetricJSON{
+		counter("requests", 3, nil),
+		{ID: "load", MType: models.Gauge},
+	})
+	require.ErrorIs(t, err, storage.ErrInvalidMetric)
+
+	metrics, err := st.GetMetrics(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, metrics.Counters)
+	require.NoError(t, f.Flush(ctx))
+	assert.Empty(t, up.received())
+}
+
+func TestForwarder_UpstreamDown(t *testing.T) {
+	up := newUpstream(t)
+	f, err := New(Config{URL: up.URL, Interval: time.Hour})
+	require.NoError(t, err)
+
+	f.Add(counter("requests", 2, nil), gauge("load", 1, nil))
+
+	// A canceled context stops the retries after the first attempt
+	up.setStatus(http.StatusServiceUnavailable)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, f.Flush(ctx))
+
+	// Accepted while the upstream was down
+	f.Add(counter("requests", 1, nil), gauge("load", 3, nil))
+
+	up.setStatus(http.StatusOK)
+	require.NoError(t, f.Flush(context.Background()))
+	require.Len(t, up.received(), 1)
+	assert.Equal(t, []models.MetricJSON{
+		counter("requests", 3, nil),
+		gauge("load", 3, nil),
+	}, up.received()[0], "no increment lost, the newer gauge value kept")
+}
+
+func TestForwarder_UpstreamDownWithSource(t *testing.T) {
+	up := newUpstream(t)
+	f, err := New(Config{URL: up.URL, Source: "dc1", Interval: time.Hour})
+	require.NoError(t, err)
+
+	f.Add(gauge("g", 1, nil))
+	up.setStatus(http.StatusServiceUnavailable)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, f.Flush(ctx))
+
+	f.Add(gauge("g", 2, nil))
+	up.setStatus(http.StatusOK)
+	require.NoError(t, f.Flush(context.Background()))
+	require.Len(t, up.received(), 1)
+	assert.Equal(t, []models.MetricJSON{
+		gauge("g", 2, models.Labels{SourceLabel: "dc1"}),
+	}, up.received()[0], "only the newer gauge value is delivered")
+}
+
+func TestForwarder_Rejected(t *testing.T) {
+	up := newUpstream(t)
+	f, err := New(Config{URL: up.URL, Interval: time.Hour})
+	require.NoError(t, err)
+
+	f.Add(gauge("load", 1, nil))
+	up.setStatus(http.StatusRequestEntityTooLarge)
+	assert.Error(t, f.Flush(context.Background()))
+
+	up.setStatus(http.StatusOK)
+	require.NoError(t, f.Flush(context.Background()))
+	assert.Empty(t, up.received(), "a rejected batch is not sent again")
+}
+
+func TestForwarder_BadRequestKept(t *testing.T) {
+	up := newUpstream(t)
+	f, err := New(Config{URL: up.URL, Interval: time.Hour})
+	require.NoError(t, err)
+
+	// e.g. the upstream requires encryption
+	f.Add(counter("requests", 1, nil))
+	up.setStatus(http.StatusBadRequest)
+	assert.Error(t, f.Flush(context.Background()))
+
+	up.setStatus(http.StatusOK)
+	require.NoError(t, f.Flush(context.Background()))
+	require.Len(t, up.received(), 1)
+	assert.Equal(t, []models.MetricJSON{counter("requests", 1, nil)}, up.received()[0])
+}
+
+func TestForwarder_InvalidMetricsDropped(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMemStorage()
+	sum := 1.0
+	require.NoError(t, st.UpdateAll(ctx, []models.MetricJSON{
+		{ID: "latency", MType: models.Histogram, Buckets: []float64{1}, Counts: []uint64{1, 0}, Sum: &sum},
+	}))
+	up := httptest.NewServer(middleware.CompressMiddleware(http.HandlerFunc(server.NewMetricsHandler(st).UpdateAll)))
+	defer up.Close()
+
+	f, err := New(Config{URL: up.URL, Interval: time.Hour})
+	require.NoError(t, err)
+
+	// The bucket layout differs from the upstream histogram
+	f.Add(counter("a", 1, nil), counter("b", 2, nil), counter("c", 3, nil),
+		models.MetricJSON{ID: "latency", MType: models.Histogram, Buckets: []float64{2}, Counts: []uint64{0, 1}, Sum: &sum})
+	require.NoError(t, f.Flush(ctx))
+
+	metrics, err := st.GetMetrics(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, models.Counters{"a": 1, "b": 2, "c": 3}, metrics.Counters)
+	assert.Equal(t, []float64{1}, metrics.Histograms["latency"].Bounds)
+
+	// The invalid histogram is not kept
+	f.Add(counter("a", 1, nil))
+	require.NoError(t, f.Flush(ctx))
+	metrics, err = st.GetMetrics(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), metrics.Counters["a"])
+	assert.Equal(t, uint64(1), metrics.Histograms["latency"].Count)
+}
+
+func TestForwarder_Encrypted(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	require.NoError(t, err)
+	keyPath := filepath.Join(t.TempDir(), "public.pem")
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+
+	var received []models.MetricJSON
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, encryption.Version, r.Header.Get(encryption.VersionHeader))
+		gz, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		envelope, err := io.ReadAll(gz)
+		require.NoError(t, err)
+		body, err := encryption.Open(priv, envelope)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(body, &received))
+	}))
+	defer srv.Close()
+
+	f, err := New(Config{URL: srv.URL, CryptoKey: keyPath, Interval: time.Hour})
+	require.NoError(t, err)
+	f.Add(gauge("load", 1, nil))
+	require.NoError(t, f.Flush(context.Background()))
+	assert.Equal(t, []models.MetricJSON{gauge("load", 1, nil)}, received)
+
+	_, err = New(Config{URL: srv.URL, CryptoKey: filepath.Join(t.TempDir(), "missing.pem")})
+	assert.Error(t, err)
+}
+
+func TestForwarder_MaxSeries(t *testing.T) {
+	up := newUpstream(t)
+	f, err := New(Config{URL: up.URL, Interval: time.Hour, MaxSeries: 1})
+	require.NoError(t, err)
+
+	f.Add(counter("a", 1, nil), counter("b", 1, nil), counter("a", 1, nil))
+	require.NoError(t, f.Flush(context.Background()))
+	require.Len(t, up.received(), 1)
+	assert.Equal(t, []models.MetricJSON{counter("a", 2, nil)}, up.received()[0])
+}
+
+func TestForwarder_Run(t *testing.T) {
+	up := newUpstream(t)
+	f, err := New(Config{URL: up.URL, SecretKey: "secret"})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		f.Run(ctx)
+		close(done)
+	}()
+
+	// Without an interval every write is sent right away
+	f.Add(counter("requests", 1, nil))
+	assert.Eventually(t, func() bool { return len(up.received()) == 1 }, time.Second, 10*time.Millisecond)
+
+	body, err := json.Marshal(up.received()[0])
+	require.NoError(t, err)
+	up.mu.Lock()
+	assert.Equal(t, middleware.HmacSHA256(body, []byte("secret")), up.hash)
+	up.mu.Unlock()
+
+	cancel()
+	<-done
+}
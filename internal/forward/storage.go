@@ -0,0 +1,48 @@
+package forward
+
+import (
+	"context"
+
+	"github.com/runtime-metrics-course/internal/models"
+	"github.com/runtime-metrics-course/internal/storage"
+)
+
+// forwardingStorage passes the updates accepted by a storage to a forwarder
+type forwardingStorage struct {
+	storage.StorageIface
+	forwarder *Forwarder
+}
+
+// Wrap returns a storage that adds every update accepted by s to the
+// forwarder. Batches are forwarded only if the whole batch was accepted.
+func (f *Forwarder) Wrap(s storage.StorageIface) storage.StorageIface {
+	return &forwardingStorage{StorageIface: s, forwarder: f}
+}
+
+func (s *forwardingStorage) UpdateGauge(ctx context.Context, name string, value float64) error {
+	if err := s.StorageIface.UpdateGauge(ctx, name, value); err != nil {
+		return err
+	}
+	id, labels := models.ParseSeriesKey(name)
+	s.forwarder.Add(models.MetricJSON{ID: id, MType: models.Gauge, Value: &value, Labels: labels})
+	return nil
+}
+
+func (s *forwardingStorage) UpdateCounter(ctx context.Context, name string, value int64) error {
+	if err := s.StorageIface.UpdateCounter(ctx, name, value); err != nil {
+		return err
+	}
+	id, labels := models.ParseSeriesKey(name)
+	s.forwarder.Add(models.MetricJSON{ID: id, MType: models.Counter, Delta: &value, Labels: labels})
+	return nil
+}
+
+// UpdateAll forwards the batch only if the storage applied it. Storages
+// apply batches all-or-nothing, so a failed batch changed neither side.
+func (s *forwardingStorage) UpdateAll(ctx context.Context, metrics []models.MetricJSON) error {
+	if err := s.StorageIface.UpdateAll(ctx, metrics); err != nil {
+		return err
+	}
+	s.forwarder.Add(metrics...)
+	return nil
+}
@@ -0,0 +1,12 @@
+// Package influx parses the InfluxDB line protocol.
+//
+// A line holds a measurement with optional tags, one or more fields and an
+// optional timestamp:
+//
+//	cpu,host=web-1,region=eu usage_user=12.5,usage_system=3i 1700000000000000000
+//
+// Field values are floats (12.5), signed (3i) and unsigned (3u) integers,
+// double quoted strings and booleans (t, true, f, false, ...). Commas, spaces
+// and equal signs in names, tag keys and tag values are escaped with a
+// backslash. Lines starting with # are comments.
+package influx
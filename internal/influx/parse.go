@@ -0,0 +1,226 @@
+package influx
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/runtime-metrics-course/internal/models"
+)
+
+// Field is a field of a point. Value is a float64, int64, uint64, string or bool.
+type Field struct {
+	Value any
+	Key   string
+}
+
+// Point is a parsed line
+type Point struct {
+	Time        time.Time     // Timestamp of the point, zero if not set
+	Tags        models.Labels // Tag set
+	Measurement string        // Measurement name
+	Fields      []Field       // Fields in the order of the line
+}
+
+// ParsePrecision parses the precision of timestamps: n or ns, u or us, ms,
+// s, m and h. An empty precision means nanoseconds.
+func ParsePrecision(precision string) (time.Duration, error) {
+	switch precision {
+	case "", "n", "ns":
+		return time.Nanosecond, nil
+	case "u", "us", "µ":
+		return time.Microsecond, nil
+	case "ms":
+		return time.Millisecond, nil
+	case "s":
+		return time.Second, nil
+	case "m":
+		return time.Minute, nil
+	case "h":
+		return time.Hour, nil
+	}
+	return 0, fmt.Errorf("invalid precision %q", precision)
+}
+
+// ParseLine parses a line with timestamps in units of precision
+func ParseLine(line string, precision time.Duration) (Point, error) {
+	// Quotes are literal in the series, they only delimit string field values
+	series, err := split(line, ' ', false)
+	if err != nil {
+		return Point{}, err
+	}
+	if len(series) < 2 {
+		return Point{}, errors.New("missing fields")
+	}
+	rest := strings.TrimPrefix(line, series[0]+" ")
+	sections, err := split(rest, ' ', true)
+	if err != nil {
+		return Point{}, err
+	}
+	sections = append([]string{series[0]}, sections...)
+	if len(sections) > 3 {
+		return Point{}, errors.New("unexpected data after the timestamp")
+	}
+
+	var p Point
+	if err := p.parseSeries(sections[0]); err != nil {
+		return Point{}, err
+	}
+	if err := p.parseFields(sections[1]); err != nil {
+		return Point{}, err
+	}
+	if len(sections) == 3 {
+		ts, err := strconv.ParseInt(sections[2], 10, 64)
+		if err != nil {
+			return Point{}, fmt.Errorf("invalid timestamp %q", sections[2])
+		}
+		if ts > math.MaxInt64/int64(precision) || ts < math.MinInt64/int64(precision) {
+			return Point{}, fmt.Errorf("timestamp %q out of range", sections[2])
+		}
+		p.Time = time.Unix(0, ts*int64(precision))
+	}
+	return p, nil
+}
+
+// parseSeries parses the measurement and the tag set
+func (p *Point) parseSeries(series string) error {
+	parts, err := split(series, ',', false)
+	if err != nil {
+		return err
+	}
+	p.Measurement = unescape(parts[0])
+	if p.Measurement == "" {
+		return errors.New("missing measurement")
+	}
+
+	for _, tag := range parts[1:] {
+		kv, err := split(tag, '=', false)
+		if err != nil {
+			return err
+		}
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return fmt.Errorf("invalid tag %q", tag)
+		}
+		if p.Tags == nil {
+			p.Tags = make(models.Labels)
+		}
+		p.Tags[unescape(kv[0])] = unescape(kv[1])
+	}
+	return nil
+}
+
+// parseFields parses the field set
+func (p *Point) parseFields(fields string) error {
+	parts, err := split(fields, ',', true)
+	if err != nil {
+		return err
+	}
+	for _, field := range parts {
+		kv, err := cut(field)
+		if err != nil {
+			return err
+		}
+		if kv[0] == "" {
+			return fmt.Errorf("invalid field %q", field)
+		}
+		value, err := parseValue(kv[1])
+		if err != nil {
+			return fmt.Errorf("field %q: %w", unescape(kv[0]), err)
+		}
+		p.Fields = append(p.Fields, Field{Key: unescape(kv[0]), Value: value})
+	}
+	return nil
+}
+
+// parseValue parses a field value
+func parseValue(s string) (any, error) {
+	if s == "" {
+		return nil, errors.New("missing value")
+	}
+
+	switch s {
+	case "t", "T", "true", "True", "TRUE":
+		return true, nil
+	case "f", "F", "false", "False", "FALSE":
+		return false, nil
+	}
+
+	switch last := s[len(s)-1]; {
+	case s[0] == '"':
+		if len(s) < 2 || last != '"' {
+			return nil, fmt.Errorf("unterminated string %s", s)
+		}
+		return unescapeString(s[1 : len(s)-1]), nil
+	case last == 'i':
+		return strconv.ParseInt(s[:len(s)-1], 10, 64)
+	case last == 'u':
+		return strconv.ParseUint(s[:len(s)-1], 10, 64)
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, err
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil, fmt.Errorf("invalid value %s", s)
+	}
+	return v, nil
+}
+
+// split splits s on sep characters that are not escaped and, if quotes is
+// set, not inside a double quoted string. Escapes are kept in the parts.
+func split(s string, sep byte, quotes bool) ([]string, error) {
+	var (
+		parts  []string
+		start  int
+		quoted bool
+	)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			i++
+		case c == '"' && quotes:
+			quoted = !quoted
+		case c == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	if quoted {
+		return nil, errors.New("unterminated string")
+	}
+	return append(parts, s[start:]), nil
+}
+
+// cut splits a field into its key and value at the first unescaped equal sign
+func cut(field string) ([2]string, error) {
+	for i := 0; i < len(field); i++ {
+		switch field[i] {
+		case '\\':
+			i++
+		case '=':
+			return [2]string{field[:i], field[i+1:]}, nil
+		}
+	}
+	return [2]string{}, fmt.Errorf("invalid field %q", field)
+}
+
+// unescaper removes the escapes of names, tag keys and tag values
+var unescaper = strings.NewReplacer(`\,`, ",", `\ `, " ", `\=`, "=", `\"`, `"`, `\\`, `\`)
+
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	return unescaper.Replace(s)
+}
+
+// stringUnescaper removes the escapes of string field values
+var stringUnescaper = strings.NewReplacer(`\"`, `"`, `\\`, `\`)
+
+func unescapeString(s string) string {
+	return stringUnescaper.Replace(s)
+}
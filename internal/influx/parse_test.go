@@ -0,0 +1,112 @@
+package influx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/runtime-metrics-course/internal/models"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		precision time.Duration
+		expected  Point
+		expectErr bool
+	}{
+		{
+			name:     "Single field",
+			line:     "temperature value=21.5",
+			expected: Point{Measurement: "temperature", Fields: []Field{{Key: "value", Value: 21.5}}},
+		},
+		{
+			name: "Tags, field types and timestamp",
+			line: `cpu,host=web-1,region=eu user=12.5,procs=3i,threads=4u,up=t,state="running" 1700000000000000000`,
+			expected: Point{
+				Measurement: "cpu",
+				Tags:        models.Labels{"host": "web-1", "region": "eu"},
+				Fields: []Field{
+					{Key: "user", Value: 12.5},
+					{Key: "procs", Value: int64(3)},
+					{Key: "threads", Value: uint64(4)},
+					{Key: "up", Value: true},
+					{Key: "state", Value: "running"},
+				},
+				Time: time.Unix(0, 1700000000000000000),
+			},
+		},
+		{
+			name:      "Timestamp precision",
+			line:      "load value=1 1700000000",
+			precision: time.Second,
+			expected:  Point{Measurement: "load", Fields: []Field{{Key: "value", Value: 1.0}}, Time: time.Unix(1700000000, 0)},
+		},
+		{
+			name: "Escapes",
+			line: `disk\ io,path=/var\,log,mode\=x=a\ b read\ bytes=1i,msg="say \"hi\", ok"`,
+			expected: Point{
+				Measurement: "disk io",
+				Tags:        models.Labels{"path": "/var,log", "mode=x": "a b"},
+				Fields: []Field{
+					{Key: "read bytes", Value: int64(1)},
+					{Key: "msg", Value: `say "hi", ok`},
+				},
+			},
+		},
+		{
+			name:     "Quote in tag value",
+			line:     `net,iface=a"b value=1`,
+			expected: Point{Measurement: "net", Tags: models.Labels{"iface": `a"b`}, Fields: []Field{{Key: "value", Value: 1.0}}},
+		},
+		{name: "No fields", line: "cpu", expectErr: true},
+		{name: "No fields with tags", line: "cpu,host=a", expectErr: true},
+		{name: "Empty measurement", line: ",host=a value=1", expectErr: true},
+		{name: "Tag without value", line: "cpu,host value=1", expectErr: true},
+		{name: "Field without value", line: "cpu value=", expectErr: true},
+		{name: "Field without key", line: "cpu =1", expectErr: true},
+		{name: "Invalid number", line: "cpu value=abc", expectErr: true},
+		{name: "Invalid integer", line: "cpu value=1.5i", expectErr: true},
+		{name: "Unterminated string", line: `cpu msg="abc`, expectErr: true},
+		{name: "Invalid timestamp", line: "cpu value=1 yesterday", expectErr: true},
+		{name: "Timestamp out of range", line: "cpu value=1 9223372036854775807", precision: time.Second, expectErr: true},
+		{name: "Trailing data", line: "cpu value=1 1 2", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			precision := tt.precision
+			if precision == 0 {
+				precision = time.Nanosecond
+			}
+			p, err := ParseLine(tt.line, precision)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, p)
+		})
+	}
+}
+
+func TestParsePrecision(t *testing.T) {
+	for precision, expected := range map[string]time.Duration{
+		"":   time.Nanosecond,
+		"ns": time.Nanosecond,
+		"u":  time.Microsecond,
+		"ms": time.Millisecond,
+		"s":  time.Second,
+		"h":  time.Hour,
+	} {
+		d, err := ParsePrecision(precision)
+		require.NoError(t, err, precision)
+		assert.Equal(t, expected, d, precision)
+	}
+
+	_, err := ParsePrecision("d")
+	assert.Error(t, err)
+}
@@ -5,35 +5,33 @@ import (
 	"bytes"
 	"crypto/rand"
 	"crypto/rsa"
-	"crypto/x509"
-	"fmt"
 	"io"
 	"net/http"
-	"os"
 
+	"github.com/runtime-metrics-course/internal/encryption"
 	"github.com/runtime-metrics-course/internal/logger"
 )
 
+// CryptoMiddleware decrypts request bodies encrypted with the server's public key
 type CryptoMiddleware struct {
 	privateKey *rsa.PrivateKey
 }
 
+// NewCryptoMiddleware creates a middleware with the private key read from a PEM
+// (or legacy raw DER) file, see encryption.LoadPrivateKey
 func NewCryptoMiddleware(privateKeyPath string) (*CryptoMiddleware, error) {
-	keyBytes, err := os.ReadFile(privateKeyPath)
+	privateKey, err := encryption.LoadPrivateKey(privateKeyPath)
 	if err != nil {
 		logger.Log.Error(err.Error())
-		return nil, fmt.Errorf("failed to read private key: %w", err)
-	}
-
-	privateKey, err := x509.ParsePKCS1PrivateKey(keyBytes)
-	if err != nil {
-		logger.Log.Error(err.Error())
-		return nil, fmt.Errorf("failed to parse private key: %w", err)
+		return nil, err
 	}
 
 	return &CryptoMiddleware{privateKey: privateKey}, nil
 }
 
+// Middleware decrypts non-empty request bodies. Bodies marked with the
+// encryption.VersionHeader are opened as hybrid envelopes; unmarked bodies
+// from older agents are decrypted with RSA PKCS #1 v1.5.
 func (m *CryptoMiddleware) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		encryptedData, err := io.ReadAll(r.Body)
@@ -42,14 +40,29 @@ func (m *CryptoMiddleware) Middleware(next http.Handler) http.Handler {
 			http.Error(w, "Failed to read encrypted body", http.StatusBadRequest)
 			return
 		}
+		if len(encryptedData) == 0 {
+			r.Body = io.NopCloser(bytes.NewReader(encryptedData))
+			next.ServeHTTP(w, r)
+			return
+		}
 
-		decryptedData, err := rsa.DecryptPKCS1v15(rand.Reader, m.privateKey, encryptedData)
+		var decryptedData []byte
+		switch version := r.Header.Get(encryption.VersionHeader); version {
+		case encryption.Version:
+			decryptedData, err = encryption.Open(m.privateKey, encryptedData)
+		case "":
+			decryptedData, err = rsa.DecryptPKCS1v15(rand.Reader, m.privateKey, encryptedData)
+		default:
+			http.Error(w, "Unsupported encryption version", http.StatusBadRequest)
+			return
+		}
 		if err != nil {
 			logger.Log.Error(err.Error())
 			http.Error(w, "Failed to decrypt data", http.StatusBadRequest)
 			return
 		}
 
+		r.Header.Del(encryption.VersionHeader)
 		r.Body = io.NopCloser(bytes.NewReader(decryptedData))
 		r.ContentLength = int64(len(decryptedData))
 
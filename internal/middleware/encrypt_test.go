@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/runtime-metrics-course/internal/encryption"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCryptoMiddleware(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	keyPath := filepath.Join(t.TempDir(), "private.pem")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
+	require.NoError(t, os.WriteFile(keyPath, keyPEM, 0o600))
+
+	m, err := NewCryptoMiddleware(keyPath)
+	require.NoError(t, err)
+
+	var received []byte
+	handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+	}))
+
+	small := []byte(`{"id":"Alloc","type":"gauge","value":1}`)
+	large := bytes.Repeat(small, 100)
+
+	envelope, err := encryption.Seal(&priv.PublicKey, large)
+	require.NoError(t, err)
+	legacy, err := rsa.EncryptPKCS1v15(rand.Reader, &priv.PublicKey, small)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		body     []byte
+		version  string
+		wantCode int
+		wantBody []byte
+	}{
+		{name: "Hybrid envelope", body: envelope, version: encryption.Version, wantCode: http.StatusOK, wantBody: large},
+		{name: "Legacy PKCS1v15", body: legacy, wantCode: http.StatusOK, wantBody: small},
+		{name: "Empty body", body: nil, wantCode: http.StatusOK, wantBody: []byte{}},
+		{name: "Envelope without marker", body: envelope, wantCode: http.StatusBadRequest},
+		{name: "Legacy body with marker", body: legacy, version: encryption.Version, wantCode: http.StatusBadRequest},
+		{name: "Unknown version", body: envelope, version: "3", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received = nil
+			req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(tt.body))
+			if tt.version != "" {
+				req.Header.Set(encryption.VersionHeader, tt.version)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode == http.StatusOK {
+				assert.Equal(t, string(tt.wantBody), string(received))
+			}
+		})
+	}
+}
+
+func TestNewCryptoMiddleware_InvalidKey(t *testing.T) {
+	_, err := NewCryptoMiddleware(filepath.Join(t.TempDir(), "missing.pem"))
+	require.Error(t, err)
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/runtime-metrics-course/internal/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// LoggerUnaryInterceptor logs every unary gRPC call like LoggerMiddleware logs HTTP requests
+func LoggerUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+
+	logger.Log.Sugar().Infoln(
+		"method", info.FullMethod,
+		"code", status.Code(err),
+		"duration", time.Since(start),
+		"client", grpcClientIdentity(ctx),
+	)
+	return resp, err
+}
+
+// LoggerStreamInterceptor logs every streaming gRPC call like LoggerMiddleware logs HTTP requests
+func LoggerStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+
+	logger.Log.Sugar().Infoln(
+		"method", info.FullMethod,
+		"code", status.Code(err),
+		"duration", time.Since(start),
+		"client", grpcClientIdentity(ss.Context()),
+	)
+	return err
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/runtime-metrics-course/internal/tlsconfig"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// clientIdentityKey is the context key of the client certificate identity
+type clientIdentityKey struct{}
+
+// WithClientIdentity returns a copy of ctx carrying the client identity
+func WithClientIdentity(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, clientIdentityKey{}, identity)
+}
+
+// ClientIdentity returns the identity of the client certificate that sent the
+// request, or an empty string for requests without a verified client certificate
+func ClientIdentity(ctx context.Context) string {
+	identity, _ := ctx.Value(clientIdentityKey{}).(string)
+	return identity
+}
+
+// ClientIdentityMiddleware stores the identity of a verified TLS client
+// certificate in the request context (see ClientIdentity)
+func ClientIdentityMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && len(r.TLS.VerifiedChains) != 0 {
+			identity := tlsconfig.Identity(r.TLS.VerifiedChains[0][0])
+			r = r.WithContext(WithClientIdentity(r.Context(), identity))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// grpcClientIdentity returns the identity of the verified client certificate of a gRPC call
+func grpcClientIdentity(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 {
+		return ""
+	}
+	return tlsconfig.Identity(info.State.VerifiedChains[0][0])
+}
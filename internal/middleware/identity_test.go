@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIdentityMiddleware(t *testing.T) {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "agent-1"}}
+
+	tests := []struct {
+		name         string
+		state        *tls.ConnectionState
+		wantIdentity string
+	}{
+		{name: "Plain HTTP", state: nil},
+		{name: "TLS without client certificate", state: &tls.ConnectionState{}},
+		{name: "Verified client certificate", state: &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}, wantIdentity: "agent-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var identity string
+			handler := ClientIdentityMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				identity = ClientIdentity(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/updates/", nil)
+			req.TLS = tt.state
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			assert.Equal(t, tt.wantIdentity, identity)
+		})
+	}
+}
@@ -26,6 +26,8 @@ func (r *loggerResponseWriter) Write(b []byte) (int, error) {
 	return size, err
 }
 
+// LoggerMiddleware logs every request with its status, size, duration and the
+// client certificate identity set by ClientIdentityMiddleware
 func LoggerMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -41,6 +43,7 @@ func LoggerMiddleware(next http.Handler) http.Handler {
 			"status", lw.respData.statusCode,
 			"size", lw.respData.size,
 			"duration", time.Since(start),
+			"client", ClientIdentity(r.Context()),
 		)
 	})
 }
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/runtime-metrics-course/internal/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RealIPHeader carries the client address checked against the trusted subnet.
+// gRPC clients send it as metadata under the same (lowercased) key.
+const RealIPHeader = "X-Real-IP"
+
+// TrustedSubnetMiddleware rejects write requests from clients outside a trusted subnet.
+// The client address is taken from the X-Real-IP header (x-real-ip metadata for gRPC).
+type TrustedSubnetMiddleware struct {
+	subnet *net.IPNet
+}
+
+// NewTrustedSubnetMiddleware creates a middleware trusting the subnet given in CIDR notation
+func NewTrustedSubnetMiddleware(cidr string) (*TrustedSubnetMiddleware, error) {
+	_, subnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted subnet: %w", err)
+	}
+	return &TrustedSubnetMiddleware{subnet: subnet}, nil
+}
+
+// Trusted reports whether ip is a valid address inside the trusted subnet
+func (m *TrustedSubnetMiddleware) Trusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	return parsed != nil && m.subnet.Contains(parsed)
+}
+
+// Middleware responds 403 Forbidden to write requests (POST, PUT, PATCH, DELETE)
+// without a trusted X-Real-IP. Read requests are passed through.
+func (m *TrustedSubnetMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+			if ip := r.Header.Get(RealIPHeader); !m.Trusted(ip) {
+				logger.Log.Sugar().Warnf("rejected %s %s from untrusted address %q", r.Method, r.URL.Path, ip)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// UnaryInterceptor rejects unary gRPC calls without trusted x-real-ip metadata
+func (m *TrustedSubnetMiddleware) UnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := m.checkMetadata(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamInterceptor rejects streaming gRPC calls without trusted x-real-ip metadata
+func (m *TrustedSubnetMiddleware) StreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := m.checkMetadata(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// checkMetadata returns a PermissionDenied status unless the call comes from the trusted subnet
+func (m *TrustedSubnetMiddleware) checkMetadata(ctx context.Context, method string) error {
+	var ip string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RealIPHeader); len(values) != 0 {
+			ip = values[0]
+		}
+	}
+	if !m.Trusted(ip) {
+		logger.Log.Sugar().Warnf("rejected %s from untrusted address %q", method, ip)
+		return status.Error(codes.PermissionDenied, "untrusted address")
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestNewTrustedSubnetMiddleware(t *testing.T) {
+	_, err := NewTrustedSubnetMiddleware("192.168.1.0/24")
+	require.NoError(t, err)
+
+	_, err = NewTrustedSubnetMiddleware("192.168.1.0")
+	require.Error(t, err)
+}
+
+func TestTrustedSubnetMiddleware(t *testing.T) {
+	m, err := NewTrustedSubnetMiddleware("192.168.1.0/24")
+	require.NoError(t, err)
+
+	handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name     string
+		method   string
+		realIP   string
+		wantCode int
+	}{
+		{name: "Trusted write", method: http.MethodPost, realIP: "192.168.1.10", wantCode: http.StatusOK},
+		{name: "Untrusted write", method: http.MethodPost, realIP: "10.0.0.1", wantCode: http.StatusForbidden},
+		{name: "Write without header", method: http.MethodPost, wantCode: http.StatusForbidden},
+		{name: "Write with invalid address", method: http.MethodPut, realIP: "not-an-ip", wantCode: http.StatusForbidden},
+		{name: "Read from untrusted address", method: http.MethodGet, realIP: "10.0.0.1", wantCode: http.StatusOK},
+		{name: "Read without header", method: http.MethodGet, wantCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/update/", nil)
+			if tt.realIP != "" {
+				req.Header.Set(RealIPHeader, tt.realIP)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			assert.Equal(t, tt.wantCode, w.Code)
+		})
+	}
+}
+
+func TestTrustedSubnetMiddleware_UnaryInterceptor(t *testing.T) {
+	m, err := NewTrustedSubnetMiddleware("10.0.0.0/8")
+	require.NoError(t, err)
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/metrics.Metrics/UpdateMetrics"}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return "ok", nil
+	}
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		wantCode codes.Code
+	}{
+		{name: "Trusted", ctx: metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-real-ip", "10.1.2.3")), wantCode: codes.OK},
+		{name: "Untrusted", ctx: metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-real-ip", "192.168.0.1")), wantCode: codes.PermissionDenied},
+		{name: "No metadata", ctx: context.Background(), wantCode: codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := m.UnaryInterceptor(tt.ctx, nil, info, handler)
+			assert.Equal(t, tt.wantCode, status.Code(err))
+		})
+	}
+}
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- name holds the series key (metric name plus labels), which can exceed 255 characters
+ALTER TABLE metrics ALTER COLUMN name TYPE TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE metrics ALTER COLUMN name TYPE VARCHAR(255);
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS metrics_history (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    type VARCHAR(50) NOT NULL,
+    value DOUBLE PRECISION,
+    delta BIGINT,
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS metrics_history_series_idx ON metrics_history (name, type, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS metrics_history;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- distribution holds the JSON encoded state of histogram and summary metrics
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS distribution JSONB;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE metrics DROP COLUMN IF EXISTS distribution;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- a series key can be used by metrics of different types, so rows are unique by name and type
+ALTER TABLE metrics DROP CONSTRAINT IF EXISTS metrics_name_key;
+ALTER TABLE metrics ADD CONSTRAINT metrics_name_type_key UNIQUE (name, type);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM metrics a USING metrics b WHERE a.name = b.name AND a.id > b.id;
+ALTER TABLE metrics DROP CONSTRAINT IF EXISTS metrics_name_type_key;
+ALTER TABLE metrics ADD CONSTRAINT metrics_name_key UNIQUE (name);
+-- +goose StatementEnd
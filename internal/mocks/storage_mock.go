@@ -5,6 +5,8 @@ package mocks
 import (
 	context "context"
 
+	time "time"
+
 	models "github.com/runtime-metrics-course/internal/models"
 	mock "github.com/stretchr/testify/mock"
 )
@@ -14,6 +16,64 @@ type StorageIface struct {
 	mock.Mock
 }
 
+// GetHistory provides a mock function with given fields: ctx, mType, name, from, to
+func (_m *StorageIface) GetHistory(ctx context.Context, mType string, name string, from time.Time, to time.Time) ([]models.Point, error) {
+	ret := _m.Called(ctx, mType, name, from, to)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetHistory")
+	}
+
+	var r0 []models.Point
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time, time.Time) ([]models.Point, error)); ok {
+		return rf(ctx, mType, name, from, to)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time, time.Time) []models.Point); ok {
+		r0 = rf(ctx, mType, name, from, to)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Point)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, time.Time, time.Time) error); ok {
+		r1 = rf(ctx, mType, name, from, to)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetMetric provides a mock function with given fields: ctx, mType, name
+func (_m *StorageIface) GetMetric(ctx context.Context, mType string, name string) (models.MetricJSON, error) {
+	ret := _m.Called(ctx, mType, name)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetMetric")
+	}
+
+	var r0 models.MetricJSON
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (models.MetricJSON, error)); ok {
+		return rf(ctx, mType, name)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) models.MetricJSON); ok {
+		r0 = rf(ctx, mType, name)
+	} else {
+		r0 = ret.Get(0).(models.MetricJSON)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, mType, name)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetMetrics provides a mock function with given fields: ctx
 func (_m *StorageIface) GetMetrics(ctx context.Context) (models.Metrics, error) {
 	ret := _m.Called(ctx)
@@ -60,6 +120,34 @@ func (_m *StorageIface) Ping(ctx context.Context) error {
 	return r0
 }
 
+// Query provides a mock function with given fields: ctx, query
+func (_m *StorageIface) Query(ctx context.Context, query models.MetricQuery) (models.MetricPage, error) {
+	ret := _m.Called(ctx, query)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Query")
+	}
+
+	var r0 models.MetricPage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, models.MetricQuery) (models.MetricPage, error)); ok {
+		return rf(ctx, query)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, models.MetricQuery) models.MetricPage); ok {
+		r0 = rf(ctx, query)
+	} else {
+		r0 = ret.Get(0).(models.MetricPage)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, models.MetricQuery) error); ok {
+		r1 = rf(ctx, query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // UpdateAll provides a mock function with given fields: ctx, metrics
 func (_m *StorageIface) UpdateAll(ctx context.Context, metrics []models.MetricJSON) error {
 	ret := _m.Called(ctx, metrics)
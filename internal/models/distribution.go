@@ -0,0 +1,166 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Distribution metric type constants
+const (
+	Histogram = "histogram" // Histogram metric type (observations counted in fixed buckets)
+	Summary   = "summary"   // Summary metric type (quantiles over a sliding window)
+)
+
+// Summary sliding window parameters
+const (
+	SummaryWindow          = 10 * time.Minute // How long observations are kept for quantiles
+	SummaryMaxObservations = 1024             // Maximum observations kept per series
+)
+
+// SummaryQuantiles are the quantiles reported for summary metrics
+var SummaryQuantiles = []float64{0.5, 0.9, 0.99}
+
+// Histograms represents a collection of histogram metrics keyed by series key
+type Histograms map[string]HistogramValue
+
+// Summaries represents a collection of summary metrics keyed by series key
+type Summaries map[string]SummaryValue
+
+// HistogramValue holds the cumulative state of a histogram metric
+type HistogramValue struct {
+	Bounds []float64 `json:"bounds"` // Upper bounds of the buckets in ascending order
+	Counts []uint64  `json:"counts"` // Observations per bucket, the last one is the +Inf bucket
+	Sum    float64   `json:"sum"`    // Sum of all observations
+	Count  uint64    `json:"count"`  // Number of all observations
+}
+
+// Observation is a single summary observation
+type Observation struct {
+	Time  time.Time `json:"time"`  // When the observation was received
+	Value float64   `json:"value"` // Observed value
+}
+
+// SummaryValue holds the state of a summary metric
+type SummaryValue struct {
+	Window []Observation `json:"window"` // Observations of the sliding window, ordered by time
+	Sum    float64       `json:"sum"`    // Sum of all observations
+	Count  uint64        `json:"count"`  // Number of all observations
+}
+
+// ValidateHistogram checks that bucket bounds are ascending and that there is
+// one count per bucket plus the +Inf bucket
+func ValidateHistogram(bounds []float64, counts []uint64) error {
+	if len(counts) != len(bounds)+1 {
+		return fmt.Errorf("expected %d bucket counts, got %d", len(bounds)+1, len(counts))
+	}
+	for i := 1; i < len(bounds); i++ {
+		if !(bounds[i] > bounds[i-1]) {
+			return errors.New("bucket bounds must be ascending")
+		}
+	}
+	return nil
+}
+
+// Merge adds bucket counts and the sum of observations to the histogram.
+// An empty histogram takes the bucket layout of the update; otherwise the
+// layouts must match.
+func (h *HistogramValue) Merge(bounds []float64, counts []uint64, sum float64) error {
+	if err := ValidateHistogram(bounds, counts); err != nil {
+		return err
+	}
+
+	if h.Counts == nil {
+		h.Bounds = append([]float64(nil), bounds...)
+		h.Counts = make([]uint64, len(counts))
+	} else if !slices.Equal(h.Bounds, bounds) {
+		return errors.New("bucket bounds differ from the stored histogram")
+	}
+
+	for i, c := range counts {
+		h.Counts[i] += c
+		h.Count += c
+	}
+	h.Sum += sum
+	return nil
+}
+
+// Copy returns a deep copy of the histogram
+func (h HistogramValue) Copy() HistogramValue {
+	h.Bounds = append([]float64(nil), h.Bounds...)
+	h.Counts = append([]uint64(nil), h.Counts...)
+	return h
+}
+
+// Observe adds observations to the sliding window and to the totals.
+// sum and count are added to the totals as is, which allows producers to
+// report exact totals alongside a sample of observations.
+// Observations older than SummaryWindow or beyond SummaryMaxObservations are dropped.
+func (s *SummaryValue) Observe(values []float64, sum float64, count uint64, now time.Time) {
+	for _, v := range values {
+		s.Window = append(s.Window, Observation{Time: now, Value: v})
+	}
+	s.Sum += sum
+	s.Count += count
+	s.trim(now)
+}
+
+// trim drops observations that left the sliding window
+func (s *SummaryValue) trim(now time.Time) {
+	cutoff := now.Add(-SummaryWindow)
+	start := sort.Search(len(s.Window), func(i int) bool {
+		return !s.Window[i].Time.Before(cutoff)
+	})
+	if n := len(s.Window) - start; n > SummaryMaxObservations {
+		start = len(s.Window) - SummaryMaxObservations
+	}
+	if start > 0 {
+		s.Window = append(s.Window[:0:0], s.Window[start:]...)
+	}
+}
+
+// Quantile returns the q-quantile (0 <= q <= 1) of the observations in the
+// sliding window using the nearest-rank method. Returns NaN if the window is empty.
+func (s SummaryValue) Quantile(q float64) float64 {
+	if len(s.Window) == 0 {
+		return math.NaN()
+	}
+
+	values := make([]float64, len(s.Window))
+	for i, o := range s.Window {
+		values[i] = o.Value
+	}
+	sort.Float64s(values)
+
+	rank := int(math.Ceil(q*float64(len(values)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(values) {
+		rank = len(values) - 1
+	}
+	return values[rank]
+}
+
+// Quantiles returns the SummaryQuantiles of the sliding window keyed by the
+// quantile formatted as a string (e.g. "0.99"). Returns nil if the window is empty.
+func (s SummaryValue) Quantiles() map[string]float64 {
+	if len(s.Window) == 0 {
+		return nil
+	}
+	quantiles := make(map[string]float64, len(SummaryQuantiles))
+	for _, q := range SummaryQuantiles {
+		quantiles[strconv.FormatFloat(q, 'g', -1, 64)] = s.Quantile(q)
+	}
+	return quantiles
+}
+
+// Copy returns a deep copy of the summary
+func (s SummaryValue) Copy() SummaryValue {
+	s.Window = append([]Observation(nil), s.Window...)
+	return s
+}
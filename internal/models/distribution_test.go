@@ -0,0 +1,62 @@
+package models
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogramMerge(t *testing.T) {
+	var h HistogramValue
+	require.NoError(t, h.Merge([]float64{1, 5}, []uint64{1, 2, 0}, 8))
+	require.NoError(t, h.Merge([]float64{1, 5}, []uint64{0, 1, 3}, 40))
+
+	assert.Equal(t, []float64{1, 5}, h.Bounds)
+	assert.Equal(t, []uint64{1, 3, 3}, h.Counts)
+	assert.Equal(t, uint64(7), h.Count)
+	assert.Equal(t, 48.0, h.Sum)
+
+	assert.Error(t, h.Merge([]float64{1, 10}, []uint64{0, 1, 0}, 1), "layout mismatch")
+	assert.Error(t, h.Merge([]float64{1, 5}, []uint64{0, 1}, 1), "wrong number of counts")
+	assert.Error(t, ValidateHistogram([]float64{5, 1}, []uint64{0, 0, 0}), "descending bounds")
+}
+
+func TestSummaryQuantiles(t *testing.T) {
+	var s SummaryValue
+	now := time.Now()
+
+	assert.True(t, math.IsNaN(s.Quantile(0.5)))
+	assert.Nil(t, s.Quantiles())
+
+	values := make([]float64, 100)
+	for i := range values {
+		values[i] = float64(i + 1)
+	}
+	s.Observe(values, 5050, 100, now)
+
+	assert.Equal(t, 50.0, s.Quantile(0.5))
+	assert.Equal(t, 90.0, s.Quantile(0.9))
+	assert.Equal(t, 99.0, s.Quantile(0.99))
+	assert.Equal(t, map[string]float64{"0.5": 50, "0.9": 90, "0.99": 99}, s.Quantiles())
+	assert.Equal(t, uint64(100), s.Count)
+}
+
+func TestSummarySlidingWindow(t *testing.T) {
+	var s SummaryValue
+	now := time.Now()
+
+	s.Observe([]float64{100}, 100, 1, now.Add(-2*SummaryWindow))
+	s.Observe([]float64{1}, 1, 1, now)
+
+	assert.Len(t, s.Window, 1)
+	assert.Equal(t, 1.0, s.Quantile(0.99))
+	// Totals keep all observations
+	assert.Equal(t, uint64(2), s.Count)
+	assert.Equal(t, 101.0, s.Sum)
+
+	s.Observe(make([]float64, SummaryMaxObservations+10), 0, SummaryMaxObservations+10, now)
+	assert.Len(t, s.Window, SummaryMaxObservations)
+}
@@ -0,0 +1,90 @@
+package models
+
+import "slices"
+
+// CopyMetric returns a deep copy of a metric, so that the copy shares no
+// slices or pointers with the original
+func CopyMetric(m MetricJSON) MetricJSON {
+	if m.Delta != nil {
+		delta := *m.Delta
+		m.Delta = &delta
+	}
+	if m.Value != nil {
+		value := *m.Value
+		m.Value = &value
+	}
+	if m.Sum != nil {
+		sum := *m.Sum
+		m.Sum = &sum
+	}
+	if m.Count != nil {
+		count := *m.Count
+		m.Count = &count
+	}
+	m.Buckets = slices.Clone(m.Buckets)
+	m.Counts = slices.Clone(m.Counts)
+	m.Observations = slices.Clone(m.Observations)
+	return m
+}
+
+// MergeMetric combines an aggregated metric with a newer update of the same
+// series and returns the result without changing either of them:
+//   - gauges take the newer value
+//   - counter deltas are summed
+//   - histogram bucket counts, sums and counts are added; a histogram with a
+//     new bucket layout replaces the older one
+//   - summary observations are appended; sums and counts are added if either
+//     update sets them (see SummaryTotals)
+func MergeMetric(prev, next MetricJSON) MetricJSON {
+	next = CopyMetric(next)
+	switch next.MType {
+	case Counter:
+		if prev.Delta != nil && next.Delta != nil {
+			*next.Delta += *prev.Delta
+		}
+	case Histogram:
+		if !slices.Equal(prev.Buckets, next.Buckets) || len(prev.Counts) != len(next.Counts) {
+			// Bucket layout changed, older observations cannot be combined
+			return next
+		}
+		for i := range next.Counts {
+			next.Counts[i] += prev.Counts[i]
+		}
+		next.Sum = addFloat(prev.Sum, next.Sum)
+		next.Count = addUint(prev.Count, next.Count)
+	case Summary:
+		hasTotals := prev.Sum != nil || prev.Count != nil || next.Sum != nil || next.Count != nil
+		if hasTotals {
+			prevSum, prevCount := prev.SummaryTotals()
+			nextSum, nextCount := next.SummaryTotals()
+			sum, count := prevSum+nextSum, prevCount+nextCount
+			next.Sum, next.Count = &sum, &count
+		}
+		next.Observations = append(slices.Clone(prev.Observations), next.Observations...)
+	}
+	return next
+}
+
+// addFloat returns the sum of two optional values without sharing the memory of a
+func addFloat(a, b *float64) *float64 {
+	if a == nil {
+		return b
+	}
+	sum := *a
+	if b != nil {
+		sum += *b
+	}
+	return &sum
+}
+
+// addUint returns the sum of two optional values without sharing the memory of a
+func addUint(a, b *uint64) *uint64 {
+	if a == nil {
+		return b
+	}
+	sum := *a
+	if b != nil {
+		sum += *b
+	}
+	return &sum
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeMetric(t *testing.T) {
+	delta1, delta2 := int64(1), int64(2)
+	sum1, sum2 := 3.0, 4.0
+	count1, count2 := uint64(1), uint64(2)
+
+	tests := []struct {
+		name     string
+		prev     MetricJSON
+		next     MetricJSON
+		expected MetricJSON
+	}{
+		{
+			name:     "Counter deltas are summed",
+			prev:     MetricJSON{ID: "c", MType: Counter, Delta: &delta1},
+			next:     MetricJSON{ID: "c", MType: Counter, Delta: &delta2},
+			expected: MetricJSON{ID: "c", MType: Counter, Delta: ptr(int64(3))},
+		},
+		{
+			name:     "Gauge takes the newer value",
+			prev:     MetricJSON{ID: "g", MType: Gauge, Value: &sum1},
+			next:     MetricJSON{ID: "g", MType: Gauge, Value: &sum2},
+			expected: MetricJSON{ID: "g", MType: Gauge, Value: ptr(4.0)},
+		},
+		{
+			name:     "Histogram counts are added",
+			prev:     MetricJSON{ID: "h", MType: Histogram, Buckets: []float64{1}, Counts: []uint64{1, 0}, Sum: &sum1, Count: &count1},
+			next:     MetricJSON{ID: "h", MType: Histogram, Buckets: []float64{1}, Counts: []uint64{1, 1}, Sum: &sum2, Count: &count2},
+			expected: MetricJSON{ID: "h", MType: Histogram, Buckets: []float64{1}, Counts: []uint64{2, 1}, Sum: ptr(7.0), Count: ptr(uint64(3))},
+		},
+		{
+			name:     "Histogram with a new layout replaces the older one",
+			prev:     MetricJSON{ID: "h", MType: Histogram, Buckets: []float64{1}, Counts: []uint64{1, 0}},
+			next:     MetricJSON{ID: "h", MType: Histogram, Buckets: []float64{2}, Counts: []uint64{0, 1}},
+			expected: MetricJSON{ID: "h", MType: Histogram, Buckets: []float64{2}, Counts: []uint64{0, 1}},
+		},
+		{
+			name:     "Summary observations are appended",
+			prev:     MetricJSON{ID: "s", MType: Summary, Observations: []float64{1}},
+			next:     MetricJSON{ID: "s", MType: Summary, Observations: []float64{2, 3}},
+			expected: MetricJSON{ID: "s", MType: Summary, Observations: []float64{1, 2, 3}},
+		},
+		{
+			name:     "Summary totals default to the observations",
+			prev:     MetricJSON{ID: "s", MType: Summary, Observations: []float64{1}},
+			next:     MetricJSON{ID: "s", MType: Summary, Observations: []float64{2}, Sum: &sum2, Count: &count2},
+			expected: MetricJSON{ID: "s", MType: Summary, Observations: []float64{1, 2}, Sum: ptr(5.0), Count: ptr(uint64(3))},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, MergeMetric(tt.prev, tt.next))
+		})
+	}
+
+	// Inputs are unchanged
+	assert.Equal(t, int64(1), delta1)
+	assert.Equal(t, 3.0, sum1)
+	assert.Equal(t, uint64(1), count1)
+}
+
+func TestCopyMetric(t *testing.T) {
+	sum, count := 1.0, uint64(2)
+	m := MetricJSON{ID: "h", MType: Histogram, Buckets: []float64{1}, Counts: []uint64{1, 1}, Sum: &sum, Count: &count}
+
+	c := CopyMetric(m)
+	*c.Sum, *c.Count = 5, 6
+	c.Buckets[0], c.Counts[0] = 5, 6
+
+	assert.Equal(t, 1.0, sum)
+	assert.Equal(t, uint64(2), count)
+	assert.Equal(t, []float64{1}, m.Buckets)
+	assert.Equal(t, []uint64{1, 1}, m.Counts)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -1,9 +1,15 @@
 // Package models defines the core data structures and operations for metrics handling.
 // It includes types for both in-memory and JSON representations of metrics.
+// Supported metric types are gauges, counters, histograms and summaries.
 package models
 
 import (
 	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/runtime-metrics-course/internal/logger"
 )
@@ -14,25 +20,56 @@ const (
 	Counter = "counter" // Counter metric type (monotonically increasing values)
 )
 
-// Gauges represents a collection of gauge metrics
+// Labels is a set of dimensions attached to a metric (e.g. {"cpu": "3", "host": "web-1"})
+type Labels map[string]string
+
+// Gauges represents a collection of gauge metrics keyed by series key (see SeriesKey)
 type Gauges map[string]float64
 
-// Counters represents a collection of counter metrics
+// Counters represents a collection of counter metrics keyed by series key (see SeriesKey)
 type Counters map[string]int64
 
 // Metrics aggregates all collected metrics
 type Metrics struct {
-	Gauges   Gauges   // Map of gauge metrics
-	Counters Counters // Map of counter metrics
+	Gauges     Gauges     // Map of gauge metrics
+	Counters   Counters   // Map of counter metrics
+	Histograms Histograms // Map of histogram metrics
+	Summaries  Summaries  // Map of summary metrics
 }
 
 // MetricJSON represents a metric in JSON format for API communication
 type MetricJSON struct {
-	Delta *int64   `json:"delta,omitempty"` // Value for counter metrics
-	Value *float64 `json:"value,omitempty"` // Value for gauge metrics
-	ID    string   `json:"id"`              // Metric name
-	MType string   `json:"type"`            // Metric type (gauge or counter)
+	Delta        *int64             `json:"delta,omitempty"`        // Value for counter metrics
+	Value        *float64           `json:"value,omitempty"`        // Value for gauge metrics
+	Sum          *float64           `json:"sum,omitempty"`          // Sum of observations (histogram, summary)
+	Count        *uint64            `json:"count,omitempty"`        // Number of observations (histogram, summary)
+	Labels       Labels             `json:"labels,omitempty"`       // Optional metric dimensions
+	Quantiles    map[string]float64 `json:"quantiles,omitempty"`    // Summary quantiles, set in responses
+	Buckets      []float64          `json:"buckets,omitempty"`      // Histogram bucket upper bounds
+	Counts       []uint64           `json:"counts,omitempty"`       // Histogram observations per bucket, +Inf bucket last
+	Observations []float64          `json:"observations,omitempty"` // Summary observations
+	ID           string             `json:"id"`                     // Metric name
+	MType        string             `json:"type"`                   // Metric type (gauge, counter, histogram or summary)
+}
+
+// Point is a single recorded metric value in a time series
+type Point struct {
+	Delta *int64    `json:"delta,omitempty"` // Counter value at the time of the update
+	Value *float64  `json:"value,omitempty"` // Gauge value at the time of the update
+	Time  time.Time `json:"time"`            // Time of the update
+}
 
+// MetricHistory represents the recorded values of a metric series in JSON format
+type MetricHistory struct {
+	Labels Labels  `json:"labels,omitempty"` // Optional metric dimensions
+	ID     string  `json:"id"`               // Metric name
+	MType  string  `json:"type"`             // Metric type (gauge or counter)
+	Points []Point `json:"points"`           // Recorded values ordered by time
+}
+
+// Key returns the series key identifying the metric by name and labels
+func (m *MetricJSON) Key() string {
+	return SeriesKey(m.ID, m.Labels)
 }
 
 // IsCounter checks if the metric is a counter type
@@ -45,19 +82,59 @@ func (m *MetricJSON) IsGauge() bool {
 	return m.MType == Gauge
 }
 
+// IsHistogram checks if the metric is a histogram type
+func (m *MetricJSON) IsHistogram() bool {
+	return m.MType == Histogram
+}
+
+// IsSummary checks if the metric is a summary type
+func (m *MetricJSON) IsSummary() bool {
+	return m.MType == Summary
+}
+
+// HistogramSum returns the sum of observations of a histogram update (0 if unset)
+func (m *MetricJSON) HistogramSum() float64 {
+	if m.Sum == nil {
+		return 0
+	}
+	return *m.Sum
+}
+
+// SummaryTotals returns the sum and number of observations of a summary update.
+// Unset Sum and Count default to the totals of Observations.
+func (m *MetricJSON) SummaryTotals() (float64, uint64) {
+	var sum float64
+	if m.Sum != nil {
+		sum = *m.Sum
+	} else {
+		for _, v := range m.Observations {
+			sum += v
+		}
+	}
+
+	count := uint64(len(m.Observations))
+	if m.Count != nil {
+		count = *m.Count
+	}
+	return sum, count
+}
+
 // MarshalMetricToJSON creates a MetricJSON from raw values
 // Parameters:
 //   - mType: Metric type ("gauge" or "counter")
-//   - name: Metric name
-//   - val: Metric value (float64 for gauge, int64 for counter)
+//   - name: Metric name or series key (labels are split out of the key)
+//   - val: Metric value (float64 for gauge, int64 for counter,
+//     HistogramValue for histogram, SummaryValue for summary)
 //
 // Returns:
 //   - *MetricJSON: constructed metric
 //   - error: if type conversion fails
 func MarshalMetricToJSON(mType, name string, val interface{}) (*MetricJSON, error) {
+	id, labels := ParseSeriesKey(name)
 	metric := MetricJSON{
-		ID:    name,
-		MType: mType,
+		ID:     id,
+		MType:  mType,
+		Labels: labels,
 	}
 
 	switch mType {
@@ -77,6 +154,31 @@ func MarshalMetricToJSON(mType, name string, val interface{}) (*MetricJSON, erro
 		}
 		metric.Value = &valFl
 
+	case Histogram:
+		valHist, ok := val.(HistogramValue)
+		if !ok {
+			logger.Log.Error("parse error")
+			return &metric, errors.New("invalid histogram value type")
+		}
+		metric.Buckets = valHist.Bounds
+		metric.Counts = valHist.Counts
+		metric.Sum = &valHist.Sum
+		metric.Count = &valHist.Count
+
+	case Summary:
+		valSum, ok := val.(SummaryValue)
+		if !ok {
+			logger.Log.Error("parse error")
+			return &metric, errors.New("invalid summary value type")
+		}
+		metric.Observations = make([]float64, len(valSum.Window))
+		for i, o := range valSum.Window {
+			metric.Observations[i] = o.Value
+		}
+		metric.Quantiles = valSum.Quantiles()
+		metric.Sum = &valSum.Sum
+		metric.Count = &valSum.Count
+
 	default:
 		logger.Log.Error("parse error")
 		return &metric, errors.New("invalid metric type")
@@ -84,3 +186,85 @@ func MarshalMetricToJSON(mType, name string, val interface{}) (*MetricJSON, erro
 
 	return &metric, nil
 }
+
+// SeriesKey builds the storage key of a metric series from its name and labels.
+// A metric without labels is keyed by its plain name, otherwise labels are
+// appended in sorted order: name{cpu="3",host="web-1"}.
+func SeriesKey(name string, labels Labels) string {
+	if len(labels) == 0 {
+		return name
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[k]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// ParseSeriesKey splits a series key built by SeriesKey back into the metric
+// name and its labels. Keys that are not in the labeled form are returned as is.
+func ParseSeriesKey(key string) (string, Labels) {
+	start := strings.IndexByte(key, '{')
+	if start < 0 || !strings.HasSuffix(key, "}") {
+		return key, nil
+	}
+
+	labels := make(Labels)
+	rest := key[start+1 : len(key)-1]
+	for rest != "" {
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return key, nil
+		}
+		name := rest[:eq]
+
+		quoted, err := strconv.QuotedPrefix(rest[eq+1:])
+		if err != nil {
+			return key, nil
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			return key, nil
+		}
+		labels[name] = value
+
+		rest = rest[eq+1+len(quoted):]
+		if rest != "" {
+			if rest[0] != ',' {
+				return key, nil
+			}
+			rest = rest[1:]
+		}
+	}
+
+	return key[:start], labels
+}
+
+// ValidateLabels checks that the metric name and label names can be encoded
+// into a series key without ambiguity.
+func ValidateLabels(name string, labels Labels) error {
+	if strings.ContainsAny(name, "{}") {
+		return fmt.Errorf("%s: metric name must not contain braces", name)
+	}
+	for k := range labels {
+		if k == "" || strings.ContainsAny(k, `{}=,"\`) {
+			return fmt.Errorf("%s: invalid label name %q", name, k)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeriesKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		id     string
+		labels Labels
+		want   string
+	}{
+		{name: "No labels", id: "HeapAlloc", want: "HeapAlloc"},
+		{name: "Single label", id: "CPUutilization", labels: Labels{"cpu": "3"}, want: `CPUutilization{cpu="3"}`},
+		{name: "Sorted labels", id: "CPUutilization", labels: Labels{"host": "web-1", "cpu": "3"}, want: `CPUutilization{cpu="3",host="web-1"}`},
+		{name: "Escaped value", id: "m", labels: Labels{"path": `a"b,c}`}, want: `m{path="a\"b,c}"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := SeriesKey(tt.id, tt.labels)
+			assert.Equal(t, tt.want, key)
+
+			id, labels := ParseSeriesKey(key)
+			assert.Equal(t, tt.id, id)
+			if len(tt.labels) == 0 {
+				assert.Empty(t, labels)
+			} else {
+				assert.Equal(t, tt.labels, labels)
+			}
+		})
+	}
+}
+
+func TestValidateLabels(t *testing.T) {
+	assert.NoError(t, ValidateLabels("CPUutilization", Labels{"cpu": "3"}))
+	assert.Error(t, ValidateLabels("bad{name}", nil))
+	assert.Error(t, ValidateLabels("m", Labels{"": "v"}))
+	assert.Error(t, ValidateLabels("m", Labels{"a=b": "v"}))
+}
+
+func TestMarshalMetricToJSONSplitsLabels(t *testing.T) {
+	metric, err := MarshalMetricToJSON(Gauge, `CPUutilization{cpu="3"}`, 12.5)
+	assert.NoError(t, err)
+	assert.Equal(t, "CPUutilization", metric.ID)
+	assert.Equal(t, Labels{"cpu": "3"}, metric.Labels)
+	assert.Equal(t, `CPUutilization{cpu="3"}`, metric.Key())
+}
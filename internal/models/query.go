@@ -0,0 +1,27 @@
+package models
+
+// Sort orders of a MetricQuery
+const (
+	SortByName  = "name"  // Series key, then type (default)
+	SortByType  = "type"  // Type, then series key
+	SortByValue = "value" // Gauge value, counter total or distribution sum, then series key
+)
+
+// MetricQuery selects a page of series from the storage
+type MetricQuery struct {
+	Labels Labels // Labels the series must have with equal values (empty matches all)
+	Match  string // Glob over the metric name, * matches any characters and ? one (empty matches all)
+	Regex  string // Regular expression matched anywhere in the metric name (empty matches all)
+	Type   string // Metric type (empty selects all types)
+	Sort   string // Sort order (SortByName if empty), a "-" prefix reverses it
+	Cursor string // Continue after the page that returned this cursor (see MetricPage.Next)
+	Limit  int    // Maximum number of series in the page (0 = no limit)
+	Offset int    // Number of series skipped, after the cursor if it is set
+}
+
+// MetricPage is a page of series selected by a MetricQuery
+type MetricPage struct {
+	Metrics []MetricJSON `json:"metrics"`        // Series of the page in the requested order
+	Next    string       `json:"next,omitempty"` // Cursor of the next page, empty on the last page
+	Total   int          `json:"total"`          // Number of series matching the filters
+}
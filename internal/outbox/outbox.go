@@ -0,0 +1,375 @@
+// Package outbox implements a bounded on-disk FIFO queue of records.
+//
+// The agent uses it to keep metric batches that could not be delivered and
+// to replay them in order once the server is reachable again.
+//
+// Records are appended to segment files named by a sequence number. A
+// segment is closed once it reaches Config.SegmentSize; the oldest segments
+// are dropped when the queue exceeds Config.MaxSize and records older than
+// Config.MaxAge are discarded on replay.
+//
+// Every record is stored as
+//
+//	| length (4 bytes) | CRC-32 (4 bytes) | unix nanoseconds (8 bytes) | data |
+//
+// with big endian integers. A corrupted or truncated tail of a segment
+// (e.g. after a crash during a write) is ignored.
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default limits used for zero Config fields
+const (
+	DefaultSegmentSize = 1 << 20  // 1 MiB
+	DefaultMaxSize     = 64 << 20 // 64 MiB
+	DefaultMaxAge      = 24 * time.Hour
+)
+
+// segmentExt is the file extension of segment files
+const segmentExt = ".seg"
+
+// headerSize is the size of a record header
+const headerSize = 16
+
+// ErrRecordTooLarge is returned by Append for records larger than the segment size
+var ErrRecordTooLarge = errors.New("record exceeds the segment size")
+
+// Config contains outbox settings
+type Config struct {
+	Dir         string        // Directory for segment files, created if missing
+	SegmentSize int64         // Maximum size of a segment file
+	MaxSize     int64         // Maximum total size of all segments; the oldest are dropped beyond it
+	MaxAge      time.Duration // Records older than this are dropped
+}
+
+// segment is a segment file on disk
+type segment struct {
+	seq  uint64
+	size int64
+}
+
+// Outbox is a bounded on-disk FIFO queue. It is safe for concurrent use.
+type Outbox struct {
+	cfg Config
+	now func() time.Time
+
+	mu       sync.Mutex
+	segments []segment // Segments ordered from oldest to newest, the last one is open for appends
+	active   *os.File  // Open file of the last segment, nil if it must be created first
+	dropped  int       // Records dropped because of size or age limits
+}
+
+// Open opens the outbox in cfg.Dir, keeping records left by a previous run
+func Open(cfg Config) (*Outbox, error) {
+	if cfg.SegmentSize <= 0 {
+		cfg.SegmentSize = DefaultSegmentSize
+	}
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = DefaultMaxSize
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = DefaultMaxAge
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create outbox directory: %w", err)
+	}
+
+	entries, err := os.ReadDir(cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbox directory: %w", err)
+	}
+
+	o := &Outbox{cfg: cfg, now: time.Now}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		o.segments = append(o.segments, segment{seq: seq, size: info.Size()})
+	}
+	sort.Slice(o.segments, func(i, j int) bool {
+		return o.segments[i].seq < o.segments[j].seq
+	})
+	return o, nil
+}
+
+// Append adds a record to the end of the queue
+func (o *Outbox) Append(data []byte) error {
+	size := int64(headerSize + len(data))
+	if size > o.cfg.SegmentSize {
+		return ErrRecordTooLarge
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.active == nil || o.segments[len(o.segments)-1].size+size > o.cfg.SegmentSize {
+		if err := o.rotate(); err != nil {
+			return err
+		}
+	}
+
+	record := make([]byte, headerSize, size)
+	binary.BigEndian.PutUint32(record[0:], uint32(len(data)))
+	binary.BigEndian.PutUint32(record[4:], crc32.ChecksumIEEE(data))
+	binary.BigEndian.PutUint64(record[8:], uint64(o.now().UnixNano()))
+	record = append(record, data...)
+
+	if _, err := o.active.Write(record); err != nil {
+		return fmt.Errorf("failed to write outbox record: %w", err)
+	}
+	if err := o.active.Sync(); err != nil {
+		return fmt.Errorf("failed to sync outbox segment: %w", err)
+	}
+	o.segments[len(o.segments)-1].size += size
+
+	return o.enforceMaxSize()
+}
+
+// Replay passes records to send from the oldest to the newest and removes
+// them once send succeeds. It stops at the first error, which is returned;
+// the failed record and all newer ones stay queued for the next replay.
+// Records older than Config.MaxAge are dropped without being sent.
+func (o *Outbox) Replay(ctx context.Context, send func(data []byte) error) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	// New records go to a new segment while the current ones are replayed
+	if err := o.closeActive(); err != nil {
+		return err
+	}
+
+	for len(o.segments) != 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		seg := o.segments[0]
+		records, err := o.readSegment(seg.seq)
+		if err != nil {
+			return err
+		}
+
+		cutoff := o.now().Add(-o.cfg.MaxAge)
+		for i, r := range records {
+			if r.time.Before(cutoff) {
+				o.dropped++
+				continue
+			}
+			if err := send(r.data); err != nil {
+				if rerr := o.rewriteSegment(seg.seq, records[i:]); rerr != nil {
+					return errors.Join(err, rerr)
+				}
+				return err
+			}
+		}
+
+		if err := os.Remove(o.path(seg.seq)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove outbox segment: %w", err)
+		}
+		o.segments = o.segments[1:]
+	}
+	return nil
+}
+
+// Empty reports whether the queue has no records
+func (o *Outbox) Empty() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, seg := range o.segments {
+		if seg.size != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Size returns the total size of all segment files in bytes
+func (o *Outbox) Size() int64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.totalSize()
+}
+
+// Dropped returns the number of records dropped because of the size and age limits
+func (o *Outbox) Dropped() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.dropped
+}
+
+// Close closes the segment open for appends
+func (o *Outbox) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.closeActive()
+}
+
+// rotate closes the active segment and opens a new one
+func (o *Outbox) rotate() error {
+	if err := o.closeActive(); err != nil {
+		return err
+	}
+
+	var seq uint64 = 1
+	if len(o.segments) != 0 {
+		seq = o.segments[len(o.segments)-1].seq + 1
+	}
+	f, err := os.OpenFile(o.path(seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create outbox segment: %w", err)
+	}
+	o.active = f
+	o.segments = append(o.segments, segment{seq: seq})
+	return nil
+}
+
+// closeActive closes the active segment file; the next Append opens a new segment
+func (o *Outbox) closeActive() error {
+	if o.active == nil {
+		return nil
+	}
+	err := o.active.Close()
+	o.active = nil
+	return err
+}
+
+// enforceMaxSize drops the oldest closed segments while the queue exceeds MaxSize
+func (o *Outbox) enforceMaxSize() error {
+	for o.totalSize() > o.cfg.MaxSize && len(o.segments) > 1 {
+		seg := o.segments[0]
+		records, err := o.readSegment(seg.seq)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(o.path(seg.seq)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove outbox segment: %w", err)
+		}
+		o.dropped += len(records)
+		o.segments = o.segments[1:]
+	}
+	return nil
+}
+
+// totalSize returns the total size of all segments
+func (o *Outbox) totalSize() int64 {
+	var total int64
+	for _, seg := range o.segments {
+		total += seg.size
+	}
+	return total
+}
+
+// record is a decoded outbox record
+type record struct {
+	time time.Time
+	data []byte
+}
+
+// readSegment decodes all intact records of a segment
+func (o *Outbox) readSegment(seq uint64) ([]record, error) {
+	f, err := os.Open(o.path(seq))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open outbox segment: %w", err)
+	}
+	defer f.Close()
+
+	var records []record
+	reader := bufio.NewReader(f)
+	header := make([]byte, headerSize)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			// EOF or a truncated header of an interrupted write
+			return records, nil
+		}
+		length := binary.BigEndian.Uint32(header[0:])
+		if int64(length) > o.cfg.SegmentSize {
+			return records, nil
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return records, nil
+		}
+		if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:]) {
+			return records, nil
+		}
+		records = append(records, record{
+			time: time.Unix(0, int64(binary.BigEndian.Uint64(header[8:]))),
+			data: data,
+		})
+	}
+}
+
+// rewriteSegment atomically replaces a segment with the given records
+func (o *Outbox) rewriteSegment(seq uint64, records []record) error {
+	tmp := o.path(seq) + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to rewrite outbox segment: %w", err)
+	}
+
+	var size int64
+	writer := bufio.NewWriter(f)
+	header := make([]byte, headerSize)
+	for _, r := range records {
+		binary.BigEndian.PutUint32(header[0:], uint32(len(r.data)))
+		binary.BigEndian.PutUint32(header[4:], crc32.ChecksumIEEE(r.data))
+		binary.BigEndian.PutUint64(header[8:], uint64(r.time.UnixNano()))
+		writer.Write(header)
+		writer.Write(r.data)
+		size += int64(headerSize + len(r.data))
+	}
+	if err := writer.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to rewrite outbox segment: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to rewrite outbox segment: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to rewrite outbox segment: %w", err)
+	}
+	if err := os.Rename(tmp, o.path(seq)); err != nil {
+		return fmt.Errorf("failed to rewrite outbox segment: %w", err)
+	}
+
+	for i := range o.segments {
+		if o.segments[i].seq == seq {
+			o.segments[i].size = size
+		}
+	}
+	return nil
+}
+
+// path returns the file path of a segment
+func (o *Outbox) path(seq uint64) string {
+	return filepath.Join(o.cfg.Dir, fmt.Sprintf("%020d%s", seq, segmentExt))
+}
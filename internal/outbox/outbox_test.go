@@ -0,0 +1,171 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// collect replays the outbox and returns all records as strings
+func collect(t *testing.T, o *Outbox) []string {
+	t.Helper()
+	var got []string
+	require.NoError(t, o.Replay(context.Background(), func(data []byte) error {
+		got = append(got, string(data))
+		return nil
+	}))
+	return got
+}
+
+func TestOutbox_AppendReplay(t *testing.T) {
+	o, err := Open(Config{Dir: t.TempDir(), SegmentSize: 64})
+	require.NoError(t, err)
+	defer o.Close()
+
+	want := make([]string, 0, 10)
+	for i := 0; i < 10; i++ {
+		record := fmt.Sprintf("batch-%d", i)
+		want = append(want, record)
+		require.NoError(t, o.Append([]byte(record)))
+	}
+	// Small segments force several files
+	assert.Greater(t, len(o.segments), 1)
+	assert.False(t, o.Empty())
+
+	assert.Equal(t, want, collect(t, o))
+	assert.True(t, o.Empty())
+	assert.Empty(t, collect(t, o))
+}
+
+func TestOutbox_ReplayStopsOnError(t *testing.T) {
+	o, err := Open(Config{Dir: t.TempDir(), SegmentSize: 64})
+	require.NoError(t, err)
+	defer o.Close()
+
+	for i := 0; i < 6; i++ {
+		require.NoError(t, o.Append([]byte(fmt.Sprintf("batch-%d", i))))
+	}
+
+	sendErr := errors.New("server unavailable")
+	var sent []string
+	err = o.Replay(context.Background(), func(data []byte) error {
+		if len(sent) == 3 {
+			return sendErr
+		}
+		sent = append(sent, string(data))
+		return nil
+	})
+	require.ErrorIs(t, err, sendErr)
+	assert.Equal(t, []string{"batch-0", "batch-1", "batch-2"}, sent)
+
+	// Appends during the outage go after the remaining records
+	require.NoError(t, o.Append([]byte("batch-6")))
+	assert.Equal(t, []string{"batch-3", "batch-4", "batch-5", "batch-6"}, collect(t, o))
+}
+
+func TestOutbox_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	o, err := Open(Config{Dir: dir})
+	require.NoError(t, err)
+	require.NoError(t, o.Append([]byte("first")))
+	require.NoError(t, o.Append([]byte("second")))
+	require.NoError(t, o.Close())
+
+	o, err = Open(Config{Dir: dir})
+	require.NoError(t, err)
+	defer o.Close()
+	require.NoError(t, o.Append([]byte("third")))
+
+	assert.Equal(t, []string{"first", "second", "third"}, collect(t, o))
+}
+
+func TestOutbox_TruncatedTail(t *testing.T) {
+	dir := t.TempDir()
+	o, err := Open(Config{Dir: dir})
+	require.NoError(t, err)
+	require.NoError(t, o.Append([]byte("intact")))
+	require.NoError(t, o.Append([]byte("torn")))
+	require.NoError(t, o.Close())
+
+	// Simulate a crash in the middle of the last write
+	path := o.path(1)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, info.Size()-2))
+
+	o, err = Open(Config{Dir: dir})
+	require.NoError(t, err)
+	defer o.Close()
+	assert.Equal(t, []string{"intact"}, collect(t, o))
+}
+
+func TestOutbox_MaxSize(t *testing.T) {
+	o, err := Open(Config{Dir: t.TempDir(), SegmentSize: 64, MaxSize: 128})
+	require.NoError(t, err)
+	defer o.Close()
+
+	for i := 0; i < 20; i++ {
+		require.NoError(t, o.Append([]byte(fmt.Sprintf("batch-%02d", i))))
+	}
+	assert.LessOrEqual(t, o.Size(), int64(128))
+	assert.Positive(t, o.Dropped())
+
+	got := collect(t, o)
+	require.NotEmpty(t, got)
+	// The newest records are kept in order
+	assert.Equal(t, "batch-19", got[len(got)-1])
+	assert.Equal(t, 20, len(got)+o.Dropped())
+}
+
+func TestOutbox_MaxAge(t *testing.T) {
+	o, err := Open(Config{Dir: t.TempDir(), MaxAge: time.Minute})
+	require.NoError(t, err)
+	defer o.Close()
+
+	now := time.Now()
+	o.now = func() time.Time { return now.Add(-2 * time.Minute) }
+	require.NoError(t, o.Append([]byte("stale")))
+	o.now = func() time.Time { return now }
+	require.NoError(t, o.Append([]byte("fresh")))
+
+	assert.Equal(t, []string{"fresh"}, collect(t, o))
+	assert.Equal(t, 1, o.Dropped())
+}
+
+func TestOutbox_RecordTooLarge(t *testing.T) {
+	o, err := Open(Config{Dir: t.TempDir(), SegmentSize: 32})
+	require.NoError(t, err)
+	defer o.Close()
+
+	assert.ErrorIs(t, o.Append(make([]byte, 32)), ErrRecordTooLarge)
+}
+
+func TestOutbox_ReplayCanceled(t *testing.T) {
+	o, err := Open(Config{Dir: t.TempDir()})
+	require.NoError(t, err)
+	defer o.Close()
+	require.NoError(t, o.Append([]byte("batch")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, o.Replay(ctx, func([]byte) error { return nil }), context.Canceled)
+	assert.False(t, o.Empty())
+}
+
+func TestOpen_IgnoresForeignFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "abc.seg"), []byte("x"), 0o644))
+
+	o, err := Open(Config{Dir: dir})
+	require.NoError(t, err)
+	defer o.Close()
+	assert.True(t, o.Empty())
+}
@@ -0,0 +1,55 @@
+package proto
+
+import (
+	"github.com/runtime-metrics-course/internal/models"
+)
+
+// metricTypes maps protobuf metric types to model metric types
+var metricTypes = map[Metric_MType]string{
+	Metric_GAUGE:     models.Gauge,
+	Metric_COUNTER:   models.Counter,
+	Metric_HISTOGRAM: models.Histogram,
+	Metric_SUMMARY:   models.Summary,
+}
+
+// ToModel converts a protobuf metric to its JSON model counterpart.
+// Unknown metric types are kept empty so that storage validation rejects them.
+func ToModel(m *Metric) models.MetricJSON {
+	metric := models.MetricJSON{
+		Delta:        m.Delta,
+		Value:        m.Value,
+		Sum:          m.Sum,
+		Count:        m.Count,
+		Buckets:      m.Buckets,
+		Counts:       m.Counts,
+		Observations: m.Observations,
+		ID:           m.Id,
+		MType:        metricTypes[m.Type],
+	}
+	if len(m.Labels) != 0 {
+		metric.Labels = m.Labels
+	}
+	return metric
+}
+
+// FromModel converts a JSON model metric to its protobuf counterpart
+func FromModel(m models.MetricJSON) *Metric {
+	metric := &Metric{
+		Id:           m.ID,
+		Delta:        m.Delta,
+		Value:        m.Value,
+		Labels:       m.Labels,
+		Buckets:      m.Buckets,
+		Counts:       m.Counts,
+		Observations: m.Observations,
+		Sum:          m.Sum,
+		Count:        m.Count,
+	}
+	for t, name := range metricTypes {
+		if name == m.MType {
+			metric.Type = t
+			break
+		}
+	}
+	return metric
+}
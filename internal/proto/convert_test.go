@@ -0,0 +1,37 @@
+package proto
+
+import (
+	"testing"
+
+	"github.com/runtime-metrics-course/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelRoundTrip(t *testing.T) {
+	value := 1.5
+	delta := int64(7)
+	sum := 12.5
+	count := uint64(3)
+
+	tests := []struct {
+		name   string
+		metric models.MetricJSON
+	}{
+		{name: "Gauge", metric: models.MetricJSON{ID: "Alloc", MType: models.Gauge, Value: &value}},
+		{name: "Labeled counter", metric: models.MetricJSON{ID: "PollCount", MType: models.Counter, Delta: &delta, Labels: models.Labels{"host": "a"}}},
+		{name: "Histogram", metric: models.MetricJSON{ID: "GCPauseNs", MType: models.Histogram, Buckets: []float64{1, 10}, Counts: []uint64{1, 1, 1}, Sum: &sum}},
+		{name: "Summary", metric: models.MetricJSON{ID: "Latency", MType: models.Summary, Observations: []float64{1, 2}, Sum: &sum, Count: &count}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.metric, ToModel(FromModel(tt.metric)))
+		})
+	}
+}
+
+func TestToModel_UnknownType(t *testing.T) {
+	metric := ToModel(&Metric{Id: "Alloc"})
+	assert.Empty(t, metric.MType)
+	assert.Nil(t, metric.Labels)
+}
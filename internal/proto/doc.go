@@ -0,0 +1,11 @@
+// Package proto contains the protobuf definitions of the gRPC metrics
+// transport (metrics.proto), the code generated from them and conversions
+// between protobuf and model metrics.
+//
+// Regenerate the code after changing metrics.proto:
+//
+//	protoc --go_out=. --go_opt=paths=source_relative \
+//	    --go-grpc_out=. --go-grpc_opt=paths=source_relative metrics.proto
+package proto
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative metrics.proto
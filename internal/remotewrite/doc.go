@@ -0,0 +1,10 @@
+// Package remotewrite decodes Prometheus remote write requests.
+//
+// A request body is a snappy (block format) compressed protobuf WriteRequest
+// of the remote write 1.0 protocol. Only the parts needed to store samples
+// are decoded: series labels and float samples. Exemplars, native histograms
+// and metadata are skipped.
+//
+// The messages are decoded with protowire instead of generated code, so the
+// Prometheus module is not needed as a dependency.
+package remotewrite
@@ -0,0 +1,222 @@
+package remotewrite
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/golang/snappy"
+	"github.com/runtime-metrics-course/internal/models"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// NameLabel is the label holding the metric name
+const NameLabel = "__name__"
+
+// MaxDecodedSize limits the decompressed size of a request body accepted by Decode
+const MaxDecodedSize = 64 << 20
+
+// ErrNoName is returned for a series without the __name__ label
+var ErrNoName = errors.New("series without " + NameLabel + " label")
+
+// ErrTooLarge is returned by Decode for bodies decompressing to more than MaxDecodedSize
+var ErrTooLarge = errors.New("request too large")
+
+// Label is a label of a series
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a float sample of a series
+type Sample struct {
+	Value     float64
+	Timestamp int64 // Milliseconds since the Unix epoch
+}
+
+// TimeSeries is a series with its samples
+type TimeSeries struct {
+	Labels  []Label
+	Samples []Sample
+}
+
+// Metric returns the metric name of the series and its other labels
+func (ts *TimeSeries) Metric() (string, models.Labels, error) {
+	var (
+		name   string
+		labels models.Labels
+	)
+	for _, l := range ts.Labels {
+		if l.Name == NameLabel {
+			name = l.Value
+			continue
+		}
+		if labels == nil {
+			labels = make(models.Labels, len(ts.Labels))
+		}
+		labels[l.Name] = l.Value
+	}
+	if name == "" {
+		return "", nil, ErrNoName
+	}
+	return name, labels, nil
+}
+
+// WriteRequest is a remote write request
+type WriteRequest struct {
+	Timeseries []TimeSeries
+}
+
+// Field numbers of the remote write messages
+const (
+	writeRequestTimeseries = 1
+	timeSeriesLabels       = 1
+	timeSeriesSamples      = 2
+	labelName              = 1
+	labelValue             = 2
+	sampleValue            = 1
+	sampleTimestamp        = 2
+)
+
+// Decode decompresses and decodes a remote write request body.
+// The decompressed size is checked before anything is allocated for it.
+func Decode(body []byte) (*WriteRequest, error) {
+	size, err := snappy.DecodedLen(body)
+	if err != nil {
+		return nil, fmt.Errorf("snappy: %w", err)
+	}
+	if size > MaxDecodedSize {
+		return nil, fmt.Errorf("%w: %d bytes decompressed, at most %d allowed", ErrTooLarge, size, MaxDecodedSize)
+	}
+	data, err := snappy.Decode(nil, body)
+	if err != nil {
+		return nil, fmt.Errorf("snappy: %w", err)
+	}
+	return Unmarshal(data)
+}
+
+// Encode encodes and compresses a remote write request body
+func Encode(req *WriteRequest) []byte {
+	return snappy.Encode(nil, Marshal(req))
+}
+
+// Unmarshal decodes a protobuf WriteRequest
+func Unmarshal(data []byte) (*WriteRequest, error) {
+	req := &WriteRequest{}
+	err := walk(data, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		if num != writeRequestTimeseries || typ != protowire.BytesType {
+			return nil
+		}
+		ts, err := unmarshalTimeSeries(value)
+		if err != nil {
+			return fmt.Errorf("timeseries %d: %w", len(req.Timeseries), err)
+		}
+		req.Timeseries = append(req.Timeseries, ts)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func unmarshalTimeSeries(data []byte) (TimeSeries, error) {
+	var ts TimeSeries
+	err := walk(data, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case timeSeriesLabels:
+			var l Label
+			err := walk(value, func(num protowire.Number, typ protowire.Type, value []byte) error {
+				if typ != protowire.BytesType {
+					return nil
+				}
+				switch num {
+				case labelName:
+					l.Name = string(value)
+				case labelValue:
+					l.Value = string(value)
+				}
+				return nil
+			})
+			ts.Labels = append(ts.Labels, l)
+			return err
+		case timeSeriesSamples:
+			var s Sample
+			err := walk(value, func(num protowire.Number, typ protowire.Type, value []byte) error {
+				switch {
+				case num == sampleValue && typ == protowire.Fixed64Type:
+					bits, _ := protowire.ConsumeFixed64(value)
+					s.Value = math.Float64frombits(bits)
+				case num == sampleTimestamp && typ == protowire.VarintType:
+					v, _ := protowire.ConsumeVarint(value)
+					s.Timestamp = int64(v)
+				}
+				return nil
+			})
+			ts.Samples = append(ts.Samples, s)
+			return err
+		}
+		return nil
+	})
+	return ts, err
+}
+
+// walk calls fn for every field of a message with the encoded field value;
+// the value of a bytes field is its content without the length prefix
+func walk(data []byte, fn func(num protowire.Number, typ protowire.Type, value []byte) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		n = protowire.ConsumeFieldValue(num, typ, data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		value := data[:n]
+		if typ == protowire.BytesType {
+			value, _ = protowire.ConsumeBytes(value)
+		}
+		if err := fn(num, typ, value); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+// Marshal encodes a WriteRequest as protobuf
+func Marshal(req *WriteRequest) []byte {
+	var data []byte
+	for _, ts := range req.Timeseries {
+		var series []byte
+		for _, l := range ts.Labels {
+			var label []byte
+			label = protowire.AppendTag(label, labelName, protowire.BytesType)
+			label = protowire.AppendString(label, l.Name)
+			label = protowire.AppendTag(label, labelValue, protowire.BytesType)
+			label = protowire.AppendString(label, l.Value)
+			series = appendMessage(series, timeSeriesLabels, label)
+		}
+		for _, s := range ts.Samples {
+			var sample []byte
+			sample = protowire.AppendTag(sample, sampleValue, protowire.Fixed64Type)
+			sample = protowire.AppendFixed64(sample, math.Float64bits(s.Value))
+			sample = protowire.AppendTag(sample, sampleTimestamp, protowire.VarintType)
+			sample = protowire.AppendVarint(sample, uint64(s.Timestamp))
+			series = appendMessage(series, timeSeriesSamples, sample)
+		}
+		data = appendMessage(data, writeRequestTimeseries, series)
+	}
+	return data
+}
+
+func appendMessage(b []byte, num protowire.Number, message []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, message)
+}
@@ -0,0 +1,71 @@
+package remotewrite
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/runtime-metrics-course/internal/models"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	req := &WriteRequest{Timeseries: []TimeSeries{
+		{
+			Labels:  []Label{{Name: NameLabel, Value: "up"}, {Name: "job", Value: "node"}},
+			Samples: []Sample{{Value: 1, Timestamp: 1700000000000}, {Value: 0, Timestamp: 1700000015000}},
+		},
+		{
+			Labels:  []Label{{Name: NameLabel, Value: "temperature"}},
+			Samples: []Sample{{Value: -21.5, Timestamp: -1}},
+		},
+	}}
+
+	decoded, err := Decode(Encode(req))
+	require.NoError(t, err)
+	assert.Equal(t, req, decoded)
+}
+
+func TestUnmarshal_SkipsUnknownFields(t *testing.T) {
+	data := Marshal(&WriteRequest{Timeseries: []TimeSeries{{
+		Labels:  []Label{{Name: NameLabel, Value: "up"}},
+		Samples: []Sample{{Value: 1, Timestamp: 10}},
+	}}})
+	// Metadata (field 3) of a newer sender
+	data = protowire.AppendTag(data, 3, protowire.BytesType)
+	data = protowire.AppendBytes(data, []byte("metadata"))
+
+	req, err := Unmarshal(data)
+	require.NoError(t, err)
+	require.Len(t, req.Timeseries, 1)
+	assert.Equal(t, []Sample{{Value: 1, Timestamp: 10}}, req.Timeseries[0].Samples)
+}
+
+func TestDecode_Malformed(t *testing.T) {
+	_, err := Decode([]byte("not snappy"))
+	assert.Error(t, err)
+
+	valid := Marshal(&WriteRequest{Timeseries: []TimeSeries{{Samples: []Sample{{Value: math.Pi}}}}})
+	_, err = Unmarshal(valid[:len(valid)-1])
+	assert.Error(t, err, "truncated message")
+
+	// The snappy header claims a decompressed size above the limit
+	huge := binary.AppendUvarint(nil, MaxDecodedSize+1)
+	_, err = Decode(append(huge, 0))
+	assert.ErrorIs(t, err, ErrTooLarge)
+}
+
+func TestTimeSeries_Metric(t *testing.T) {
+	ts := TimeSeries{Labels: []Label{{Name: "job", Value: "node"}, {Name: NameLabel, Value: "up"}}}
+	name, labels, err := ts.Metric()
+	require.NoError(t, err)
+	assert.Equal(t, "up", name)
+	assert.Equal(t, models.Labels{"job": "node"}, labels)
+
+	ts = TimeSeries{Labels: []Label{{Name: "job", Value: "node"}}}
+	_, _, err = ts.Metric()
+	assert.ErrorIs(t, err, ErrNoName)
+}
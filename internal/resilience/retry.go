@@ -20,6 +20,7 @@ import (
 //   - PostgreSQL errors (*pgconn.PgError)
 //   - Network errors (net.Error)
 //   - Unexpected EOF errors (io.ErrUnexpectedEOF)
+//   - Errors marked with Transient
 //
 // Parameters:
 //   - ctx: Context for cancellation and timeout control
@@ -34,6 +35,7 @@ func Retry(ctx context.Context, operation func() error) error {
 	var err error
 	var pgErr *pgconn.PgError
 	var netErr net.Error
+	var transientErr *transientError
 	delays := []time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second}
 
 	for _, delay := range delays {
@@ -42,7 +44,7 @@ func Retry(ctx context.Context, operation func() error) error {
 			return nil
 		}
 
-		if errors.As(err, &pgErr) || errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		if errors.As(err, &pgErr) || errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &transientErr) {
 			logger.Log.Sugar().Error("Retriable ошибка: %v. Повтор через %v...\n", err, delay)
 			select {
 			case <-ctx.Done():
@@ -56,3 +58,21 @@ func Retry(ctx context.Context, operation func() error) error {
 	}
 	return fmt.Errorf("operation failed after retries: %w", err)
 }
+
+// transientError marks an error as retriable
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string { return e.err.Error() }
+
+func (e *transientError) Unwrap() error { return e.err }
+
+// Transient marks err as transient so that Retry retries it, e.g. an HTTP 503
+// response. Returns nil if err is nil.
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &transientError{err: err}
+}
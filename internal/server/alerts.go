@@ -0,0 +1,45 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/runtime-metrics-course/internal/alerting"
+)
+
+// AlertsHandler serves the state of the alerting rules
+type AlertsHandler struct {
+	engine *alerting.Engine // Engine evaluating the rules
+}
+
+// NewAlertsHandler creates a new AlertsHandler instance
+func NewAlertsHandler(engine *alerting.Engine) *AlertsHandler {
+	return &AlertsHandler{engine: engine}
+}
+
+// GetAlerts handles GET /alerts - returns pending, firing and recently
+// resolved alerts in JSON format.
+// Optional query parameter state filters alerts by state (e.g. ?state=firing).
+// Responses:
+//   - 200: JSON array of alerts
+//   - 500: Internal server error
+func (h *AlertsHandler) GetAlerts(w http.ResponseWriter, r *http.Request) {
+	alerts := h.engine.Alerts()
+	if state := r.URL.Query().Get("state"); state != "" {
+		filtered := alerts[:0]
+		for _, a := range alerts {
+			if a.State == state {
+				filtered = append(filtered, a)
+			}
+		}
+		alerts = filtered
+	}
+
+	respData, err := json.Marshal(alerts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(respData)
+}
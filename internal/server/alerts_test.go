@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/runtime-metrics-course/internal/alerting"
+	"github.com/runtime-metrics-course/internal/models"
+	"github.com/runtime-metrics-course/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAlerts(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMemStorage()
+	require.NoError(t, st.UpdateGauge(ctx, "HeapAlloc", 200))
+	require.NoError(t, st.UpdateGauge(ctx, "Alloc", 200))
+
+	threshold := 100.0
+	engine := alerting.NewEngine([]alerting.Rule{
+		{Name: "HighHeap", Metric: "HeapAlloc", MType: models.Gauge, Op: alerting.OpGreater, Threshold: &threshold},
+		{Name: "HighAlloc", Metric: "Alloc", MType: models.Gauge, Op: alerting.OpGreater, Threshold: &threshold, For: alerting.Duration(time.Hour)},
+	}, st, time.Second)
+	require.NoError(t, engine.Evaluate(ctx, time.Now()))
+
+	tests := []struct {
+		name       string
+		url        string
+		wantStates []string
+	}{
+		{name: "All alerts", url: "/alerts", wantStates: []string{alerting.StatePending, alerting.StateFiring}},
+		{name: "Firing only", url: "/alerts?state=firing", wantStates: []string{alerting.StateFiring}},
+		{name: "No resolved", url: "/alerts?state=resolved", wantStates: []string{}},
+	}
+
+	h := NewAlertsHandler(engine)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			w := httptest.NewRecorder()
+			h.GetAlerts(w, req)
+
+			resp := w.Result()
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+			var alerts []alerting.Alert
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&alerts))
+			states := make([]string, 0, len(alerts))
+			for _, a := range alerts {
+				states = append(states, a.State)
+			}
+			assert.Equal(t, tt.wantStates, states)
+		})
+	}
+}
@@ -1,6 +1,13 @@
 // Package server provides HTTP server implementation for metrics collection and monitoring.
 // It includes:
 // - Metrics endpoints for CRUD operations
+// - Metric query API with filtering, sorting and pagination
+// - Prometheus text exposition endpoint
+// - OpenTelemetry OTLP/HTTP metrics receiver (OTLPHandler)
+// - InfluxDB line protocol write endpoint
+// - Prometheus remote write receiver
+// - Alert states endpoint
+// - gRPC metrics service (InitGRPCServer) sharing the HTTP server storage
 // - Database health checks
 // - Built-in pprof profiling
 // - Middleware for logging, compression and authentication
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/runtime-metrics-course/internal/logger"
+	"github.com/runtime-metrics-course/internal/middleware"
+	"github.com/runtime-metrics-course/internal/models"
+	pb "github.com/runtime-metrics-course/internal/proto"
+	"github.com/runtime-metrics-course/internal/resilience"
+	"github.com/runtime-metrics-course/internal/storage"
+	"github.com/runtime-metrics-course/internal/tlsconfig"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// MetricsServer implements the Metrics gRPC service on top of the same
+// storage as the HTTP handlers
+type MetricsServer struct {
+	pb.UnimplementedMetricsServer
+	storage storage.StorageIface // Storage interface for metrics persistence
+}
+
+// NewMetricsServer creates a new MetricsServer instance
+func NewMetricsServer(storage storage.StorageIface) *MetricsServer {
+	return &MetricsServer{storage: storage}
+}
+
+// UpdateMetrics stores a batch of metrics, the gRPC counterpart of POST /updates/.
+// Returns codes.InvalidArgument for invalid labels and codes.Internal for storage errors.
+func (s *MetricsServer) UpdateMetrics(ctx context.Context, req *pb.UpdateMetricsRequest) (*pb.UpdateMetricsResponse, error) {
+	metrics := make([]models.MetricJSON, 0, len(req.Metrics))
+	for _, m := range req.Metrics {
+		metrics = append(metrics, pb.ToModel(m))
+	}
+
+	if err := s.store(ctx, metrics); err != nil {
+		return nil, err
+	}
+	return &pb.UpdateMetricsResponse{Accepted: uint32(len(metrics))}, nil
+}
+
+// StreamMetrics stores metrics received over a client stream and replies with
+// the number of stored metrics once the client closes the stream. The metrics
+// are stored as one batch at the end, so a stream that fails midway stores
+// nothing and the client can resend it as a whole.
+func (s *MetricsServer) StreamMetrics(stream pb.Metrics_StreamMetricsServer) error {
+	var metrics []models.MetricJSON
+	for {
+		m, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		metrics = append(metrics, pb.ToModel(m))
+	}
+
+	if len(metrics) != 0 {
+		if err := s.store(stream.Context(), metrics); err != nil {
+			return err
+		}
+	}
+	return stream.SendAndClose(&pb.UpdateMetricsResponse{Accepted: uint32(len(metrics))})
+}
+
+// store validates and saves metrics, converting errors to gRPC statuses
+func (s *MetricsServer) store(ctx context.Context, metrics []models.MetricJSON) error {
+	if err := validateLabels(metrics); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := resilience.Retry(ctx, func() error {
+		return s.storage.UpdateAll(ctx, metrics)
+	}); err != nil {
+		logger.Log.Error(err.Error())
+		if errors.Is(err, storage.ErrInvalidMetric) {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		return status.Error(codes.Internal, err.Error())
+	}
+	return nil
+}
+
+// InitGRPCServer starts the gRPC metrics server on cfg.GRPCAddress.
+// It shares the storage of the HTTP server, applies the trusted subnet check
+// if cfg.TrustedSubnet is set, serves TLS with cfg.TLS and blocks until the server stops.
+func InitGRPCServer(cfg Config) error {
+	storage, err := storage.GetStorageManager().GetStorage()
+	if err != nil {
+		return err
+	}
+
+	unary := []grpc.UnaryServerInterceptor{middleware.LoggerUnaryInterceptor}
+	stream := []grpc.StreamServerInterceptor{middleware.LoggerStreamInterceptor}
+	if cfg.TrustedSubnet != "" {
+		subnetMiddleware, err := middleware.NewTrustedSubnetMiddleware(cfg.TrustedSubnet)
+		if err != nil {
+			return err
+		}
+		unary = append(unary, subnetMiddleware.UnaryInterceptor)
+		stream = append(stream, subnetMiddleware.StreamInterceptor)
+	}
+
+	listener, err := net.Listen("tcp", cfg.GRPCAddress)
+	if err != nil {
+		return err
+	}
+
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}
+	if cfg.TLS.Enabled() {
+		tlsCfg, err := tlsconfig.Server(cfg.TLS)
+		if err != nil {
+			listener.Close()
+			return err
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsCfg)))
+	}
+
+	srv := grpc.NewServer(opts...)
+	pb.RegisterMetricsServer(srv, NewMetricsServer(storage))
+
+	logger.Log.Sugar().Infoln("gRPC server starting on", cfg.GRPCAddress)
+	return srv.Serve(listener)
+}
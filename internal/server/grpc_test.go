@@ -0,0 +1,166 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/runtime-metrics-course/internal/mocks"
+	"github.com/runtime-metrics-course/internal/models"
+	pb "github.com/runtime-metrics-course/internal/proto"
+	"github.com/runtime-metrics-course/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestGRPCClient serves a MetricsServer over an in-memory connection
+func newTestGRPCClient(t *testing.T, st storage.StorageIface) pb.MetricsClient {
+	t.Helper()
+
+	listener := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pb.RegisterMetricsServer(srv, NewMetricsServer(st))
+	go srv.Serve(listener)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return pb.NewMetricsClient(conn)
+}
+
+func TestMetricsServer_UpdateMetrics(t *testing.T) {
+	value := 1.5
+	delta := int64(3)
+
+	tests := []struct {
+		name         string
+		metrics      []*pb.Metric
+		wantCode     codes.Code
+		wantAccepted uint32
+		wantGauges   models.Gauges
+		wantCounters models.Counters
+	}{
+		{
+			name: "Gauge and labeled counter",
+			metrics: []*pb.Metric{
+				{Id: "Alloc", Type: pb.Metric_GAUGE, Value: &value},
+				{Id: "Requests", Type: pb.Metric_COUNTER, Delta: &delta, Labels: map[string]string{"host": "a"}},
+				{Id: "Requests", Type: pb.Metric_COUNTER, Delta: &delta, Labels: map[string]string{"host": "a"}},
+			},
+			wantCode:     codes.OK,
+			wantAccepted: 3,
+			wantGauges:   models.Gauges{"Alloc": 1.5},
+			wantCounters: models.Counters{`Requests{host="a"}`: 6},
+		},
+		{
+			name:     "Invalid labels",
+			metrics:  []*pb.Metric{{Id: "Alloc", Type: pb.Metric_GAUGE, Value: &value, Labels: map[string]string{"a=b": "c"}}},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "Missing value",
+			metrics:  []*pb.Metric{{Id: "Alloc", Type: pb.Metric_GAUGE}},
+			wantCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := storage.NewMemStorage()
+			client := newTestGRPCClient(t, st)
+
+			resp, err := client.UpdateMetrics(context.Background(), &pb.UpdateMetricsRequest{Metrics: tt.metrics})
+			require.Equal(t, tt.wantCode, status.Code(err), err)
+			if tt.wantCode != codes.OK {
+				return
+			}
+			assert.Equal(t, tt.wantAccepted, resp.Accepted)
+
+			metrics, err := st.GetMetrics(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantGauges, metrics.Gauges)
+			assert.Equal(t, tt.wantCounters, metrics.Counters)
+		})
+	}
+}
+
+func TestMetricsServer_UpdateMetrics_StorageError(t *testing.T) {
+	st := mocks.NewStorageIface(t)
+	st.On("UpdateAll", mock.Anything, mock.Anything).Return(errors.New("storage failure"))
+	client := newTestGRPCClient(t, st)
+
+	value := 1.0
+	_, err := client.UpdateMetrics(context.Background(), &pb.UpdateMetricsRequest{
+		Metrics: []*pb.Metric{{Id: "Alloc", Type: pb.Metric_GAUGE, Value: &value}},
+	})
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
+
+func TestMetricsServer_StreamMetrics(t *testing.T) {
+	st := storage.NewMemStorage()
+	client := newTestGRPCClient(t, st)
+
+	stream, err := client.StreamMetrics(context.Background())
+	require.NoError(t, err)
+
+	total := 205
+	for i := 0; i < total; i++ {
+		value := float64(i)
+		require.NoError(t, stream.Send(&pb.Metric{Id: fmt.Sprintf("Gauge%d", i), Type: pb.Metric_GAUGE, Value: &value}))
+	}
+	resp, err := stream.CloseAndRecv()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(total), resp.Accepted)
+
+	metrics, err := st.GetMetrics(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, metrics.Gauges, total)
+	assert.Equal(t, 42.0, metrics.Gauges["Gauge42"])
+}
+
+func TestMetricsServer_StreamMetrics_FailedMidway(t *testing.T) {
+	st := storage.NewMemStorage()
+	client := newTestGRPCClient(t, st)
+
+	stream, err := client.StreamMetrics(context.Background())
+	require.NoError(t, err)
+
+	delta := int64(1)
+	for i := 0; i < 150; i++ {
+		require.NoError(t, stream.Send(&pb.Metric{Id: fmt.Sprintf("Counter%d", i), Type: pb.Metric_COUNTER, Delta: &delta}))
+	}
+	require.NoError(t, stream.Send(&pb.Metric{Id: "Alloc", Type: pb.Metric_GAUGE}))
+	_, err = stream.CloseAndRecv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	metrics, err := st.GetMetrics(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, metrics.Counters, "a failed stream stores nothing")
+}
+
+func TestMetricsServer_StreamMetrics_InvalidLabels(t *testing.T) {
+	client := newTestGRPCClient(t, storage.NewMemStorage())
+
+	stream, err := client.StreamMetrics(context.Background())
+	require.NoError(t, err)
+
+	value := 1.0
+	require.NoError(t, stream.Send(&pb.Metric{Id: "Alloc", Type: pb.Metric_GAUGE, Value: &value, Labels: map[string]string{"": "x"}}))
+	_, err = stream.CloseAndRecv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -2,13 +2,16 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi"
 	"github.com/runtime-metrics-course/internal/logger"
+	"github.com/runtime-metrics-course/internal/middleware"
 	"github.com/runtime-metrics-course/internal/models"
 	"github.com/runtime-metrics-course/internal/resilience"
 	"github.com/runtime-metrics-course/internal/storage"
@@ -20,6 +23,10 @@ const (
 	Gauge = "gauge"
 	// Counter represents a counter metric type that only increments
 	Counter = "counter"
+	// Histogram represents a distribution of observations counted in buckets
+	Histogram = "histogram"
+	// Summary represents quantiles of observations over a sliding window
+	Summary = "summary"
 )
 
 // MetricsHandler handles all metrics-related HTTP requests
@@ -53,44 +60,40 @@ func (h *MetricsHandler) GetMetrics(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// GetMetricValue handles GET /value/{metric_type}/{name} - returns plaintext metric value
+// GetMetricValue handles GET /value/{metric_type}/{name} - returns plaintext metric value.
+// Query parameters select the labeled series, e.g. /value/gauge/CPUutilization?cpu=3
 // Responses:
 //   - 200: Metric value as plaintext
 //   - 400: Invalid metric type
 //   - 404: Metric not found
 //   - 500: Internal server error
 func (h *MetricsHandler) GetMetricValue(w http.ResponseWriter, r *http.Request) {
-	name := chi.URLParam(r, "name")
+	name := models.SeriesKey(chi.URLParam(r, "name"), labelsFromQuery(r))
 	metricType := chi.URLParam(r, "metric_type")
+	if metricType != Gauge && metricType != Counter {
+		http.Error(w, "Unknown metric type", http.StatusBadRequest)
+		return
+	}
 
-	metrics, err := h.storage.GetMetrics(r.Context())
+	metric, err := h.storage.GetMetric(r.Context(), metricType, name)
+	if errors.Is(err, storage.ErrMetricNotFound) {
+		http.Error(w, "Unknown metric", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	switch metricType {
-	case Gauge:
-		if val, ok := metrics.Gauges[name]; ok {
-			w.Write([]byte(strconv.FormatFloat(val, 'f', -1, 64)))
-		} else {
-			http.Error(w, "Unknown metric", http.StatusNotFound)
-			return
-		}
-	case Counter:
-		if val, ok := metrics.Counters[name]; ok {
-			w.Write([]byte(fmt.Sprintf("%d", val)))
-		} else {
-			http.Error(w, "Unknown metric", http.StatusNotFound)
-			return
-		}
-	default:
-		http.Error(w, "Unknown metric type", http.StatusBadRequest)
-		return
+	if metric.IsGauge() {
+		w.Write([]byte(strconv.FormatFloat(*metric.Value, 'f', -1, 64)))
+	} else {
+		w.Write([]byte(fmt.Sprintf("%d", *metric.Delta)))
 	}
 }
 
-// GetMetricValueJSON handles POST /value/ - returns metric value in JSON format
+// GetMetricValueJSON handles POST /value/ - returns metric value in JSON format.
+// The series is selected by the id and labels of the request body.
 // Responses:
 //   - 200: JSON response with metric value
 //   - 400: Invalid JSON or metric type
@@ -111,32 +114,27 @@ func (h *MetricsHandler) GetMetricValueJSON(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	storageMetrics, err := h.storage.GetMetrics(r.Context())
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
 	switch metric.MType {
-	case Gauge:
-		if val, ok := storageMetrics.Gauges[metric.ID]; ok {
-			metric.Value = &val
-		} else {
-			http.Error(w, "Unknown metric", http.StatusNotFound)
-			return
-		}
-	case Counter:
-		if val, ok := storageMetrics.Counters[metric.ID]; ok {
-			metric.Delta = &val
-		} else {
-			http.Error(w, "Unknown metric", http.StatusNotFound)
-			return
-		}
+	case Gauge, Counter, Histogram, Summary:
 	default:
 		http.Error(w, "Unknown metric type", http.StatusBadRequest)
 		return
 	}
 
+	stored, err := h.storage.GetMetric(r.Context(), metric.MType, metric.Key())
+	if errors.Is(err, storage.ErrMetricNotFound) {
+		http.Error(w, "Unknown metric", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	metric.Value, metric.Delta = stored.Value, stored.Delta
+	metric.Buckets, metric.Counts = stored.Buckets, stored.Counts
+	metric.Sum, metric.Count = stored.Sum, stored.Count
+	metric.Quantiles = stored.Quantiles
+
 	respData, err := json.Marshal(metric)
 	if err != nil {
 		http.Error(w, "", http.StatusBadRequest)
@@ -148,13 +146,19 @@ func (h *MetricsHandler) GetMetricValueJSON(w http.ResponseWriter, r *http.Reque
 // Update handles POST /update/{metric_type}/{name}/{value} - updates metric via URL params
 // Responses:
 //   - 200: Metric updated successfully
-//   - 400: Invalid metric type or value
+//   - 400: Invalid metric type, name or value
 //   - 500: Internal server error
 func (h *MetricsHandler) Update(w http.ResponseWriter, r *http.Request) {
 	metricType := chi.URLParam(r, "metric_type")
 	name := chi.URLParam(r, "name")
 	value := chi.URLParam(r, "value")
 
+	if err := models.ValidateLabels(name, nil); err != nil {
+		logger.Log.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	switch metricType {
 	case Gauge:
 		val, err := strconv.ParseFloat(value, 64)
@@ -211,6 +215,16 @@ func (h MetricsHandler) UpdateJSON(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err = models.ValidateLabels(metric.ID, metric.Labels); err != nil {
+		logger.Log.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if client := middleware.ClientIdentity(r.Context()); client != "" {
+		logger.Log.Sugar().Infow("metric received", "client", client, "id", metric.ID, "type", metric.MType)
+	}
+
 	switch metric.MType {
 	case Gauge:
 		if metric.Value == nil {
@@ -219,7 +233,7 @@ func (h MetricsHandler) UpdateJSON(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		err = resilience.Retry(r.Context(), func() error {
-			return h.storage.UpdateGauge(r.Context(), metric.ID, *metric.Value)
+			return h.storage.UpdateGauge(r.Context(), metric.Key(), *metric.Value)
 		})
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -232,12 +246,38 @@ func (h MetricsHandler) UpdateJSON(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		err = resilience.Retry(r.Context(), func() error {
-			return h.storage.UpdateCounter(r.Context(), metric.ID, *metric.Delta)
+			return h.storage.UpdateCounter(r.Context(), metric.Key(), *metric.Delta)
 		})
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+	case Histogram:
+		if err = models.ValidateHistogram(metric.Buckets, metric.Counts); err != nil {
+			logger.Log.Error("Invalid histogram value")
+			http.Error(w, "Invalid histogram value: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = resilience.Retry(r.Context(), func() error {
+			return h.storage.UpdateAll(r.Context(), []models.MetricJSON{*metric})
+		})
+		if err != nil {
+			updateError(w, err)
+			return
+		}
+	case Summary:
+		if metric.Observations == nil && metric.Count == nil {
+			logger.Log.Error("Invalid summary value")
+			http.Error(w, "Invalid summary value", http.StatusBadRequest)
+			return
+		}
+		err = resilience.Retry(r.Context(), func() error {
+			return h.storage.UpdateAll(r.Context(), []models.MetricJSON{*metric})
+		})
+		if err != nil {
+			updateError(w, err)
+			return
+		}
 	default:
 		logger.Log.Error("Invalid metric type")
 		http.Error(w, "Invalid metric type", http.StatusBadRequest)
@@ -264,10 +304,13 @@ func (h *MetricsHandler) PingDBHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// UpdateAll handles POST /updates/ - batch updates multiple metrics
+// UpdateAll handles POST /updates/ - batch updates multiple metrics.
+// The batch is applied atomically: if any metric is invalid nothing is stored.
 // Responses:
 //   - 200: Metrics updated successfully
-//   - 400: Invalid JSON input
+//   - 400: Invalid JSON input or invalid metrics; the message of invalid
+//     metrics starts with "invalid metric" (storage.ErrInvalidMetric), so
+//     clients can tell it from a failed HMAC check or decryption
 //   - 500: Internal server error
 func (h *MetricsHandler) UpdateAll(w http.ResponseWriter, r *http.Request) {
 	var metrics []models.MetricJSON
@@ -284,12 +327,67 @@ func (h *MetricsHandler) UpdateAll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := validateLabels(metrics); err != nil {
+		logger.Log.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if client := middleware.ClientIdentity(r.Context()); client != "" {
+		logger.Log.Sugar().Infow("metrics batch received", "client", client, "count", len(metrics))
+	}
+
 	operation := func() error {
 		return h.storage.UpdateAll(r.Context(), metrics)
 	}
 
 	if err := resilience.Retry(r.Context(), operation); err != nil {
+		updateError(w, err)
+		return
+	}
+}
+
+// updateError responds to a failed storage update: 400 for batches the
+// storage refused as invalid, with the message of the storage error starting
+// with "invalid metric", and 500 otherwise
+func updateError(w http.ResponseWriter, err error) {
+	if !errors.Is(err, storage.ErrInvalidMetric) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+
+	// Skip the context added around the storage error, e.g. by resilience.Retry
+	message := err.Error()
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if strings.HasPrefix(e.Error(), storage.ErrInvalidMetric.Error()) {
+			message = e.Error()
+			break
+		}
+	}
+	http.Error(w, message, http.StatusBadRequest)
+}
+
+// validateLabels checks the labels of every metric in a batch.
+// The error wraps storage.ErrInvalidMetric.
+func validateLabels(metrics []models.MetricJSON) error {
+	for _, metric := range metrics {
+		if err := models.ValidateLabels(metric.ID, metric.Labels); err != nil {
+			return fmt.Errorf("%w: %w", storage.ErrInvalidMetric, err)
+		}
+	}
+	return nil
+}
+
+// labelsFromQuery collects label filters from the request query string
+func labelsFromQuery(r *http.Request) models.Labels {
+	query := r.URL.Query()
+	if len(query) == 0 {
+		return nil
+	}
+
+	labels := make(models.Labels, len(query))
+	for name := range query {
+		labels[name] = query.Get(name)
+	}
+	return labels
 }
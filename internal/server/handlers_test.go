@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -12,6 +13,7 @@ import (
 	"github.com/go-chi/chi"
 	"github.com/runtime-metrics-course/internal/mocks"
 	"github.com/runtime-metrics-course/internal/models"
+	"github.com/runtime-metrics-course/internal/storage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -50,6 +52,14 @@ func TestUpdateHandler(t *testing.T) {
 			},
 			expectedCode: http.StatusBadRequest,
 		},
+		{
+			name:   "Metric name with labels",
+			url:    "/update/gauge/a%7Bx=%221%22%7D/1",
+			method: http.MethodPost,
+			setupMock: func(storage *mocks.StorageIface) {
+			},
+			expectedCode: http.StatusBadRequest,
+		},
 		{
 			name:   "Invalid gauge value",
 			url:    "/update/gauge/temperature/not-a-number",
@@ -108,11 +118,9 @@ func TestGetMetricValue(t *testing.T) {
 			url:    "/value/gauge/temperature",
 			method: http.MethodGet,
 			setupMock: func(storage *mocks.StorageIface) {
-				storage.On("GetMetrics", mock.Anything).Return(models.Metrics{
-					Gauges: models.Gauges{
-						"temperature": 25.5,
-					},
-				}, nil)
+				value := 25.5
+				storage.On("GetMetric", mock.Anything, models.Gauge, "temperature").
+					Return(models.MetricJSON{ID: "temperature", MType: models.Gauge, Value: &value}, nil)
 			},
 			expectedCode: http.StatusOK,
 			expectedBody: "25.5",
@@ -122,11 +130,9 @@ func TestGetMetricValue(t *testing.T) {
 			url:    "/value/counter/requests",
 			method: http.MethodGet,
 			setupMock: func(storage *mocks.StorageIface) {
-				storage.On("GetMetrics", mock.Anything).Return(models.Metrics{
-					Counters: models.Counters{
-						"requests": 42,
-					},
-				}, nil)
+				delta := int64(42)
+				storage.On("GetMetric", mock.Anything, models.Counter, "requests").
+					Return(models.MetricJSON{ID: "requests", MType: models.Counter, Delta: &delta}, nil)
 			},
 			expectedCode: http.StatusOK,
 			expectedBody: "42",
@@ -135,10 +141,9 @@ func TestGetMetricValue(t *testing.T) {
 			name:   "Unknown gauge metric",
 			url:    "/value/gauge/unknown",
 			method: http.MethodGet,
-			setupMock: func(storage *mocks.StorageIface) {
-				storage.On("GetMetrics", mock.Anything).Return(models.Metrics{
-					Gauges: models.Gauges{},
-				}, nil)
+			setupMock: func(s *mocks.StorageIface) {
+				s.On("GetMetric", mock.Anything, models.Gauge, "unknown").
+					Return(models.MetricJSON{}, storage.ErrMetricNotFound)
 			},
 			expectedCode: http.StatusNotFound,
 			expectedBody: "Unknown metric\n",
@@ -147,24 +152,30 @@ func TestGetMetricValue(t *testing.T) {
 			name:   "Unknown counter metric",
 			url:    "/value/counter/unknown",
 			method: http.MethodGet,
-			setupMock: func(storage *mocks.StorageIface) {
-				storage.On("GetMetrics", mock.Anything).Return(models.Metrics{
-					Counters: models.Counters{},
-				}, nil)
+			setupMock: func(s *mocks.StorageIface) {
+				s.On("GetMetric", mock.Anything, models.Counter, "unknown").
+					Return(models.MetricJSON{}, storage.ErrMetricNotFound)
 			},
 			expectedCode: http.StatusNotFound,
 			expectedBody: "Unknown metric\n",
 		},
 		{
-			name:   "Invalid metric type",
-			url:    "/value/invalid/metric",
+			name:   "Labeled gauge metric",
+			url:    "/value/gauge/load?host=web-2",
 			method: http.MethodGet,
 			setupMock: func(storage *mocks.StorageIface) {
-				storage.On("GetMetrics", mock.Anything).Return(models.Metrics{
-					Counters: models.Counters{},
-					Gauges:   models.Gauges{},
-				}, nil)
+				value := 2.5
+				storage.On("GetMetric", mock.Anything, models.Gauge, `load{host="web-2"}`).
+					Return(models.MetricJSON{ID: "load", MType: models.Gauge, Value: &value}, nil)
 			},
+			expectedCode: http.StatusOK,
+			expectedBody: "2.5",
+		},
+		{
+			name:         "Invalid metric type",
+			url:          "/value/invalid/metric",
+			method:       http.MethodGet,
+			setupMock:    func(storage *mocks.StorageIface) {},
 			expectedCode: http.StatusBadRequest,
 			expectedBody: "Unknown metric type\n",
 		},
@@ -219,11 +230,8 @@ func TestGetMetricValueJSONHandler(t *testing.T) {
 				MType: models.Gauge,
 			},
 			setupMock: func(storage *mocks.StorageIface) {
-				storage.On("GetMetrics", mock.Anything).Return(models.Metrics{
-					Gauges: models.Gauges{
-						"temperature": 25.5,
-					},
-				}, nil)
+				storage.On("GetMetric", mock.Anything, models.Gauge, "temperature").
+					Return(models.MetricJSON{ID: "temperature", MType: models.Gauge, Value: &testValue}, nil)
 			},
 			expectedCode: http.StatusOK,
 			expectedBody: models.MetricJSON{
@@ -232,6 +240,27 @@ func TestGetMetricValueJSONHandler(t *testing.T) {
 				Value: &testValue,
 			},
 		},
+		{
+			name:   "Labeled gauge metric",
+			url:    "/value/",
+			method: http.MethodPost,
+			body: models.MetricJSON{
+				ID:     "temperature",
+				MType:  models.Gauge,
+				Labels: models.Labels{"room": "kitchen"},
+			},
+			setupMock: func(storage *mocks.StorageIface) {
+				storage.On("GetMetric", mock.Anything, models.Gauge, `temperature{room="kitchen"}`).
+					Return(models.MetricJSON{ID: "temperature", MType: models.Gauge, Value: &testValue}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: models.MetricJSON{
+				ID:     "temperature",
+				MType:  models.Gauge,
+				Labels: models.Labels{"room": "kitchen"},
+				Value:  &testValue,
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -269,6 +298,64 @@ func TestGetMetricValueJSONHandler(t *testing.T) {
 		})
 	}
 }
+func TestUpdateJSONDistributions(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         models.MetricJSON
+		setupMock    func(storage *mocks.StorageIface)
+		expectedCode int
+	}{
+		{
+			name: "Valid histogram",
+			body: models.MetricJSON{ID: "GCPauseNs", MType: models.Histogram, Buckets: []float64{1e4}, Counts: []uint64{1, 0}},
+			setupMock: func(storage *mocks.StorageIface) {
+				storage.On("UpdateAll", mock.Anything, mock.Anything).Return(nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Histogram with missing +Inf bucket",
+			body:         models.MetricJSON{ID: "GCPauseNs", MType: models.Histogram, Buckets: []float64{1e4}, Counts: []uint64{1}},
+			setupMock:    func(storage *mocks.StorageIface) {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "Valid summary",
+			body: models.MetricJSON{ID: "size", MType: models.Summary, Observations: []float64{1, 2}},
+			setupMock: func(storage *mocks.StorageIface) {
+				storage.On("UpdateAll", mock.Anything, mock.Anything).Return(nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Summary without observations",
+			body:         models.MetricJSON{ID: "size", MType: models.Summary},
+			setupMock:    func(storage *mocks.StorageIface) {},
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := mocks.NewStorageIface(t)
+			tt.setupMock(storage)
+
+			r := chi.NewRouter()
+			h := NewMetricsHandler(storage)
+			r.Post("/update/", h.UpdateJSON)
+
+			testBody, err := json.Marshal(tt.body)
+			require.NoError(t, err)
+			req := httptest.NewRequest(http.MethodPost, "/update/", bytes.NewBuffer(testBody))
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+		})
+	}
+}
+
 func TestUpdateAllHandler(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -302,6 +389,18 @@ func TestUpdateAllHandler(t *testing.T) {
 			},
 			expectedCode: http.StatusInternalServerError,
 		},
+		{
+			name:   "Invalid metrics in batch",
+			url:    "/update/",
+			method: http.MethodPost,
+			body: []models.MetricJSON{
+				{ID: "latency", MType: models.Histogram, Buckets: []float64{2}, Counts: []uint64{1, 0}},
+			},
+			setupMock: func(s *mocks.StorageIface) {
+				s.On("UpdateAll", mock.Anything, mock.Anything).Return(fmt.Errorf("%w: latency: bucket layout changed", storage.ErrInvalidMetric))
+			},
+			expectedCode: http.StatusBadRequest,
+		},
 		{
 			name:   "Internal server error on update",
 			url:    "/update/",
@@ -365,11 +464,8 @@ func BenchmarkUpdateHandler(b *testing.B) {
 
 func BenchmarkGetMetricValue(b *testing.B) {
 	storage := mocks.NewStorageIface(b)
-	storage.On("GetMetrics", mock.Anything).Return(models.Metrics{
-		Gauges: models.Gauges{
-			"temperature": 25.5,
-		},
-	}, nil)
+	storage.On("GetMetric", mock.Anything, models.Gauge, "temperature").
+		Return(models.MetricJSON{ID: "temperature", MType: models.Gauge, Value: pointerToFloat64(25.5)}, nil)
 
 	r := chi.NewRouter()
 	h := NewMetricsHandler(storage)
@@ -388,12 +484,8 @@ func BenchmarkGetMetricValue(b *testing.B) {
 
 func BenchmarkGetMetricValueJSON(b *testing.B) {
 	storage := mocks.NewStorageIface(b)
-	testValue := 25.5
-	storage.On("GetMetrics", mock.Anything).Return(models.Metrics{
-		Gauges: models.Gauges{
-			"temperature": testValue,
-		},
-	}, nil)
+	storage.On("GetMetric", mock.Anything, models.Gauge, "temperature").
+		Return(models.MetricJSON{ID: "temperature", MType: models.Gauge, Value: pointerToFloat64(25.5)}, nil)
 
 	r := chi.NewRouter()
 	h := NewMetricsHandler(storage)
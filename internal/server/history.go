@@ -0,0 +1,140 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/runtime-metrics-course/internal/logger"
+	"github.com/runtime-metrics-course/internal/models"
+	"github.com/runtime-metrics-course/internal/storage"
+)
+
+// defaultHistoryRange is the queried time range when "from" is not set
+const defaultHistoryRange = time.Hour
+
+// GetHistory handles GET /history/{metric_type}/{name} - returns recorded values
+// of a metric series in JSON format.
+// Query parameters:
+//   - from, to: range bounds as RFC3339 or unix seconds (defaults to the last hour)
+//   - step: optional downsampling interval (e.g. 30s), keeps the last point per interval
+//   - any other parameter selects the labeled series, as in GetMetricValue
+//
+// Responses:
+//   - 200: JSON response with recorded points
+//   - 400: Invalid metric type or query parameters
+//   - 501: History recording is disabled
+//   - 500: Internal server error
+func (h *MetricsHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	metricType := chi.URLParam(r, "metric_type")
+	if metricType != Gauge && metricType != Counter {
+		http.Error(w, "Unknown metric type", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	now := time.Now()
+
+	to, err := parseHistoryTime(query.Get("to"), now)
+	if err != nil {
+		http.Error(w, "Invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, err := parseHistoryTime(query.Get("from"), to.Add(-defaultHistoryRange))
+	if err != nil {
+		http.Error(w, "Invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if from.After(to) {
+		http.Error(w, "from must not be after to", http.StatusBadRequest)
+		return
+	}
+
+	var step time.Duration
+	if s := query.Get("step"); s != "" {
+		step, err = time.ParseDuration(s)
+		if err != nil || step <= 0 {
+			http.Error(w, "Invalid step", http.StatusBadRequest)
+			return
+		}
+	}
+
+	query.Del("from")
+	query.Del("to")
+	query.Del("step")
+	labels := make(models.Labels, len(query))
+	for name := range query {
+		labels[name] = query.Get(name)
+	}
+	name := chi.URLParam(r, "name")
+
+	points, err := h.storage.GetHistory(r.Context(), metricType, models.SeriesKey(name, labels), from, to)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		if errors.Is(err, storage.ErrHistoryDisabled) {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := models.MetricHistory{
+		ID:     name,
+		MType:  metricType,
+		Points: downsample(points, from, step),
+	}
+	if len(labels) != 0 {
+		resp.Labels = labels
+	}
+
+	respData, err := json.Marshal(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(respData)
+}
+
+// parseHistoryTime parses a range bound given as RFC3339 or unix seconds.
+// Returns def if the value is empty.
+func parseHistoryTime(value string, def time.Time) (time.Time, error) {
+	if value == "" {
+		return def, nil
+	}
+	if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected RFC3339 or unix seconds, got %q", value)
+	}
+	return t, nil
+}
+
+// downsample reduces points to at most one per step interval counted from
+// the start of the range, keeping the last point of every interval.
+// A zero step returns points unchanged. Points must be ordered by time.
+func downsample(points []models.Point, from time.Time, step time.Duration) []models.Point {
+	if step <= 0 || len(points) == 0 {
+		return points
+	}
+
+	result := make([]models.Point, 0, len(points))
+	bucket := int64(-1)
+	for _, p := range points {
+		b := int64(p.Time.Sub(from) / step)
+		if b == bucket {
+			result[len(result)-1] = p
+			continue
+		}
+		bucket = b
+		result = append(result, p)
+	}
+	return result
+}
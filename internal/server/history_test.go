@@ -0,0 +1,112 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/runtime-metrics-course/internal/mocks"
+	"github.com/runtime-metrics-course/internal/models"
+	"github.com/runtime-metrics-course/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetHistoryHandler(t *testing.T) {
+	from := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+	points := []models.Point{
+		{Value: pointerToFloat64(1), Time: from.Add(10 * time.Second)},
+		{Value: pointerToFloat64(2), Time: from.Add(20 * time.Second)},
+		{Value: pointerToFloat64(3), Time: from.Add(70 * time.Second)},
+	}
+
+	tests := []struct {
+		name           string
+		url            string
+		setupMock      func(storage *mocks.StorageIface)
+		expectedCode   int
+		expectedPoints []models.Point
+	}{
+		{
+			name: "All points",
+			url:  "/history/gauge/HeapAlloc?from=2025-03-01T12:00:00Z&to=2025-03-01T13:00:00Z",
+			setupMock: func(s *mocks.StorageIface) {
+				s.On("GetHistory", mock.Anything, models.Gauge, "HeapAlloc", from, to).Return(points, nil)
+			},
+			expectedCode:   http.StatusOK,
+			expectedPoints: points,
+		},
+		{
+			name: "Downsampled by step",
+			url:  "/history/gauge/HeapAlloc?from=2025-03-01T12:00:00Z&to=2025-03-01T13:00:00Z&step=1m",
+			setupMock: func(s *mocks.StorageIface) {
+				s.On("GetHistory", mock.Anything, models.Gauge, "HeapAlloc", from, to).Return(points, nil)
+			},
+			expectedCode:   http.StatusOK,
+			expectedPoints: []models.Point{points[1], points[2]},
+		},
+		{
+			name: "Labeled series with unix bounds",
+			url:  "/history/gauge/CPUutilization?cpu=1&from=1740830400&to=1740834000",
+			setupMock: func(s *mocks.StorageIface) {
+				s.On("GetHistory", mock.Anything, models.Gauge, `CPUutilization{cpu="1"}`, from.Local(), to.Local()).Return(points[:1], nil)
+			},
+			expectedCode:   http.StatusOK,
+			expectedPoints: points[:1],
+		},
+		{
+			name:         "Invalid metric type",
+			url:          "/history/unknown/HeapAlloc",
+			setupMock:    func(s *mocks.StorageIface) {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Invalid step",
+			url:          "/history/gauge/HeapAlloc?step=fast",
+			setupMock:    func(s *mocks.StorageIface) {},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "History disabled",
+			url:  "/history/counter/PollCount",
+			setupMock: func(s *mocks.StorageIface) {
+				s.On("GetHistory", mock.Anything, models.Counter, "PollCount", mock.Anything, mock.Anything).Return(nil, storage.ErrHistoryDisabled)
+			},
+			expectedCode: http.StatusNotImplemented,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := mocks.NewStorageIface(t)
+			tt.setupMock(storage)
+
+			r := chi.NewRouter()
+			h := NewMetricsHandler(storage)
+			r.Get("/history/{metric_type}/{name}", h.GetHistory)
+
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			require.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+
+			var resp models.MetricHistory
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			require.Len(t, resp.Points, len(tt.expectedPoints))
+			for i, p := range tt.expectedPoints {
+				assert.Equal(t, *p.Value, *resp.Points[i].Value)
+				assert.True(t, p.Time.Equal(resp.Points[i].Time))
+			}
+		})
+	}
+}
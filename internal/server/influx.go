@@ -0,0 +1,178 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/runtime-metrics-course/internal/influx"
+	"github.com/runtime-metrics-course/internal/logger"
+	"github.com/runtime-metrics-course/internal/models"
+	"github.com/runtime-metrics-course/internal/resilience"
+)
+
+// InfluxLineError is the error of a line that was not stored
+type InfluxLineError struct {
+	Line  int    `json:"line"`  // Line number, starting at 1
+	Error string `json:"error"` // Why the line was rejected
+}
+
+// InfluxWriteReport is the response to a write with malformed lines
+type InfluxWriteReport struct {
+	Errors   []InfluxLineError `json:"errors"`   // Rejected lines
+	Accepted int               `json:"accepted"` // Number of stored metrics
+}
+
+// influxMetric is a metric converted from a field, with the point timestamp
+type influxMetric struct {
+	at     time.Time
+	metric models.MetricJSON
+}
+
+// InfluxWrite handles POST /write - stores metrics sent in the InfluxDB line protocol.
+// Every numeric or boolean field becomes a gauge named measurement_field, or
+// measurement for a field named "value"; tags become labels. String fields
+// are ignored. Integer fields of the metrics listed in the counters query
+// parameter (comma separated) are added to counters instead.
+// Timestamps are read in units of the precision query parameter (ns by
+// default); when a series has several values, the latest one is stored.
+// Malformed lines are reported, the valid lines are stored anyway.
+// Responses:
+//   - 204: All lines stored
+//   - 400: Invalid precision, or some lines were malformed (JSON InfluxWriteReport)
+//   - 500: Internal server error
+func (h *MetricsHandler) InfluxWrite(w http.ResponseWriter, r *http.Request) {
+	precision, err := influx.ParsePrecision(r.URL.Query().Get("precision"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	counters := make(map[string]bool)
+	for _, name := range strings.Split(r.URL.Query().Get("counters"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			counters[name] = true
+		}
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var (
+		converted []influxMetric
+		report    InfluxWriteReport
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		metrics, err := influxMetrics(line, precision, counters)
+		if err != nil {
+			report.Errors = append(report.Errors, InfluxLineError{Line: n, Error: err.Error()})
+			continue
+		}
+		converted = append(converted, metrics...)
+	}
+
+	// The latest value of a gauge is stored last
+	sort.SliceStable(converted, func(i, j int) bool {
+		return converted[i].at.Before(converted[j].at)
+	})
+	metrics := make([]models.MetricJSON, len(converted))
+	for i, m := range converted {
+		metrics[i] = m.metric
+	}
+
+	if len(metrics) != 0 {
+		operation := func() error {
+			return h.storage.UpdateAll(r.Context(), metrics)
+		}
+		if err := resilience.Retry(r.Context(), operation); err != nil {
+			updateError(w, err)
+			return
+		}
+	}
+
+	if len(report.Errors) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	report.Accepted = len(metrics)
+	respData, err := json.Marshal(report)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write(respData)
+}
+
+// influxMetrics converts the fields of a line into metrics. Points without a
+// timestamp get the current time.
+func influxMetrics(line string, precision time.Duration, counters map[string]bool) ([]influxMetric, error) {
+	p, err := influx.ParseLine(line, precision)
+	if err != nil {
+		return nil, err
+	}
+	at := p.Time
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	var metrics []influxMetric
+	for _, field := range p.Fields {
+		name := p.Measurement
+		if field.Key != "value" {
+			name += "_" + field.Key
+		}
+		if err := models.ValidateLabels(name, p.Tags); err != nil {
+			return nil, err
+		}
+
+		metric := models.MetricJSON{ID: name, MType: models.Gauge, Labels: p.Tags}
+		var value float64
+		switch v := field.Value.(type) {
+		case float64:
+			value = v
+		case int64:
+			value = float64(v)
+			if counters[name] {
+				metric.MType, metric.Delta = models.Counter, &v
+			}
+		case uint64:
+			value = float64(v)
+			if counters[name] {
+				if v > 1<<63-1 {
+					return nil, fmt.Errorf("field %q: counter increment %d out of range", field.Key, v)
+				}
+				delta := int64(v)
+				metric.MType, metric.Delta = models.Counter, &delta
+			}
+		case bool:
+			if v {
+				value = 1
+			}
+		default:
+			continue
+		}
+		if metric.MType == models.Gauge {
+			metric.Value = &value
+		}
+		metrics = append(metrics, influxMetric{at: at, metric: metric})
+	}
+	return metrics, nil
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/runtime-metrics-course/internal/mocks"
+	"github.com/runtime-metrics-course/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInfluxWrite(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMemStorage()
+	h := NewMetricsHandler(st)
+
+	body := "# comment\n" +
+		"cpu,host=web-1 user=12.5,system=3i,state=\"ok\" 1700000002\n" +
+		"cpu,host=web-1 user=10 1700000001\n" +
+		"temperature value=21.5\n" +
+		"jobs,queue=mail processed=5i,busy=true\n" +
+		"jobs,queue=mail processed=2i\n"
+	r := httptest.NewRequest(http.MethodPost, "/write?precision=s&counters=jobs_processed", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	h.InfluxWrite(w, r)
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+
+	metrics, err := st.GetMetrics(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 12.5, metrics.Gauges[`cpu_user{host="web-1"}`], "the latest point wins")
+	assert.Equal(t, 3.0, metrics.Gauges[`cpu_system{host="web-1"}`])
+	assert.NotContains(t, metrics.Gauges, `cpu_state{host="web-1"}`)
+	assert.Equal(t, 21.5, metrics.Gauges["temperature"])
+	assert.Equal(t, 1.0, metrics.Gauges[`jobs_busy{queue="mail"}`])
+	assert.Equal(t, int64(7), metrics.Counters[`jobs_processed{queue="mail"}`])
+}
+
+func TestInfluxWrite_MalformedLines(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMemStorage()
+	h := NewMetricsHandler(st)
+
+	body := "temperature value=21.5\n" +
+		"cpu\n" +
+		"bad{name} value=1\n" +
+		"humidity value=40\n"
+	r := httptest.NewRequest(http.MethodPost, "/write", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	h.InfluxWrite(w, r)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	var report InfluxWriteReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, 2, report.Accepted)
+	require.Len(t, report.Errors, 2)
+	assert.Equal(t, 2, report.Errors[0].Line)
+	assert.Equal(t, 3, report.Errors[1].Line)
+
+	metrics, err := st.GetMetrics(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 21.5, metrics.Gauges["temperature"])
+	assert.Equal(t, 40.0, metrics.Gauges["humidity"])
+}
+
+func TestInfluxWrite_Errors(t *testing.T) {
+	tests := []struct {
+		name         string
+		url          string
+		setupMock    func(storage *mocks.StorageIface)
+		expectedCode int
+	}{
+		{name: "Invalid precision", url: "/write?precision=d", expectedCode: http.StatusBadRequest},
+		{
+			name: "Storage error",
+			url:  "/write",
+			setupMock: func(storage *mocks.StorageIface) {
+				storage.On("UpdateAll", mock.Anything, mock.Anything).Return(assert.AnError)
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := mocks.NewStorageIface(t)
+			if tt.setupMock != nil {
+				tt.setupMock(st)
+			}
+
+			r := httptest.NewRequest(http.MethodPost, tt.url, bytes.NewBufferString("temperature value=1\n"))
+			w := httptest.NewRecorder()
+			NewMetricsHandler(st).InfluxWrite(w, r)
+			assert.Equal(t, tt.expectedCode, w.Code)
+		})
+	}
+}
@@ -0,0 +1,351 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/runtime-metrics-course/internal/logger"
+	"github.com/runtime-metrics-course/internal/models"
+	"github.com/runtime-metrics-course/internal/resilience"
+	"github.com/runtime-metrics-course/internal/storage"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// OTLP/HTTP content types
+const (
+	otlpProtobuf = "application/x-protobuf"
+	otlpJSON     = "application/json"
+)
+
+// otlpServiceName is the resource attribute added to the labels of every data point
+const otlpServiceName = "service.name"
+
+// otlpCumulative is the last data point of a cumulative monotonic sum series
+type otlpCumulative struct {
+	start uint64  // Start time of the series, changes when the producer restarts
+	value float64 // Last reported cumulative value
+}
+
+// otlpState is the per-series state changed by a request. It is committed to
+// the handler only after the request has been stored, so a request retried
+// after a storage error is converted the same way again.
+type otlpState struct {
+	cumulative map[string]otlpCumulative
+	upDown     map[string]float64
+}
+
+// OTLPHandler receives metrics exported by OpenTelemetry SDKs over OTLP/HTTP.
+//
+// Data points are converted as follows:
+//   - Gauge - gauges
+//   - monotonic Sum, delta temporality - counters of the point values
+//   - monotonic Sum, cumulative temporality - counters of the increments
+//     between points; the first point of a series only sets the baseline,
+//     unless the series started after the handler was created
+//   - non-monotonic Sum - gauges; delta points change the last value
+//
+// Histogram, ExponentialHistogram and Summary metrics are rejected and
+// reported as a partial success. Sum values are rounded to integer counters.
+//
+// OTLPHandler is safe for concurrent use; requests are converted and stored
+// one at a time, so the series state always matches the stored metrics.
+type OTLPHandler struct {
+	storage storage.StorageIface
+	started time.Time
+
+	mu         sync.Mutex                // Held while a request is converted and stored
+	cumulative map[string]otlpCumulative // Last cumulative sums by series key
+	upDown     map[string]float64        // Values of delta non-monotonic sums by series key
+}
+
+// NewOTLPHandler creates a new OTLPHandler instance
+func NewOTLPHandler(storage storage.StorageIface) *OTLPHandler {
+	return &OTLPHandler{
+		storage:    storage,
+		started:    time.Now(),
+		cumulative: make(map[string]otlpCumulative),
+		upDown:     make(map[string]float64),
+	}
+}
+
+// Export handles POST /v1/metrics - stores an OTLP ExportMetricsServiceRequest
+// encoded as protobuf (application/x-protobuf) or JSON (application/json).
+// The response is an ExportMetricsServiceResponse in the request encoding;
+// rejected data points are reported in its partial_success field.
+// Responses:
+//   - 200: Metrics stored (possibly partially)
+//   - 400: Malformed request
+//   - 415: Unsupported content type
+//   - 503: Storage error, the exporter should retry
+func (h *OTLPHandler) Export(w http.ResponseWriter, r *http.Request) {
+	contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (contentType != otlpProtobuf && contentType != otlpJSON) {
+		http.Error(w, "content type must be "+otlpProtobuf+" or "+otlpJSON, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req colmetricspb.ExportMetricsServiceRequest
+	if contentType == otlpProtobuf {
+		err = proto.Unmarshal(data, &req)
+	} else {
+		err = protojson.Unmarshal(data, &req)
+	}
+	if err != nil {
+		logger.Log.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rejected, reason, err := h.store(r.Context(), &req)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		// OTLP exporters retry on 503 only
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	resp := &colmetricspb.ExportMetricsServiceResponse{}
+	if rejected != 0 {
+		logger.Log.Sugar().Warnf("OTLP: rejected %d data points: %s", rejected, reason)
+		resp.PartialSuccess = &colmetricspb.ExportMetricsPartialSuccess{
+			RejectedDataPoints: rejected,
+			ErrorMessage:       reason,
+		}
+	}
+
+	var respData []byte
+	if contentType == otlpProtobuf {
+		respData, err = proto.Marshal(resp)
+	} else {
+		respData, err = protojson.Marshal(resp)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(respData)
+}
+
+// store converts and stores a request, then commits the series state.
+// Returns the number of rejected data points and the reason of the last
+// rejection, or the storage error.
+func (h *OTLPHandler) store(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) (int64, string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	state := &otlpState{
+		cumulative: make(map[string]otlpCumulative),
+		upDown:     make(map[string]float64),
+	}
+	metrics, rejected, reason := h.convert(req, state)
+	if len(metrics) != 0 {
+		operation := func() error {
+			return h.storage.UpdateAll(ctx, metrics)
+		}
+		if err := resilience.Retry(ctx, operation); err != nil {
+			return 0, "", err
+		}
+	}
+
+	for key, c := range state.cumulative {
+		h.cumulative[key] = c
+	}
+	for key, v := range state.upDown {
+		h.upDown[key] = v
+	}
+	return rejected, reason, nil
+}
+
+// convert turns the data points of a request into metric updates, recording
+// the new series state in state. Returns the number of rejected data points
+// and the reason of the last rejection. Caller must hold h.mu.
+func (h *OTLPHandler) convert(req *colmetricspb.ExportMetricsServiceRequest, state *otlpState) ([]models.MetricJSON, int64, string) {
+	var (
+		metrics  []models.MetricJSON
+		rejected int64
+		reason   string
+	)
+	for _, rm := range req.GetResourceMetrics() {
+		service := otlpAttribute(rm.GetResource().GetAttributes(), otlpServiceName)
+		for _, sm := range rm.GetScopeMetrics() {
+			for _, m := range sm.GetMetrics() {
+				var points []*metricspb.NumberDataPoint
+				switch data := m.GetData().(type) {
+				case *metricspb.Metric_Gauge:
+					points = data.Gauge.GetDataPoints()
+				case *metricspb.Metric_Sum:
+					points = data.Sum.GetDataPoints()
+				default:
+					n := otlpDataPoints(m)
+					if n != 0 {
+						rejected += int64(n)
+						reason = fmt.Sprintf("%s: only Gauge and Sum metrics are supported", m.GetName())
+					}
+					continue
+				}
+
+				for _, p := range points {
+					labels := otlpLabels(p.GetAttributes(), service)
+					if err := models.ValidateLabels(m.GetName(), labels); err != nil {
+						rejected++
+						reason = err.Error()
+						continue
+					}
+					if metric, ok := h.convertPoint(m, p, labels, state); ok {
+						metrics = append(metrics, metric)
+					}
+				}
+			}
+		}
+	}
+	return metrics, rejected, reason
+}
+
+// convertPoint converts a Gauge or Sum data point. Returns false for points
+// without a value and for the baseline points of cumulative sums.
+func (h *OTLPHandler) convertPoint(m *metricspb.Metric, p *metricspb.NumberDataPoint, labels models.Labels, state *otlpState) (models.MetricJSON, bool) {
+	value := p.GetAsDouble()
+	if v, ok := p.GetValue().(*metricspb.NumberDataPoint_AsInt); ok {
+		value = float64(v.AsInt)
+	}
+	if p.GetFlags()&uint32(metricspb.DataPointFlags_DATA_POINT_FLAGS_NO_RECORDED_VALUE_MASK) != 0 ||
+		math.IsNaN(value) || math.IsInf(value, 0) {
+		return models.MetricJSON{}, false
+	}
+
+	metric := models.MetricJSON{ID: m.GetName(), Labels: labels}
+	key := models.SeriesKey(metric.ID, labels)
+
+	sum := m.GetSum()
+	if sum == nil {
+		metric.MType = models.Gauge
+		metric.Value = &value
+		return metric, true
+	}
+
+	delta := sum.GetAggregationTemporality() == metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+	if !sum.GetIsMonotonic() {
+		if delta {
+			total, ok := state.upDown[key]
+			if !ok {
+				total = h.upDown[key]
+			}
+			value += total
+			state.upDown[key] = value
+		}
+		metric.MType = models.Gauge
+		metric.Value = &value
+		return metric, true
+	}
+
+	increase := value
+	if !delta {
+		var ok bool
+		if increase, ok = h.cumulativeIncrease(key, p.GetStartTimeUnixNano(), value, state); !ok {
+			return models.MetricJSON{}, false
+		}
+	}
+	counter := int64(math.Round(increase))
+	metric.MType = models.Counter
+	metric.Delta = &counter
+	return metric, true
+}
+
+// cumulativeIncrease returns the increase of a cumulative sum since its
+// previous point. A changed start time or a lower value means the producer
+// restarted, so the whole value is the increase.
+func (h *OTLPHandler) cumulativeIncrease(key string, start uint64, value float64, state *otlpState) (float64, bool) {
+	prev, ok := state.cumulative[key]
+	if !ok {
+		prev, ok = h.cumulative[key]
+	}
+	state.cumulative[key] = otlpCumulative{start: start, value: value}
+
+	switch {
+	case !ok:
+		// Everything counted so far is new only if the series started after us
+		return value, start != 0 && start >= uint64(h.started.UnixNano())
+	case start != prev.start || value < prev.value:
+		return value, true
+	default:
+		// Rounded separately, so that rounding errors do not add up
+		return math.Round(value) - math.Round(prev.value), true
+	}
+}
+
+// otlpDataPoints returns the number of data points of a metric
+func otlpDataPoints(m *metricspb.Metric) int {
+	switch data := m.GetData().(type) {
+	case *metricspb.Metric_Histogram:
+		return len(data.Histogram.GetDataPoints())
+	case *metricspb.Metric_ExponentialHistogram:
+		return len(data.ExponentialHistogram.GetDataPoints())
+	case *metricspb.Metric_Summary:
+		return len(data.Summary.GetDataPoints())
+	}
+	return 0
+}
+
+// otlpLabels converts data point attributes into labels and adds the service
+// name of the resource. Attributes without a scalar value are skipped.
+func otlpLabels(attributes []*commonpb.KeyValue, service string) models.Labels {
+	labels := make(models.Labels, len(attributes)+1)
+	if service != "" {
+		labels[otlpServiceName] = service
+	}
+	for _, kv := range attributes {
+		if value, ok := otlpValue(kv.GetValue()); ok {
+			labels[kv.GetKey()] = value
+		}
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+	return labels
+}
+
+// otlpAttribute returns the scalar value of an attribute, empty if not set
+func otlpAttribute(attributes []*commonpb.KeyValue, key string) string {
+	for _, kv := range attributes {
+		if kv.GetKey() == key {
+			value, _ := otlpValue(kv.GetValue())
+			return value
+		}
+	}
+	return ""
+}
+
+// otlpValue formats a scalar attribute value. Returns false for arrays,
+// key-value lists, bytes and empty values.
+func otlpValue(v *commonpb.AnyValue) (string, bool) {
+	switch value := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return value.StringValue, true
+	case *commonpb.AnyValue_BoolValue:
+		return strconv.FormatBool(value.BoolValue), true
+	case *commonpb.AnyValue_IntValue:
+		return strconv.FormatInt(value.IntValue, 10), true
+	case *commonpb.AnyValue_DoubleValue:
+		return strconv.FormatFloat(value.DoubleValue, 'g', -1, 64), true
+	}
+	return "", false
+}
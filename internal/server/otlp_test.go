@@ -0,0 +1,222 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/runtime-metrics-course/internal/mocks"
+	"github.com/runtime-metrics-course/internal/models"
+	"github.com/runtime-metrics-course/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func otlpRequest(metrics ...*metricspb.Metric) *colmetricspb.ExportMetricsServiceRequest {
+	return &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource:     &resourcepb.Resource{Attributes: []*commonpb.KeyValue{otlpString("service.name", "api")}},
+			ScopeMetrics: []*metricspb.ScopeMetrics{{Metrics: metrics}},
+		}},
+	}
+}
+
+func otlpString(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
+
+func otlpSum(name string, temporality metricspb.AggregationTemporality, monotonic bool, start uint64, value int64) *metricspb.Metric {
+	return &metricspb.Metric{Name: name, Data: &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+		AggregationTemporality: temporality,
+		IsMonotonic:            monotonic,
+		DataPoints: []*metricspb.NumberDataPoint{{
+			StartTimeUnixNano: start,
+			Value:             &metricspb.NumberDataPoint_AsInt{AsInt: value},
+		}},
+	}}}
+}
+
+// exportOTLP posts a protobuf encoded request and decodes the response
+func exportOTLP(t *testing.T, h *OTLPHandler, req *colmetricspb.ExportMetricsServiceRequest) *colmetricspb.ExportMetricsServiceResponse {
+	t.Helper()
+	body, err := proto.Marshal(req)
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/metrics", bytes.NewReader(body))
+	r.Header.Set("Content-Type", otlpProtobuf)
+	w := httptest.NewRecorder()
+	h.Export(w, r)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, otlpProtobuf, w.Header().Get("Content-Type"))
+
+	var resp colmetricspb.ExportMetricsServiceResponse
+	require.NoError(t, proto.Unmarshal(w.Body.Bytes(), &resp))
+	return &resp
+}
+
+func TestOTLPHandler_Export(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMemStorage()
+	h := NewOTLPHandler(st)
+	before := uint64(h.started.Add(-time.Hour).UnixNano())
+	after := uint64(h.started.Add(time.Second).UnixNano())
+
+	gauge := &metricspb.Metric{Name: "queue.size", Data: &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{
+		DataPoints: []*metricspb.NumberDataPoint{{
+			Attributes: []*commonpb.KeyValue{otlpString("queue", "jobs")},
+			Value:      &metricspb.NumberDataPoint_AsDouble{AsDouble: 2.5},
+		}},
+	}}}
+	resp := exportOTLP(t, h, otlpRequest(
+		gauge,
+		otlpSum("requests.delta", metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA, true, 0, 3),
+		otlpSum("requests.old", metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE, true, before, 100),
+		otlpSum("requests.new", metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE, true, after, 7),
+		otlpSum("connections", metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA, false, 0, 4),
+	))
+	assert.Nil(t, resp.PartialSuccess)
+
+	metrics, err := st.GetMetrics(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2.5, metrics.Gauges[`queue.size{queue="jobs",service.name="api"}`])
+	assert.Equal(t, int64(3), metrics.Counters[`requests.delta{service.name="api"}`])
+	assert.Equal(t, int64(7), metrics.Counters[`requests.new{service.name="api"}`], "started after the handler")
+	assert.NotContains(t, metrics.Counters, `requests.old{service.name="api"}`, "first point is the baseline")
+	assert.Equal(t, 4.0, metrics.Gauges[`connections{service.name="api"}`])
+
+	exportOTLP(t, h, otlpRequest(
+		otlpSum("requests.delta", metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA, true, 0, 2),
+		otlpSum("requests.old", metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE, true, before, 110),
+		// The producer restarted
+		otlpSum("requests.new", metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE, true, after+1, 5),
+		otlpSum("connections", metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA, false, 0, -1),
+	))
+
+	metrics, err = st.GetMetrics(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), metrics.Counters[`requests.delta{service.name="api"}`])
+	assert.Equal(t, int64(10), metrics.Counters[`requests.old{service.name="api"}`])
+	assert.Equal(t, int64(12), metrics.Counters[`requests.new{service.name="api"}`])
+	assert.Equal(t, 3.0, metrics.Gauges[`connections{service.name="api"}`])
+}
+
+func TestOTLPHandler_RetryAfterStorageError(t *testing.T) {
+	st := mocks.NewStorageIface(t)
+	h := NewOTLPHandler(st)
+	after := uint64(h.started.Add(time.Second).UnixNano())
+	req := otlpRequest(
+		otlpSum("requests", metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE, true, after, 7),
+		otlpSum("connections", metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA, false, 0, 4),
+	)
+	body, err := proto.Marshal(req)
+	require.NoError(t, err)
+
+	st.On("UpdateAll", mock.Anything, mock.Anything).Return(assert.AnError).Once()
+	r := httptest.NewRequest(http.MethodPost, "/v1/metrics", bytes.NewReader(body))
+	r.Header.Set("Content-Type", otlpProtobuf)
+	w := httptest.NewRecorder()
+	h.Export(w, r)
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	// The exporter retries the same request
+	var stored []models.MetricJSON
+	st.On("UpdateAll", mock.Anything, mock.Anything).Return(nil).Once().Run(func(args mock.Arguments) {
+		stored = args.Get(1).([]models.MetricJSON)
+	})
+	exportOTLP(t, h, req)
+
+	require.Len(t, stored, 2)
+	assert.Equal(t, int64(7), *stored[0].Delta, "the increment is not lost")
+	assert.Equal(t, 4.0, *stored[1].Value, "the delta is not added twice")
+}
+
+func TestOTLPHandler_PartialSuccess(t *testing.T) {
+	st := storage.NewMemStorage()
+	h := NewOTLPHandler(st)
+
+	histogram := &metricspb.Metric{Name: "latency", Data: &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+		DataPoints: []*metricspb.HistogramDataPoint{{Count: 1}, {Count: 2}},
+	}}}
+	invalid := otlpSum("bad{name}", metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA, true, 0, 1)
+	resp := exportOTLP(t, h, otlpRequest(
+		histogram,
+		invalid,
+		otlpSum("requests", metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA, true, 0, 1),
+	))
+
+	require.NotNil(t, resp.PartialSuccess)
+	assert.Equal(t, int64(3), resp.PartialSuccess.RejectedDataPoints)
+	assert.NotEmpty(t, resp.PartialSuccess.ErrorMessage)
+
+	metrics, err := st.GetMetrics(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), metrics.Counters[`requests{service.name="api"}`])
+}
+
+func TestOTLPHandler_Requests(t *testing.T) {
+	jsonBody := `{"resourceMetrics":[{"scopeMetrics":[{"metrics":[{"name":"temperature","gauge":{"dataPoints":[{"asDouble":21.5}]}}]}]}]}`
+
+	tests := []struct {
+		name         string
+		contentType  string
+		body         string
+		setupMock    func(storage *mocks.StorageIface)
+		expectedCode int
+	}{
+		{
+			name:        "JSON encoding",
+			contentType: "application/json; charset=utf-8",
+			body:        jsonBody,
+			setupMock: func(storage *mocks.StorageIface) {
+				storage.On("UpdateAll", mock.Anything, mock.Anything).Return(nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Unsupported content type",
+			contentType:  "text/plain",
+			body:         jsonBody,
+			expectedCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:         "Malformed protobuf",
+			contentType:  otlpProtobuf,
+			body:         "not protobuf",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:        "Storage error",
+			contentType: otlpJSON,
+			body:        jsonBody,
+			setupMock: func(storage *mocks.StorageIface) {
+				storage.On("UpdateAll", mock.Anything, mock.Anything).Return(assert.AnError)
+			},
+			expectedCode: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := mocks.NewStorageIface(t)
+			if tt.setupMock != nil {
+				tt.setupMock(st)
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/v1/metrics", bytes.NewBufferString(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			NewOTLPHandler(st).Export(w, r)
+
+			assert.Equal(t, tt.expectedCode, w.Code, w.Body.String())
+		})
+	}
+}
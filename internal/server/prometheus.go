@@ -0,0 +1,265 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/runtime-metrics-course/internal/logger"
+	"github.com/runtime-metrics-course/internal/models"
+)
+
+// prometheusContentType is the content type of the Prometheus text exposition format
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// PrometheusMetrics handles GET /metrics - returns all metrics in the Prometheus
+// text exposition format
+// Responses:
+//   - 200: Metrics in Prometheus text format
+//   - 500: Internal server error
+func (h *MetricsHandler) PrometheusMetrics(w http.ResponseWriter, r *http.Request) {
+	metrics, err := h.storage.GetMetrics(r.Context())
+	if err != nil {
+		logger.Log.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", prometheusContentType)
+	w.Write(renderPrometheus(metrics))
+}
+
+// promLabelValueEscaper escapes label values as required by the text format
+var promLabelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// promFamily groups the series exposed under one metric name
+type promFamily struct {
+	metric string // Metric name the family was created for
+	mType  string
+	series [][]string // Sample lines of every series in the family
+}
+
+// renderPrometheus converts metrics to the Prometheus text exposition format.
+// Metric families and their series are sorted so the output is stable between scrapes.
+//
+// Series are added by type (gauges, counters, histograms, summaries) and
+// series key. A series is skipped with a warning if its sanitized name is
+// already exposed with another type or for another metric name, e.g. "a.b"
+// after "a_b", or a gauge "a_count" before a histogram "a". Series whose
+// labels cannot be exposed (see sanitizePrometheusLabels) are skipped too.
+func renderPrometheus(metrics models.Metrics) []byte {
+	families := make(map[string]*promFamily)
+	owners := make(map[string]string) // Sample name -> name of the family exposing it
+
+	add := func(key, mType string, samples func(name string, labels models.Labels) []string) {
+		name, labels := models.ParseSeriesKey(key)
+		promName := sanitizePrometheusName(name)
+
+		promLabels, err := sanitizePrometheusLabels(labels, mType)
+		if err != nil {
+			logger.Log.Sugar().Warnf("metric %q skipped: %v", key, err)
+			return
+		}
+
+		family, ok := families[promName]
+		if !ok {
+			sampleNames := promSampleNames(promName, mType)
+			for _, sampleName := range sampleNames {
+				if owner, taken := owners[sampleName]; taken {
+					logger.Log.Sugar().Warnf("metric %q skipped: name %s already exposed for %q", key, sampleName, families[owner].metric)
+					return
+				}
+			}
+			family = &promFamily{metric: name, mType: mType}
+			families[promName] = family
+			for _, sampleName := range sampleNames {
+				owners[sampleName] = promName
+			}
+		}
+		if family.mType != mType {
+			logger.Log.Sugar().Warnf("metric %q skipped: name already exposed as %s", key, family.mType)
+			return
+		}
+		if family.metric != name {
+			logger.Log.Sugar().Warnf("metric %q skipped: name %s already exposed for %q", key, promName, family.metric)
+			return
+		}
+		family.series = append(family.series, samples(promName, promLabels))
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(metrics.Gauges)) {
+		value := metrics.Gauges[key]
+		add(key, models.Gauge, func(name string, labels models.Labels) []string {
+			return []string{promSample(name, labels, formatPrometheusFloat(value))}
+		})
+	}
+	for _, key := range slices.Sorted(maps.Keys(metrics.Counters)) {
+		value := metrics.Counters[key]
+		add(key, models.Counter, func(name string, labels models.Labels) []string {
+			return []string{promSample(name, labels, strconv.FormatInt(value, 10))}
+		})
+	}
+	for _, key := range slices.Sorted(maps.Keys(metrics.Histograms)) {
+		value := metrics.Histograms[key]
+		add(key, models.Histogram, func(name string, labels models.Labels) []string {
+			return histogramSamples(name, labels, value)
+		})
+	}
+	for _, key := range slices.Sorted(maps.Keys(metrics.Summaries)) {
+		value := metrics.Summaries[key]
+		add(key, models.Summary, func(name string, labels models.Labels) []string {
+			return summarySamples(name, labels, value)
+		})
+	}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		family := families[name]
+		sort.Slice(family.series, func(i, j int) bool {
+			return family.series[i][0] < family.series[j][0]
+		})
+		buf.WriteString("# TYPE " + name + " " + family.mType + "\n")
+		for _, series := range family.series {
+			for _, sample := range series {
+				buf.WriteString(sample + "\n")
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+// promSampleNames returns the family name and the sample names a family of
+// the type exposes
+func promSampleNames(name, mType string) []string {
+	switch mType {
+	case models.Histogram:
+		return []string{name, name + "_bucket", name + "_sum", name + "_count"}
+	case models.Summary:
+		return []string{name, name + "_sum", name + "_count"}
+	}
+	return []string{name}
+}
+
+// histogramSamples renders cumulative _bucket samples followed by _sum and _count
+func histogramSamples(name string, labels models.Labels, h models.HistogramValue) []string {
+	samples := make([]string, 0, len(h.Counts)+2)
+	var cumulative uint64
+	for i, count := range h.Counts {
+		cumulative += count
+		le := "+Inf"
+		if i < len(h.Bounds) {
+			le = formatPrometheusFloat(h.Bounds[i])
+		}
+		samples = append(samples, promSample(name+"_bucket", withLabel(labels, "le", le), strconv.FormatUint(cumulative, 10)))
+	}
+	return append(samples,
+		promSample(name+"_sum", labels, formatPrometheusFloat(h.Sum)),
+		promSample(name+"_count", labels, strconv.FormatUint(h.Count, 10)),
+	)
+}
+
+// summarySamples renders quantile samples followed by _sum and _count
+func summarySamples(name string, labels models.Labels, s models.SummaryValue) []string {
+	samples := make([]string, 0, len(models.SummaryQuantiles)+2)
+	if len(s.Window) != 0 {
+		for _, q := range models.SummaryQuantiles {
+			quantile := formatPrometheusFloat(q)
+			samples = append(samples, promSample(name, withLabel(labels, "quantile", quantile), formatPrometheusFloat(s.Quantile(q))))
+		}
+	}
+	return append(samples,
+		promSample(name+"_sum", labels, formatPrometheusFloat(s.Sum)),
+		promSample(name+"_count", labels, strconv.FormatUint(s.Count, 10)),
+	)
+}
+
+// promSample renders a single sample line without the trailing newline
+func promSample(name string, labels models.Labels, value string) string {
+	return name + formatPrometheusLabels(labels) + " " + value
+}
+
+// withLabel returns a copy of labels with one more label set
+func withLabel(labels models.Labels, name, value string) models.Labels {
+	result := make(models.Labels, len(labels)+1)
+	for k, v := range labels {
+		result[k] = v
+	}
+	result[name] = value
+	return result
+}
+
+// formatPrometheusFloat formats a float as accepted by the text format (NaN, +Inf, -Inf)
+func formatPrometheusFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// sanitizePrometheusLabels returns labels with names mapped onto the
+// Prometheus label charset. Fails if two names are the same after
+// sanitizing, e.g. "service.name" and "service_name", or a name is used by
+// the samples of the metric type (le for histograms, quantile for summaries).
+func sanitizePrometheusLabels(labels models.Labels, mType string) (models.Labels, error) {
+	result := make(models.Labels, len(labels))
+	for name, value := range labels {
+		// Colons are reserved for metric names
+		labelName := strings.ReplaceAll(sanitizePrometheusName(name), ":", "_")
+		if _, ok := result[labelName]; ok {
+			return nil, fmt.Errorf("labels collide as %s", labelName)
+		}
+		if (mType == models.Histogram && labelName == "le") || (mType == models.Summary && labelName == "quantile") {
+			return nil, fmt.Errorf("label %s is reserved for %s samples", labelName, mType)
+		}
+		result[labelName] = value
+	}
+	return result, nil
+}
+
+// formatPrometheusLabels renders a label set sanitized by
+// sanitizePrometheusLabels as {name="value",...} sorted by name
+func formatPrometheusLabels(labels models.Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(labels))
+	for name, value := range labels {
+		pairs = append(pairs, name+`="`+promLabelValueEscaper.Replace(value)+`"`)
+	}
+	sort.Strings(pairs)
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// sanitizePrometheusName maps an arbitrary metric name onto the Prometheus
+// name charset [a-zA-Z_:][a-zA-Z0-9_:]*, e.g. "CPUutilization 0" becomes
+// "CPUutilization_0".
+func sanitizePrometheusName(name string) string {
+	if name == "" {
+		return "_"
+	}
+
+	var b strings.Builder
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_', c == ':':
+			b.WriteRune(c)
+		case c >= '0' && c <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(c)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
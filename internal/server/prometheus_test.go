@@ -0,0 +1,209 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/runtime-metrics-course/internal/mocks"
+	"github.com/runtime-metrics-course/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	tests := []struct {
+		name         string
+		setupMock    func(storage *mocks.StorageIface)
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "Gauges and counters",
+			setupMock: func(storage *mocks.StorageIface) {
+				storage.On("GetMetrics", mock.Anything).Return(models.Metrics{
+					Gauges: models.Gauges{
+						"HeapAlloc":        1024,
+						"CPUutilization 0": 12.5,
+					},
+					Counters: models.Counters{
+						"PollCount": 7,
+					},
+				}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: "# TYPE CPUutilization_0 gauge\n" +
+				"CPUutilization_0 12.5\n" +
+				"# TYPE HeapAlloc gauge\n" +
+				"HeapAlloc 1024\n" +
+				"# TYPE PollCount counter\n" +
+				"PollCount 7\n",
+		},
+		{
+			name: "Labeled series",
+			setupMock: func(storage *mocks.StorageIface) {
+				storage.On("GetMetrics", mock.Anything).Return(models.Metrics{
+					Gauges: models.Gauges{
+						`CPUutilization{cpu="1"}`:              20,
+						`CPUutilization{cpu="0",host="web-1"}`: 10,
+					},
+					Counters: models.Counters{
+						`requests{path="/a\"b"}`: 3,
+					},
+				}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: "# TYPE CPUutilization gauge\n" +
+				`CPUutilization{cpu="0",host="web-1"} 10` + "\n" +
+				`CPUutilization{cpu="1"} 20` + "\n" +
+				"# TYPE requests counter\n" +
+				`requests{path="/a\"b"} 3` + "\n",
+		},
+		{
+			name: "Histograms and summaries",
+			setupMock: func(storage *mocks.StorageIface) {
+				storage.On("GetMetrics", mock.Anything).Return(models.Metrics{
+					Histograms: models.Histograms{
+						"GCPauseNs": {Bounds: []float64{1e4, 1e5}, Counts: []uint64{2, 1, 1}, Sum: 250000, Count: 4},
+					},
+					Summaries: models.Summaries{
+						`size{host="a"}`: {Window: []models.Observation{{Value: 2}}, Sum: 2, Count: 1},
+					},
+				}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: "# TYPE GCPauseNs histogram\n" +
+				`GCPauseNs_bucket{le="10000"} 2` + "\n" +
+				`GCPauseNs_bucket{le="100000"} 3` + "\n" +
+				`GCPauseNs_bucket{le="+Inf"} 4` + "\n" +
+				"GCPauseNs_sum 250000\n" +
+				"GCPauseNs_count 4\n" +
+				"# TYPE size summary\n" +
+				`size{host="a",quantile="0.5"} 2` + "\n" +
+				`size{host="a",quantile="0.9"} 2` + "\n" +
+				`size{host="a",quantile="0.99"} 2` + "\n" +
+				`size_sum{host="a"} 2` + "\n" +
+				`size_count{host="a"} 1` + "\n",
+		},
+		{
+			name: "Sanitized name collisions",
+			setupMock: func(storage *mocks.StorageIface) {
+				storage.On("GetMetrics", mock.Anything).Return(models.Metrics{
+					Gauges: models.Gauges{
+						"a.b":           2,
+						"a_b":           1,
+						`a_b{host="x"}`: 3,
+					},
+					Counters: models.Counters{
+						"a_b": 4,
+					},
+				}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: "# TYPE a_b gauge\n" +
+				"a_b 2\n",
+		},
+		{
+			name: "Generated sample name collisions",
+			setupMock: func(storage *mocks.StorageIface) {
+				storage.On("GetMetrics", mock.Anything).Return(models.Metrics{
+					Gauges: models.Gauges{
+						"size_count": 5,
+					},
+					Histograms: models.Histograms{
+						"latency":     {Bounds: []float64{1}, Counts: []uint64{1, 0}, Sum: 0.5, Count: 1},
+						"latency_sum": {Bounds: []float64{1}, Counts: []uint64{1, 0}, Sum: 0.5, Count: 1},
+					},
+					Summaries: models.Summaries{
+						"size": {Sum: 2, Count: 1},
+					},
+				}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: "# TYPE latency histogram\n" +
+				`latency_bucket{le="1"} 1` + "\n" +
+				`latency_bucket{le="+Inf"} 1` + "\n" +
+				"latency_sum 0.5\n" +
+				"latency_count 1\n" +
+				"# TYPE size_count gauge\n" +
+				"size_count 5\n",
+		},
+		{
+			name: "Label collisions",
+			setupMock: func(storage *mocks.StorageIface) {
+				storage.On("GetMetrics", mock.Anything).Return(models.Metrics{
+					Gauges: models.Gauges{
+						`up{service.name="a",service_name="b"}`: 1,
+						`up{le="1"}`:                            2,
+					},
+					Histograms: models.Histograms{
+						`latency{le="1"}`: {Bounds: []float64{1}, Counts: []uint64{1, 0}, Sum: 0.5, Count: 1},
+					},
+					Summaries: models.Summaries{
+						`size{quantile="0.5"}`: {Sum: 2, Count: 1},
+					},
+				}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: "# TYPE up gauge\n" +
+				`up{le="1"} 2` + "\n",
+		},
+		{
+			name: "Empty storage",
+			setupMock: func(storage *mocks.StorageIface) {
+				storage.On("GetMetrics", mock.Anything).Return(models.Metrics{}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: "",
+		},
+		{
+			name: "Storage error",
+			setupMock: func(storage *mocks.StorageIface) {
+				storage.On("GetMetrics", mock.Anything).Return(models.Metrics{}, errors.New("storage error"))
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: "storage error\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := mocks.NewStorageIface(t)
+			tt.setupMock(storage)
+
+			r := chi.NewRouter()
+			h := NewMetricsHandler(storage)
+			r.Get("/metrics", h.PrometheusMetrics)
+
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			assert.Equal(t, tt.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestSanitizePrometheusName(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "HeapAlloc", want: "HeapAlloc"},
+		{in: "CPUutilization 0", want: "CPUutilization_0"},
+		{in: "9lives", want: "_9lives"},
+		{in: "http.requests-total", want: "http_requests_total"},
+		{in: "ns:metric", want: "ns:metric"},
+		{in: "", want: "_"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			assert.Equal(t, tt.want, sanitizePrometheusName(tt.in))
+		})
+	}
+}
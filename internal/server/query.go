@@ -0,0 +1,92 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/runtime-metrics-course/internal/logger"
+	"github.com/runtime-metrics-course/internal/models"
+	"github.com/runtime-metrics-course/internal/storage"
+)
+
+// Page sizes of GET /api/v1/metrics
+const (
+	defaultQueryLimit = 100  // Page size when limit is not set
+	maxQueryLimit     = 1000 // Largest accepted page size
+)
+
+// QueryMetrics handles GET /api/v1/metrics - returns a page of stored series
+// in JSON format, e.g. /api/v1/metrics?match=Heap*&type=gauge&limit=50
+// Query parameters:
+//   - match: glob over the metric name, * matches any characters and ? one
+//   - regex: regular expression matched anywhere in the metric name
+//   - type: gauge, counter, histogram or summary
+//   - sort: name (default), type or value; a "-" prefix reverses the order
+//   - limit: page size, 100 by default and at most 1000
+//   - offset: number of series skipped
+//   - cursor: the "next" cursor of the previous page
+//   - any other parameter selects the series with that label value
+//
+// Responses:
+//   - 200: JSON response with the page (models.MetricPage)
+//   - 400: Invalid query parameters
+//   - 500: Internal server error
+func (h *MetricsHandler) QueryMetrics(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := models.MetricQuery{
+		Match:  params.Get("match"),
+		Regex:  params.Get("regex"),
+		Type:   params.Get("type"),
+		Sort:   params.Get("sort"),
+		Cursor: params.Get("cursor"),
+		Limit:  defaultQueryLimit,
+	}
+
+	var err error
+	if s := params.Get("limit"); s != "" {
+		query.Limit, err = strconv.Atoi(s)
+		if err != nil || query.Limit < 1 || query.Limit > maxQueryLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxQueryLimit), http.StatusBadRequest)
+			return
+		}
+	}
+	if s := params.Get("offset"); s != "" {
+		query.Offset, err = strconv.Atoi(s)
+		if err != nil || query.Offset < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+
+	for _, name := range []string{"match", "regex", "type", "sort", "limit", "offset", "cursor"} {
+		params.Del(name)
+	}
+	if len(params) != 0 {
+		query.Labels = make(models.Labels, len(params))
+		for name := range params {
+			query.Labels[name] = params.Get(name)
+		}
+	}
+
+	page, err := h.storage.Query(r.Context(), query)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		if errors.Is(err, storage.ErrInvalidQuery) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respData, err := json.Marshal(page)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(respData)
+}
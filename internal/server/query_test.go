@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/runtime-metrics-course/internal/mocks"
+	"github.com/runtime-metrics-course/internal/models"
+	"github.com/runtime-metrics-course/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryMetricsHandler(t *testing.T) {
+	page := models.MetricPage{
+		Metrics: []models.MetricJSON{{ID: "HeapAlloc", MType: models.Gauge, Value: pointerToFloat64(1)}},
+		Next:    "next",
+		Total:   2,
+	}
+
+	tests := []struct {
+		name         string
+		url          string
+		setupMock    func(s *mocks.StorageIface)
+		expectedCode int
+	}{
+		{
+			name: "Filters and page",
+			url:  "/api/v1/metrics?match=Heap*&type=gauge&sort=-value&limit=50&offset=5&cursor=abc",
+			setupMock: func(s *mocks.StorageIface) {
+				s.On("Query", mock.Anything, models.MetricQuery{
+					Match: "Heap*", Type: models.Gauge, Sort: "-value", Cursor: "abc", Limit: 50, Offset: 5,
+				}).Return(page, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "Default limit and labels",
+			url:  "/api/v1/metrics?regex=^load&host=web-1",
+			setupMock: func(s *mocks.StorageIface) {
+				s.On("Query", mock.Anything, models.MetricQuery{
+					Regex: "^load", Labels: models.Labels{"host": "web-1"}, Limit: defaultQueryLimit,
+				}).Return(page, nil)
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Limit too large",
+			url:          fmt.Sprintf("/api/v1/metrics?limit=%d", maxQueryLimit+1),
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Invalid offset",
+			url:          "/api/v1/metrics?offset=-1",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "Invalid query",
+			url:  "/api/v1/metrics?type=meter",
+			setupMock: func(s *mocks.StorageIface) {
+				s.On("Query", mock.Anything, mock.Anything).Return(models.MetricPage{}, storage.ErrInvalidQuery)
+			},
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name: "Storage error",
+			url:  "/api/v1/metrics",
+			setupMock: func(s *mocks.StorageIface) {
+				s.On("Query", mock.Anything, mock.Anything).Return(models.MetricPage{}, assert.AnError)
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := mocks.NewStorageIface(t)
+			if tt.setupMock != nil {
+				tt.setupMock(s)
+			}
+
+			w := httptest.NewRecorder()
+			NewMetricsHandler(s).QueryMetrics(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			require.Equal(t, tt.expectedCode, w.Code, w.Body.String())
+			if tt.expectedCode == http.StatusOK {
+				assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+				var got models.MetricPage
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+				assert.Equal(t, page, got)
+			}
+		})
+	}
+}
+
+func TestQueryMetricsHandler_Pages(t *testing.T) {
+	st := storage.NewMemStorage()
+	for _, name := range []string{"HeapAlloc", "HeapIdle", "HeapSys", "StackSys"} {
+		require.NoError(t, st.UpdateGauge(context.Background(), name, 1))
+	}
+	h := NewMetricsHandler(st)
+
+	var names []string
+	url := "/api/v1/metrics?match=Heap*&limit=2"
+	for url != "" {
+		w := httptest.NewRecorder()
+		h.QueryMetrics(w, httptest.NewRequest(http.MethodGet, url, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var page models.MetricPage
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		assert.Equal(t, 3, page.Total)
+		for _, m := range page.Metrics {
+			names = append(names, m.ID)
+		}
+
+		url = ""
+		if page.Next != "" {
+			url = "/api/v1/metrics?match=Heap*&limit=2&cursor=" + page.Next
+		}
+	}
+	assert.Equal(t, []string{"HeapAlloc", "HeapIdle", "HeapSys"}, names)
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+
+	"github.com/runtime-metrics-course/internal/logger"
+	"github.com/runtime-metrics-course/internal/models"
+	"github.com/runtime-metrics-course/internal/remotewrite"
+	"github.com/runtime-metrics-course/internal/resilience"
+)
+
+// maxRemoteWriteBodySize limits the compressed size of a remote write request
+const maxRemoteWriteBodySize = 16 << 20
+
+// RemoteWrite handles POST /api/v1/write - stores series sent with Prometheus
+// remote write (snappy compressed protobuf WriteRequest).
+// Every series is stored as a gauge with the __name__ label as the metric ID
+// and the other labels preserved; the sample with the latest timestamp is
+// stored. Prometheus counters are stored as gauges of their cumulative value.
+// Stale markers and other non-finite samples are skipped.
+// Series without a name or with invalid labels are rejected, the valid
+// series are stored anyway; Prometheus does not retry 4xx responses.
+// Responses:
+//   - 204: Series stored
+//   - 400: Malformed request or rejected series
+//   - 413: Request body larger than maxRemoteWriteBodySize, or decompressing
+//     to more than remotewrite.MaxDecodedSize
+//   - 500: Internal server error, Prometheus retries the request
+func (h *MetricsHandler) RemoteWrite(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRemoteWriteBodySize))
+	if err != nil {
+		logger.Log.Error(err.Error())
+		status := http.StatusBadRequest
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	req, err := remotewrite.Decode(body)
+	if err != nil {
+		logger.Log.Error(err.Error())
+		status := http.StatusBadRequest
+		if errors.Is(err, remotewrite.ErrTooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	var (
+		metrics []models.MetricJSON
+		errs    []error
+	)
+	for i := range req.Timeseries {
+		metric, ok, err := remoteWriteMetric(&req.Timeseries[i])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("series %d: %w", i, err))
+			continue
+		}
+		if ok {
+			metrics = append(metrics, metric)
+		}
+	}
+
+	if len(metrics) != 0 {
+		operation := func() error {
+			return h.storage.UpdateAll(r.Context(), metrics)
+		}
+		if err := resilience.Retry(r.Context(), operation); err != nil {
+			updateError(w, err)
+			return
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		logger.Log.Sugar().Warnf("remote write: rejected %d of %d series: %v", len(errs), len(req.Timeseries), err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// remoteWriteMetric converts the latest finite sample of a series into a gauge.
+// Returns false if the series has no such sample.
+func remoteWriteMetric(ts *remotewrite.TimeSeries) (models.MetricJSON, bool, error) {
+	name, labels, err := ts.Metric()
+	if err != nil {
+		return models.MetricJSON{}, false, err
+	}
+	if err := models.ValidateLabels(name, labels); err != nil {
+		return models.MetricJSON{}, false, err
+	}
+
+	var latest *remotewrite.Sample
+	for i, s := range ts.Samples {
+		if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+			continue
+		}
+		if latest == nil || s.Timestamp >= latest.Timestamp {
+			latest = &ts.Samples[i]
+		}
+	}
+	if latest == nil {
+		return models.MetricJSON{}, false, nil
+	}
+
+	value := latest.Value
+	return models.MetricJSON{ID: name, MType: models.Gauge, Value: &value, Labels: labels}, true, nil
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/runtime-metrics-course/internal/mocks"
+	"github.com/runtime-metrics-course/internal/remotewrite"
+	"github.com/runtime-metrics-course/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteWrite(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMemStorage()
+	h := NewMetricsHandler(st)
+
+	staleNaN := math.Float64frombits(0x7ff0000000000002)
+	body := remotewrite.Encode(&remotewrite.WriteRequest{Timeseries: []remotewrite.TimeSeries{
+		{
+			Labels: []remotewrite.Label{{Name: "__name__", Value: "node_load1"}, {Name: "instance", Value: "web-1"}},
+			Samples: []remotewrite.Sample{
+				{Value: 0.7, Timestamp: 2000},
+				{Value: 0.5, Timestamp: 1000},
+			},
+		},
+		{
+			Labels:  []remotewrite.Label{{Name: "__name__", Value: "http_requests_total"}},
+			Samples: []remotewrite.Sample{{Value: 1234, Timestamp: 1000}, {Value: staleNaN, Timestamp: 2000}},
+		},
+		{
+			Labels:  []remotewrite.Label{{Name: "__name__", Value: "gone"}},
+			Samples: []remotewrite.Sample{{Value: staleNaN, Timestamp: 1000}},
+		},
+		// Rejected: no name
+		{
+			Labels:  []remotewrite.Label{{Name: "job", Value: "node"}},
+			Samples: []remotewrite.Sample{{Value: 1, Timestamp: 1000}},
+		},
+	}})
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/write", bytes.NewReader(body))
+	r.Header.Set("Content-Encoding", "snappy")
+	w := httptest.NewRecorder()
+	h.RemoteWrite(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "series 3")
+
+	metrics, err := st.GetMetrics(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0.7, metrics.Gauges[`node_load1{instance="web-1"}`], "the latest sample wins")
+	assert.Equal(t, 1234.0, metrics.Gauges["http_requests_total"])
+	assert.NotContains(t, metrics.Gauges, "gone")
+}
+
+func TestRemoteWrite_Responses(t *testing.T) {
+	valid := remotewrite.Encode(&remotewrite.WriteRequest{Timeseries: []remotewrite.TimeSeries{{
+		Labels:  []remotewrite.Label{{Name: "__name__", Value: "up"}},
+		Samples: []remotewrite.Sample{{Value: 1, Timestamp: 1000}},
+	}}})
+
+	tests := []struct {
+		name         string
+		body         []byte
+		setupMock    func(storage *mocks.StorageIface)
+		expectedCode int
+	}{
+		{
+			name: "Stored",
+			body: valid,
+			setupMock: func(storage *mocks.StorageIface) {
+				storage.On("UpdateAll", mock.Anything, mock.Anything).Return(nil)
+			},
+			expectedCode: http.StatusNoContent,
+		},
+		{name: "Not snappy", body: []byte("plain"), expectedCode: http.StatusBadRequest},
+		{name: "Body too large", body: make([]byte, maxRemoteWriteBodySize+1), expectedCode: http.StatusRequestEntityTooLarge},
+		{
+			name:         "Decompressed body too large",
+			body:         append(binary.AppendUvarint(nil, remotewrite.MaxDecodedSize+1), 0),
+			expectedCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "Storage error",
+			body: valid,
+			setupMock: func(storage *mocks.StorageIface) {
+				storage.On("UpdateAll", mock.Anything, mock.Anything).Return(assert.AnError)
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := mocks.NewStorageIface(t)
+			if tt.setupMock != nil {
+				tt.setupMock(st)
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/write", bytes.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			NewMetricsHandler(st).RemoteWrite(w, r)
+			assert.Equal(t, tt.expectedCode, w.Code)
+		})
+	}
+}
@@ -6,75 +6,154 @@ import (
 	"net/http/pprof"
 
 	"github.com/go-chi/chi"
+	"github.com/runtime-metrics-course/internal/alerting"
 	"github.com/runtime-metrics-course/internal/logger"
 	"github.com/runtime-metrics-course/internal/middleware"
 	"github.com/runtime-metrics-course/internal/storage"
+	"github.com/runtime-metrics-course/internal/tlsconfig"
 )
 
+// Config contains the HTTP server settings
+type Config struct {
+	Address       string           // Server listen address (e.g. ":8080")
+	SecretKey     string           // Secret key for request authentication (empty disables auth)
+	CryptoKeyPath string           // Path to the private key for request decryption (empty disables)
+	TrustedSubnet string           // CIDR allowed to write metrics, checked against X-Real-IP (empty allows all)
+	GRPCAddress   string           // gRPC server listen address (see InitGRPCServer)
+	TLS           tlsconfig.Config // HTTPS and mutual TLS settings (plain HTTP if not enabled)
+	Alerts        *alerting.Engine // Alerting engine exposed on /alerts (nil disables the route)
+}
+
 // InitServer initializes and starts the HTTP server with configured routes and middleware.
 //
-// Parameters:
-//   - address: Server listen address (e.g. ":8080")
-//   - secretKey: Secret key for request authentication (empty disables auth)
-//
 // Returns:
 //   - error if server fails to start
 //
 // Routes configured:
 //   - GET / - Main metrics endpoint
+//   - GET /metrics - Prometheus text exposition endpoint
 //   - GET /ping - Database health check
+//   - GET /history/{metric_type}/{name} - Recorded values of a metric series
+//   - GET /api/v1/metrics - Filtered, sorted and paginated metric query
+//   - GET /alerts - Alert states (if an alerting engine is configured)
 //   - POST /updates/ - Batch update metrics
+//   - POST /v1/metrics - OpenTelemetry OTLP/HTTP metrics export
+//   - POST /write - InfluxDB line protocol write
+//   - POST /api/v1/write - Prometheus remote write
 //   - /value/ - Metric retrieval endpoints
 //   - /update/ - Metric update endpoints
 //
 // Middleware applied:
+//   - Client certificate identity (with mutual TLS)
 //   - Request logging
+//   - Trusted subnet check of the update and ingestion routes (if TrustedSubnet provided)
 //   - Response compression
-//   - HMAC authentication (if secretKey provided)
-func InitServer(address, secretKey, cryptoKeyPath string) error {
+//   - HMAC authentication (if SecretKey provided, agent routes only)
+//   - Request decryption (if CryptoKeyPath provided, agent routes only)
+func InitServer(cfg Config) error {
 	storage, err := storage.GetStorageManager().GetStorage()
 	if err != nil {
 		return err
 	}
 
+	r, err := newRouter(cfg, storage)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{Addr: cfg.Address, Handler: r}
+	if cfg.TLS.Enabled() {
+		tlsCfg, err := tlsconfig.Server(cfg.TLS)
+		if err != nil {
+			return err
+		}
+		srv.TLSConfig = tlsCfg
+
+		logger.Log.Sugar().Infoln("Server starting with TLS on", cfg.Address)
+		return srv.ListenAndServeTLS("", "")
+	}
+
+	logger.Log.Sugar().Infoln("Server starting on", cfg.Address)
+	return srv.ListenAndServe()
+}
+
+// newRouter builds the HTTP routes and middleware described in InitServer.
+// The OTLP, InfluxDB and remote write endpoints are called by third-party
+// clients that neither sign nor encrypt their requests, so the agent HMAC and
+// decryption middleware is applied only to the agent routes.
+func newRouter(cfg Config, storage storage.StorageIface) (http.Handler, error) {
 	r := chi.NewRouter()
 
 	// Apply middleware stack
+	r.Use(middleware.ClientIdentityMiddleware)
 	r.Use(middleware.LoggerMiddleware)
 	r.Use(middleware.CompressMiddleware)
-	if secretKey != "" {
-		r.Use(middleware.NewHashMiddleware([]byte(secretKey)).Middleware)
+
+	// Middleware of the routes writing metrics; reads such as POST /value/ are
+	// allowed from any address
+	var writeMiddlewares []func(http.Handler) http.Handler
+	if cfg.TrustedSubnet != "" {
+		subnetMiddleware, err := middleware.NewTrustedSubnetMiddleware(cfg.TrustedSubnet)
+		if err != nil {
+			return nil, err
+		}
+		writeMiddlewares = append(writeMiddlewares, subnetMiddleware.Middleware)
+	}
+
+	// Middleware of the agent routes
+	var agentMiddlewares []func(http.Handler) http.Handler
+	if cfg.SecretKey != "" {
+		agentMiddlewares = append(agentMiddlewares, middleware.NewHashMiddleware([]byte(cfg.SecretKey)).Middleware)
 	}
-	if cryptoKeyPath != "" {
-		cryptoMiddleware, err := middleware.NewCryptoMiddleware(cryptoKeyPath)
+	if cfg.CryptoKeyPath != "" {
+		cryptoMiddleware, err := middleware.NewCryptoMiddleware(cfg.CryptoKeyPath)
 		if err != nil {
-			return fmt.Errorf("failed to init crypto middleware: %w", err)
+			return nil, fmt.Errorf("failed to init crypto middleware: %w", err)
 		}
-		r.Use(cryptoMiddleware.Middleware)
+		agentMiddlewares = append(agentMiddlewares, cryptoMiddleware.Middleware)
 	}
+
 	// Initialize metrics handler
 	mh := NewMetricsHandler(storage)
 
-	// Configure routes
-	r.Mount("/debug", pprofRouter())
-	r.Get("/", mh.GetMetrics)
-	r.Get("/ping", mh.PingDBHandler)
-	r.Post("/updates/", mh.UpdateAll)
-
-	// Metric value routes
-	r.Route("/value/", func(r chi.Router) {
-		r.Post("/", mh.GetMetricValueJSON)                // JSON endpoint
-		r.Get("/{metric_type}/{name}", mh.GetMetricValue) // Plaintext endpoint
+	// Third-party ingestion routes
+	r.Group(func(r chi.Router) {
+		r.Use(writeMiddlewares...)
+		r.Post("/v1/metrics", NewOTLPHandler(storage).Export)
+		r.Post("/write", mh.InfluxWrite)
+		r.Post("/api/v1/write", mh.RemoteWrite)
 	})
 
-	// Metric update routes
-	r.Route("/update/", func(r chi.Router) {
-		r.Post("/", mh.UpdateJSON)                         // JSON endpoint
-		r.Post("/{metric_type}/{name}/{value}", mh.Update) // Plaintext endpoint
+	// Agent and API routes
+	r.Group(func(r chi.Router) {
+		r.Use(agentMiddlewares...)
+
+		r.Mount("/debug", pprofRouter())
+		r.Get("/", mh.GetMetrics)
+		r.Get("/metrics", mh.PrometheusMetrics)
+		r.Get("/ping", mh.PingDBHandler)
+		r.With(writeMiddlewares...).Post("/updates/", mh.UpdateAll)
+		r.Get("/history/{metric_type}/{name}", mh.GetHistory)
+		r.Get("/api/v1/metrics", mh.QueryMetrics)
+		if cfg.Alerts != nil {
+			r.Get("/alerts", NewAlertsHandler(cfg.Alerts).GetAlerts)
+		}
+
+		// Metric value routes
+		r.Route("/value/", func(r chi.Router) {
+			r.Post("/", mh.GetMetricValueJSON)                // JSON endpoint
+			r.Get("/{metric_type}/{name}", mh.GetMetricValue) // Plaintext endpoint
+		})
+
+		// Metric update routes
+		r.Route("/update/", func(r chi.Router) {
+			r.Use(writeMiddlewares...)
+			r.Post("/", mh.UpdateJSON)                         // JSON endpoint
+			r.Post("/{metric_type}/{name}/{value}", mh.Update) // Plaintext endpoint
+		})
 	})
 
-	logger.Log.Sugar().Infoln("Server starting on", address)
-	return http.ListenAndServe(address, r)
+	return r, nil
 }
 
 func pprofRouter() http.Handler {
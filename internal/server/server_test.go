@@ -0,0 +1,165 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/runtime-metrics-course/internal/middleware"
+	"github.com/runtime-metrics-course/internal/remotewrite"
+	"github.com/runtime-metrics-course/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writePrivateKey stores a new RSA private key as PEM and returns its path
+func writePrivateKey(t *testing.T) string {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	keyPath := filepath.Join(t.TempDir(), "private.pem")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
+	require.NoError(t, os.WriteFile(keyPath, keyPEM, 0o600))
+	return keyPath
+}
+
+func TestRouter_IngestionWithCryptoKey(t *testing.T) {
+	remoteWrite := remotewrite.Encode(&remotewrite.WriteRequest{Timeseries: []remotewrite.TimeSeries{{
+		Labels:  []remotewrite.Label{{Name: "__name__", Value: "up"}},
+		Samples: []remotewrite.Sample{{Value: 1, Timestamp: 1000}},
+	}}})
+	r, err := newRouter(Config{CryptoKeyPath: writePrivateKey(t), SecretKey: "secret"}, storage.NewMemStorage())
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		url          string
+		contentType  string
+		encoding     string
+		body         string
+		expectedCode int
+	}{
+		{
+			name:         "OTLP export",
+			url:          "/v1/metrics",
+			contentType:  otlpJSON,
+			body:         `{"resourceMetrics":[{"scopeMetrics":[{"metrics":[{"name":"temperature","gauge":{"dataPoints":[{"asDouble":21.5}]}}]}]}]}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "InfluxDB write",
+			url:          "/write",
+			contentType:  "text/plain",
+			body:         "cpu,host=web-1 usage=0.5",
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:         "Prometheus remote write",
+			url:          "/api/v1/write",
+			contentType:  "application/x-protobuf",
+			encoding:     "snappy",
+			body:         string(remoteWrite),
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:         "Unencrypted agent update",
+			url:          "/update/",
+			contentType:  "application/json",
+			body:         `{"id":"Alloc","type":"gauge","value":1}`,
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.url, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.encoding != "" {
+				req.Header.Set("Content-Encoding", tt.encoding)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestRouter_TrustedSubnet(t *testing.T) {
+	st := storage.NewMemStorage()
+	require.NoError(t, st.UpdateGauge(context.Background(), "Alloc", 1))
+	r, err := newRouter(Config{TrustedSubnet: "192.168.1.0/24"}, st)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		url          string
+		realIP       string
+		body         string
+		expectedCode int
+	}{
+		{
+			name:         "JSON value read from untrusted address",
+			url:          "/value/",
+			realIP:       "10.0.0.1",
+			body:         `{"id":"Alloc","type":"gauge"}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Update from untrusted address",
+			url:          "/update/",
+			realIP:       "10.0.0.1",
+			body:         `{"id":"Alloc","type":"gauge","value":2}`,
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "Plaintext update without address",
+			url:          "/update/gauge/Alloc/2",
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "Batch update from untrusted address",
+			url:          "/updates/",
+			realIP:       "10.0.0.1",
+			body:         `[{"id":"Alloc","type":"gauge","value":2}]`,
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "InfluxDB write from untrusted address",
+			url:          "/write",
+			realIP:       "10.0.0.1",
+			body:         "cpu usage=0.5",
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "Update from trusted address",
+			url:          "/update/",
+			realIP:       "192.168.1.10",
+			body:         `{"id":"Alloc","type":"gauge","value":2}`,
+			expectedCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.url, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.realIP != "" {
+				req.Header.Set(middleware.RealIPHeader, tt.realIP)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code, w.Body.String())
+		})
+	}
+}
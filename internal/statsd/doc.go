@@ -0,0 +1,22 @@
+// Package statsd receives StatsD and DogStatsD metrics over UDP and writes
+// them into the metrics storage.
+//
+// Every packet holds one or more newline separated lines of the form
+//
+//	name:value|type[|@sample_rate][|#tag:value,tag2]
+//
+// Supported types map to the storage metric types:
+//   - c - counters; the values received between flushes are summed
+//   - g - gauges; "+" and "-" prefixed values change the last value, which is
+//     forgotten if the gauge is not set for 60 flushes
+//   - ms, h, d - timers, histograms and distributions become summaries
+//   - s - sets; the number of unique values between flushes becomes a gauge
+//
+// Sample rates scale counters and summary totals. DogStatsD tags become
+// labels; tags without a value get an empty label value. Other DogStatsD
+// sections, events and service checks are ignored.
+//
+// The Server aggregates received lines and writes them with
+// StorageIface.UpdateAll every flush interval, so the storage sees one batch
+// per interval instead of one update per packet.
+package statsd
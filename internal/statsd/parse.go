@@ -0,0 +1,110 @@
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/runtime-metrics-course/internal/models"
+)
+
+// StatsD metric types
+const (
+	TypeCounter      = "c"  // Counter increment
+	TypeGauge        = "g"  // Gauge value
+	TypeTimer        = "ms" // Timing in milliseconds
+	TypeHistogram    = "h"  // DogStatsD histogram
+	TypeDistribution = "d"  // DogStatsD distribution
+	TypeSet          = "s"  // Set member
+)
+
+// Sample is a single parsed StatsD line
+type Sample struct {
+	Labels   models.Labels // DogStatsD tags
+	Name     string        // Metric name
+	Type     string        // StatsD metric type, one of the Type* constants
+	Member   string        // Set member (sets only)
+	Value    float64       // Metric value (all types but sets)
+	Rate     float64       // Sample rate in (0, 1]
+	Relative bool          // Gauge value is a change of the last value
+}
+
+// ErrUnsupported is returned for DogStatsD events and service checks
+var ErrUnsupported = errors.New("unsupported statsd message")
+
+// ParseLine parses a StatsD line, e.g. "api.requests:1|c|@0.5|#route:/users"
+func ParseLine(line string) (Sample, error) {
+	if strings.HasPrefix(line, "_e{") || strings.HasPrefix(line, "_sc|") {
+		return Sample{}, ErrUnsupported
+	}
+
+	sections := strings.Split(line, "|")
+	if len(sections) < 2 {
+		return Sample{}, fmt.Errorf("%q: missing metric type", line)
+	}
+
+	sep := strings.LastIndexByte(sections[0], ':')
+	if sep <= 0 {
+		return Sample{}, fmt.Errorf("%q: expected name:value", line)
+	}
+	s := Sample{
+		Name: sections[0][:sep],
+		Type: sections[1],
+		Rate: 1,
+	}
+	value := sections[0][sep+1:]
+
+	switch s.Type {
+	case TypeSet:
+		if value == "" {
+			return Sample{}, fmt.Errorf("%q: empty set member", line)
+		}
+		s.Member = value
+	case TypeCounter, TypeGauge, TypeTimer, TypeHistogram, TypeDistribution:
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return Sample{}, fmt.Errorf("%q: invalid value %q", line, value)
+		}
+		s.Value = v
+		s.Relative = s.Type == TypeGauge && (value[0] == '+' || value[0] == '-')
+	default:
+		return Sample{}, fmt.Errorf("%q: unknown metric type %q", line, s.Type)
+	}
+
+	for _, section := range sections[2:] {
+		switch {
+		case strings.HasPrefix(section, "@"):
+			rate, err := strconv.ParseFloat(section[1:], 64)
+			if err != nil || !(rate > 0 && rate <= 1) {
+				return Sample{}, fmt.Errorf("%q: invalid sample rate %q", line, section[1:])
+			}
+			s.Rate = rate
+		case strings.HasPrefix(section, "#"):
+			s.Labels = parseTags(section[1:])
+		}
+	}
+
+	if err := models.ValidateLabels(s.Name, s.Labels); err != nil {
+		return Sample{}, err
+	}
+	return s, nil
+}
+
+// parseTags converts DogStatsD tags into labels, e.g. "env:prod,canary"
+// into {env="prod", canary=""}. The last value of a repeated tag wins.
+func parseTags(tags string) models.Labels {
+	labels := make(models.Labels)
+	for _, tag := range strings.Split(tags, ",") {
+		if tag == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(tag, ":")
+		labels[name] = value
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+	return labels
+}
@@ -0,0 +1,95 @@
+package statsd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/runtime-metrics-course/internal/models"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		expected  Sample
+		expectErr bool
+	}{
+		{
+			name:     "Counter",
+			line:     "api.requests:1|c",
+			expected: Sample{Name: "api.requests", Type: TypeCounter, Value: 1, Rate: 1},
+		},
+		{
+			name:     "Sampled counter",
+			line:     "api.requests:2|c|@0.5",
+			expected: Sample{Name: "api.requests", Type: TypeCounter, Value: 2, Rate: 0.5},
+		},
+		{
+			name:     "Gauge",
+			line:     "queue.size:3.2|g",
+			expected: Sample{Name: "queue.size", Type: TypeGauge, Value: 3.2, Rate: 1},
+		},
+		{
+			name:     "Relative gauge",
+			line:     "queue.size:-4|g",
+			expected: Sample{Name: "queue.size", Type: TypeGauge, Value: -4, Rate: 1, Relative: true},
+		},
+		{
+			name:     "Timer",
+			line:     "api.latency:320|ms|@0.1",
+			expected: Sample{Name: "api.latency", Type: TypeTimer, Value: 320, Rate: 0.1},
+		},
+		{
+			name:     "Set",
+			line:     "users.unique:alice|s",
+			expected: Sample{Name: "users.unique", Type: TypeSet, Member: "alice", Rate: 1},
+		},
+		{
+			name: "DogStatsD tags",
+			line: "api.latency:12|h|#env:prod,route:/users,canary|c:abc123",
+			expected: Sample{
+				Name:   "api.latency",
+				Type:   TypeHistogram,
+				Value:  12,
+				Rate:   1,
+				Labels: models.Labels{"env": "prod", "route": "/users", "canary": ""},
+			},
+		},
+		{
+			name:     "Colons in name",
+			line:     "app:web:hits:1|c",
+			expected: Sample{Name: "app:web:hits", Type: TypeCounter, Value: 1, Rate: 1},
+		},
+		{name: "Missing type", line: "api.requests:1", expectErr: true},
+		{name: "Missing value", line: "api.requests|c", expectErr: true},
+		{name: "Empty name", line: ":1|c", expectErr: true},
+		{name: "Invalid value", line: "api.requests:one|c", expectErr: true},
+		{name: "Infinite value", line: "api.requests:Inf|g", expectErr: true},
+		{name: "Unknown type", line: "api.requests:1|x", expectErr: true},
+		{name: "Zero sample rate", line: "api.requests:1|c|@0", expectErr: true},
+		{name: "Sample rate above one", line: "api.requests:1|c|@2", expectErr: true},
+		{name: "Braces in name", line: "api{x}:1|c", expectErr: true},
+		{name: "Invalid tag name", line: "api:1|c|#a=b:c", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sample, err := ParseLine(tt.line)
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, sample)
+		})
+	}
+}
+
+func TestParseLine_Unsupported(t *testing.T) {
+	for _, line := range []string{"_e{5,4}:title|text", "_sc|redis.can_connect|0"} {
+		_, err := ParseLine(line)
+		assert.ErrorIs(t, err, ErrUnsupported, line)
+	}
+}
@@ -0,0 +1,289 @@
+package statsd
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/runtime-metrics-course/internal/logger"
+	"github.com/runtime-metrics-course/internal/models"
+	"github.com/runtime-metrics-course/internal/storage"
+)
+
+// DefaultFlushInterval is how often received metrics are written to the storage
+const DefaultFlushInterval = time.Second
+
+// maxPacketSize is the largest UDP payload read at once
+const maxPacketSize = 65535
+
+// gaugeRetention is the number of flushes without an update after which a
+// gauge value is forgotten, so series of departed clients do not pile up
+const gaugeRetention = 60
+
+// Config contains the StatsD listener settings
+type Config struct {
+	Address       string        // UDP listen address (e.g. ":8125")
+	FlushInterval time.Duration // How often metrics are written to the storage (DefaultFlushInterval if 0)
+}
+
+// summary aggregates timer, histogram and distribution samples of a series
+type summary struct {
+	observations []float64
+	sum          float64
+	count        float64 // Scaled by the sample rates
+}
+
+// gauge is the last value of a gauge, kept for relative changes
+type gauge struct {
+	value float64
+	idle  int // Flushes since the gauge was last set
+}
+
+// series is the name and labels of an aggregated metric
+type series struct {
+	name   string
+	labels models.Labels
+}
+
+// Server receives StatsD packets and writes the aggregated metrics to the
+// storage every flush interval. It is safe for concurrent use.
+type Server struct {
+	storage  storage.StorageIface
+	address  string
+	interval time.Duration
+
+	mu        sync.Mutex
+	series    map[string]series              // Name and labels by series key
+	counters  map[string]float64             // Counter increments since the last flush
+	gauges    map[string]*gauge              // Last gauge values, forgotten after gaugeRetention idle flushes
+	summaries map[string]*summary            // Summary samples since the last flush
+	sets      map[string]map[string]struct{} // Set members since the last flush
+}
+
+// NewServer creates a StatsD server writing to storage
+func NewServer(cfg Config, storage storage.StorageIface) *Server {
+	interval := cfg.FlushInterval
+	if interval <= 0 {
+		interval = DefaultFlushInterval
+	}
+	return &Server{
+		storage:   storage,
+		address:   cfg.Address,
+		interval:  interval,
+		series:    make(map[string]series),
+		counters:  make(map[string]float64),
+		gauges:    make(map[string]*gauge),
+		summaries: make(map[string]*summary),
+		sets:      make(map[string]map[string]struct{}),
+	}
+}
+
+// ListenAndServe listens on the configured UDP address and serves packets
+// until the context is canceled
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	conn, err := net.ListenPacket("udp", s.address)
+	if err != nil {
+		return err
+	}
+	logger.Log.Sugar().Infoln("StatsD listener starting on", conn.LocalAddr())
+	return s.Serve(ctx, conn)
+}
+
+// Serve reads packets from conn and flushes the received metrics every flush
+// interval until the context is canceled. The metrics received last are
+// flushed before returning; conn is closed.
+func (s *Server) Serve(ctx context.Context, conn net.PacketConn) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.flushLoop(ctx)
+	}()
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			cancel()
+			wg.Wait()
+			if ctx.Err() != nil && errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		s.HandlePacket(buf[:n])
+	}
+}
+
+// flushLoop flushes every interval and once more when the context is canceled
+func (s *Server) flushLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := s.Flush(context.WithoutCancel(ctx)); err != nil {
+				logger.Log.Sugar().Errorf("statsd flush failed: %v", err)
+			}
+			return
+		case <-ticker.C:
+			if err := s.Flush(ctx); err != nil {
+				logger.Log.Sugar().Errorf("statsd flush failed: %v", err)
+			}
+		}
+	}
+}
+
+// HandlePacket parses the lines of a packet and aggregates them until the
+// next flush. Lines that cannot be parsed are skipped.
+func (s *Server) HandlePacket(packet []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, line := range strings.Split(string(packet), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		sample, err := ParseLine(line)
+		if err != nil {
+			if !errors.Is(err, ErrUnsupported) {
+				logger.Log.Sugar().Debugf("statsd line skipped: %v", err)
+			}
+			continue
+		}
+		s.add(sample)
+	}
+}
+
+// add aggregates a sample
+func (s *Server) add(sample Sample) {
+	key := models.SeriesKey(sample.Name, sample.Labels)
+	s.series[key] = series{name: sample.Name, labels: sample.Labels}
+
+	switch sample.Type {
+	case TypeCounter:
+		s.counters[key] += sample.Value / sample.Rate
+	case TypeGauge:
+		g, ok := s.gauges[key]
+		if !ok {
+			g = &gauge{}
+			s.gauges[key] = g
+		}
+		if sample.Relative {
+			g.value += sample.Value
+		} else {
+			g.value = sample.Value
+		}
+		g.idle = 0
+	case TypeTimer, TypeHistogram, TypeDistribution:
+		sum, ok := s.summaries[key]
+		if !ok {
+			sum = &summary{}
+			s.summaries[key] = sum
+		}
+		sum.observations = append(sum.observations, sample.Value)
+		sum.sum += sample.Value / sample.Rate
+		sum.count += 1 / sample.Rate
+	case TypeSet:
+		members, ok := s.sets[key]
+		if !ok {
+			members = make(map[string]struct{})
+			s.sets[key] = members
+		}
+		members[sample.Member] = struct{}{}
+	}
+}
+
+// Flush writes the metrics aggregated since the previous flush to the
+// storage. Nothing is written if no metrics were received. The metrics are
+// dropped if the storage update fails, as StatsD clients do not resend.
+func (s *Server) Flush(ctx context.Context) error {
+	metrics := s.drain()
+	if len(metrics) == 0 {
+		return nil
+	}
+	return s.storage.UpdateAll(ctx, metrics)
+}
+
+// drain returns the aggregated metrics sorted by type and series key and
+// resets the aggregation. Gauge values are kept for relative changes until
+// they were not set for gaugeRetention flushes; a relative change of a
+// forgotten gauge starts from zero.
+func (s *Server) drain() []models.MetricJSON {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var metrics []models.MetricJSON
+	for _, key := range sortedKeys(s.counters) {
+		delta := int64(math.Round(s.counters[key]))
+		metrics = append(metrics, s.metric(key, models.MetricJSON{MType: models.Counter, Delta: &delta}))
+	}
+	for _, key := range sortedKeys(s.gauges) {
+		if g := s.gauges[key]; g.idle == 0 {
+			value := g.value
+			metrics = append(metrics, s.metric(key, models.MetricJSON{MType: models.Gauge, Value: &value}))
+		}
+	}
+	for _, key := range sortedKeys(s.sets) {
+		value := float64(len(s.sets[key]))
+		metrics = append(metrics, s.metric(key, models.MetricJSON{MType: models.Gauge, Value: &value}))
+	}
+	for _, key := range sortedKeys(s.summaries) {
+		sum := s.summaries[key]
+		count := uint64(math.Round(sum.count))
+		metrics = append(metrics, s.metric(key, models.MetricJSON{
+			MType:        models.Summary,
+			Observations: sum.observations,
+			Sum:          &sum.sum,
+			Count:        &count,
+		}))
+	}
+
+	for key, g := range s.gauges {
+		if g.idle++; g.idle >= gaugeRetention {
+			delete(s.gauges, key)
+		}
+	}
+	// Series without a gauge value are not needed for the next flush
+	for key := range s.series {
+		if _, ok := s.gauges[key]; !ok {
+			delete(s.series, key)
+		}
+	}
+	clear(s.counters)
+	clear(s.summaries)
+	clear(s.sets)
+	return metrics
+}
+
+// metric sets the name and labels of a series on an aggregated metric
+func (s *Server) metric(key string, m models.MetricJSON) models.MetricJSON {
+	m.ID = s.series[key].name
+	m.Labels = s.series[key].labels
+	return m
+}
+
+// sortedKeys returns the keys of a map in ascending order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,102 @@
+package statsd
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/runtime-metrics-course/internal/models"
+	"github.com/runtime-metrics-course/internal/storage"
+)
+
+func TestServer_Flush(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMemStorage()
+	s := NewServer(Config{}, st)
+
+	s.HandlePacket([]byte("hits:1|c\nhits:2|c|@0.5\nhits:1|c|#env:prod\n" +
+		"queue:10|g\nqueue:-3|g\n" +
+		"latency:10|ms\nlatency:30|ms|@0.5\n" +
+		"users:alice|s\nusers:bob|s\nusers:alice|s\n" +
+		"garbage\n_e{1,1}:a|b\n"))
+	require.NoError(t, s.Flush(ctx))
+
+	metrics, err := st.GetMetrics(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), metrics.Counters["hits"])
+	assert.Equal(t, int64(1), metrics.Counters[`hits{env="prod"}`])
+	assert.Equal(t, 7.0, metrics.Gauges["queue"])
+	assert.Equal(t, 2.0, metrics.Gauges["users"])
+
+	latency := metrics.Summaries["latency"]
+	assert.Equal(t, uint64(3), latency.Count)
+	assert.Equal(t, 70.0, latency.Sum)
+	assert.Len(t, latency.Window, 2)
+
+	// Counters start over, relative gauge changes apply to the last value
+	s.HandlePacket([]byte("hits:1|c\nqueue:+1|g"))
+	require.NoError(t, s.Flush(ctx))
+	metrics, err = st.GetMetrics(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), metrics.Counters["hits"])
+	assert.Equal(t, 8.0, metrics.Gauges["queue"])
+}
+
+func TestServer_GaugeRetention(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMemStorage()
+	s := NewServer(Config{}, st)
+
+	s.HandlePacket([]byte("queue:10|g\nworkers:4|g"))
+	require.NoError(t, s.Flush(ctx))
+	for i := 1; i < gaugeRetention; i++ {
+		s.HandlePacket([]byte("workers:+1|g"))
+		require.NoError(t, s.Flush(ctx))
+	}
+
+	// The idle gauge and its series are forgotten, the updated one is kept
+	assert.NotContains(t, s.gauges, "queue")
+	assert.NotContains(t, s.series, "queue")
+	assert.Equal(t, float64(4+gaugeRetention-1), s.gauges["workers"].value)
+
+	s.HandlePacket([]byte("queue:+1|g"))
+	require.NoError(t, s.Flush(ctx))
+	metrics, err := st.GetMetrics(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, metrics.Gauges["queue"])
+}
+
+func TestServer_FlushEmpty(t *testing.T) {
+	s := NewServer(Config{}, nil)
+	assert.NoError(t, s.Flush(context.Background()), "nothing received, storage not touched")
+}
+
+func TestServer_Serve(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	st := storage.NewMemStorage()
+	s := NewServer(Config{FlushInterval: 10 * time.Millisecond}, st)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx, conn) }()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer client.Close()
+	_, err = client.Write([]byte("requests:3|c|#service:api"))
+	require.NoError(t, err)
+
+	key := models.SeriesKey("requests", models.Labels{"service": "api"})
+	assert.Eventually(t, func() bool {
+		metrics, err := st.GetMetrics(context.Background())
+		return err == nil && metrics.Counters[key] == 3
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-done)
+}
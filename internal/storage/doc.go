@@ -20,6 +20,14 @@
 //   - StorageManager: Unified access point to storage
 //   - StorageWorker: Periodic persistence for file storage
 //
+// History:
+//   - Optional recording of every update with its timestamp (Cfg.History)
+//   - Time range queries via StorageIface.GetHistory
+//
+// Queries:
+//   - Point lookups of a single series via StorageIface.GetMetric
+//   - Filtering, sorting and pagination via StorageIface.Query
+//
 // Configuration:
 //   - Cfg: Storage initialization settings
 //
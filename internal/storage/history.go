@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/runtime-metrics-course/internal/models"
+)
+
+// ErrHistoryDisabled is returned by GetHistory when the storage does not record history
+var ErrHistoryDisabled = errors.New("metrics history is disabled")
+
+// history keeps every recorded value of gauge and counter series in memory.
+// It is not safe for concurrent use; callers must hold their own lock.
+type history struct {
+	gauges    map[string][]models.Point // Gauge points by series key
+	counters  map[string][]models.Point // Counter points by series key
+	retention time.Duration             // How long points are kept (0 = forever)
+}
+
+// newHistory creates an empty history with the given retention period
+func newHistory(retention time.Duration) *history {
+	return &history{
+		gauges:    make(map[string][]models.Point),
+		counters:  make(map[string][]models.Point),
+		retention: retention,
+	}
+}
+
+// addGauge records a gauge value at the given time
+func (h *history) addGauge(key string, value float64, at time.Time) {
+	h.gauges[key] = h.trim(append(h.gauges[key], models.Point{Value: &value, Time: at}), at)
+}
+
+// addCounter records the counter total at the given time
+func (h *history) addCounter(key string, total int64, at time.Time) {
+	h.counters[key] = h.trim(append(h.counters[key], models.Point{Delta: &total, Time: at}), at)
+}
+
+// trim drops points that are older than the retention period
+func (h *history) trim(points []models.Point, now time.Time) []models.Point {
+	if h.retention <= 0 {
+		return points
+	}
+	cutoff := now.Add(-h.retention)
+	i := sort.Search(len(points), func(i int) bool {
+		return !points[i].Time.Before(cutoff)
+	})
+	if i == 0 {
+		return points
+	}
+	return append(points[:0:0], points[i:]...)
+}
+
+// query returns a copy of the points of a series recorded within [from, to]
+func (h *history) query(mType, key string, from, to time.Time) []models.Point {
+	var points []models.Point
+	switch mType {
+	case models.Gauge:
+		points = h.gauges[key]
+	case models.Counter:
+		points = h.counters[key]
+	}
+
+	start := sort.Search(len(points), func(i int) bool {
+		return !points[i].Time.Before(from)
+	})
+	end := sort.Search(len(points), func(i int) bool {
+		return points[i].Time.After(to)
+	})
+	if start >= end {
+		return []models.Point{}
+	}
+
+	result := make([]models.Point, end-start)
+	copy(result, points[start:end])
+	return result
+}
@@ -19,6 +19,8 @@ type Cfg struct {
 	FilePath string        // File path for persistence (for memory storage)
 	Restore  bool          // Whether to restore from file on startup
 
+	History          bool          // Whether every update is recorded with its timestamp
+	HistoryRetention time.Duration // How long recorded history is kept (0 = forever)
 }
 
 // StorageManager manages the application's storage backend.
@@ -50,15 +52,25 @@ func GetStorageManager() *StorageManager {
 // Storage selection logic:
 //   - Uses PostgreSQL if connection is provided in config
 //   - Falls back to in-memory storage otherwise
+//
+// History recording is enabled on the selected storage if cfg.History is set.
 func NewStorageManager(cfg *Cfg) (*StorageManager, error) {
 	var err error
 
 	switch {
 	case cfg != nil && cfg.Conn != nil:
-		currentSM.storage = NewPgxStorage(cfg.Conn)
+		pgxStorage := NewPgxStorage(cfg.Conn)
+		if cfg.History {
+			pgxStorage.EnableHistory(cfg.HistoryRetention)
+		}
+		currentSM.storage = pgxStorage
 		currentSM.storageType = PostgresDB
 	default:
-		currentSM.storage = NewMemStorage()
+		memStorage := NewMemStorage()
+		if cfg != nil && cfg.History {
+			memStorage.EnableHistory(cfg.HistoryRetention)
+		}
+		currentSM.storage = memStorage
 		currentSM.storageType = RuntimeMemory
 	}
 
@@ -81,6 +93,13 @@ func (m *StorageManager) GetStorage() (StorageIface, error) {
 	return m.storage, nil
 }
 
+// Wrap replaces the current storage with a decorator of it, e.g. one that
+// forwards accepted updates. Must be called before the storage is handed out
+// with GetStorage; the file saver keeps using the undecorated storage.
+func (m *StorageManager) Wrap(wrap func(StorageIface) StorageIface) {
+	m.storage = wrap(m.storage)
+}
+
 // GetStorageType returns the type of currently active storage.
 // Returns one of the storage type constants (RuntimeMemory or PostgresDB).
 func (m *StorageManager) GetStorageType() string {
@@ -5,16 +5,20 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/runtime-metrics-course/internal/models"
 )
 
 // MemStorage implements StorageIface using in-memory storage with mutex protection.
-// It provides thread-safe storage for gauge and counter metrics.
+// It provides thread-safe storage for gauge, counter, histogram and summary metrics.
 type MemStorage struct {
-	mu       sync.Mutex      // Mutex to protect concurrent access
-	gauges   models.Gauges   // Map for storing gauge metrics
-	counters models.Counters // Map for storing counter metrics
+	mu         sync.Mutex        // Mutex to protect concurrent access
+	gauges     models.Gauges     // Map for storing gauge metrics
+	counters   models.Counters   // Map for storing counter metrics
+	histograms models.Histograms // Map for storing histogram metrics
+	summaries  models.Summaries  // Map for storing summary metrics
+	history    *history          // Recorded values, nil when history is disabled
 }
 
 // NewMemStorage creates a new initialized MemStorage instance.
@@ -22,17 +26,28 @@ type MemStorage struct {
 //   - *MemStorage: ready-to-use in-memory storage
 func NewMemStorage() *MemStorage {
 	return &MemStorage{
-		gauges:   make(models.Gauges),
-		counters: make(models.Counters),
+		gauges:     make(models.Gauges),
+		counters:   make(models.Counters),
+		histograms: make(models.Histograms),
+		summaries:  make(models.Summaries),
 	}
 }
 
+// EnableHistory turns on recording of every update with its timestamp.
+// Points older than retention are dropped; zero retention keeps all points.
+// Must be called before the storage is used concurrently.
+func (m *MemStorage) EnableHistory(retention time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.history = newHistory(retention)
+}
+
 // UpdateGauge stores or updates a gauge metric value.
 // Implements StorageIface.UpdateGauge.
 func (m *MemStorage) UpdateGauge(ctx context.Context, name string, value float64) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.gauges[name] = value
+	m.setGauge(name, value, time.Now())
 	return nil
 }
 
@@ -41,10 +56,42 @@ func (m *MemStorage) UpdateGauge(ctx context.Context, name string, value float64
 func (m *MemStorage) UpdateCounter(ctx context.Context, name string, value int64) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.counters[name] += value
+	m.addCounter(name, value, time.Now())
 	return nil
 }
 
+// setGauge stores a gauge value and records it in history. Caller must hold m.mu.
+func (m *MemStorage) setGauge(key string, value float64, at time.Time) {
+	m.gauges[key] = value
+	if m.history != nil {
+		m.history.addGauge(key, value, at)
+	}
+}
+
+// addCounter increments a counter and records the new total in history.
+// Caller must hold m.mu.
+func (m *MemStorage) addCounter(key string, delta int64, at time.Time) {
+	m.counters[key] += delta
+	if m.history != nil {
+		m.history.addCounter(key, m.counters[key], at)
+	}
+}
+
+// GetHistory returns the values of a series recorded within [from, to].
+// Implements StorageIface.GetHistory.
+// Returns:
+//   - []models.Point: points ordered by time
+//   - error: ErrHistoryDisabled if history recording is not enabled
+func (m *MemStorage) GetHistory(ctx context.Context, mType, name string, from, to time.Time) ([]models.Point, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.history == nil {
+		return nil, ErrHistoryDisabled
+	}
+	return m.history.query(mType, name, from, to), nil
+}
+
 // GetMetrics returns a snapshot of all stored metrics.
 // Implements StorageIface.GetMetrics.
 // Returns:
@@ -65,12 +112,64 @@ func (m *MemStorage) GetMetrics(ctx context.Context) (models.Metrics, error) {
 		copyCounters[k] = v
 	}
 
+	copyHistograms := make(models.Histograms, len(m.histograms))
+	for k, v := range m.histograms {
+		copyHistograms[k] = v.Copy()
+	}
+
+	copySummaries := make(models.Summaries, len(m.summaries))
+	for k, v := range m.summaries {
+		copySummaries[k] = v.Copy()
+	}
+
 	return models.Metrics{
-		Gauges:   copyGauges,
-		Counters: copyCounters,
+		Gauges:     copyGauges,
+		Counters:   copyCounters,
+		Histograms: copyHistograms,
+		Summaries:  copySummaries,
 	}, nil
 }
 
+// GetMetric returns a copy of a single series.
+// Implements StorageIface.GetMetric.
+// Returns:
+//   - models.MetricJSON: the series value in JSON form
+//   - error: ErrMetricNotFound if the series is not stored
+func (m *MemStorage) GetMetric(ctx context.Context, mType, name string) (models.MetricJSON, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	metric, err := m.metric(mType, name)
+	if err != nil {
+		return models.MetricJSON{}, err
+	}
+	return *metric, nil
+}
+
+// metric converts a stored series into JSON form. Caller must hold m.mu.
+func (m *MemStorage) metric(mType, key string) (*models.MetricJSON, error) {
+	var (
+		val any
+		ok  bool
+	)
+	switch mType {
+	case models.Gauge:
+		val, ok = m.gauges[key]
+	case models.Counter:
+		val, ok = m.counters[key]
+	case models.Histogram:
+		if h, found := m.histograms[key]; found {
+			val, ok = h.Copy(), true
+		}
+	case models.Summary:
+		val, ok = m.summaries[key]
+	}
+	if !ok {
+		return nil, ErrMetricNotFound
+	}
+	return models.MarshalMetricToJSON(mType, key, val)
+}
+
 // Ping always returns nil as in-memory storage is always available.
 // Implements StorageIface.Ping.
 func (m *MemStorage) Ping(ctx context.Context) error {
@@ -79,23 +178,89 @@ func (m *MemStorage) Ping(ctx context.Context) error {
 
 // UpdateAll performs batch updates of multiple metrics atomically.
 // Implements StorageIface.UpdateAll.
+// Histogram updates add bucket counts to the stored histogram, summary
+// updates add observations to the sliding window of the stored summary.
+// The whole batch is validated first, so a failed batch changes nothing.
 // Returns:
-//   - error: joined error for all failed updates or nil if all succeeded
+//   - error: wrapping ErrInvalidMetric and the errors of all invalid metrics,
+//     or nil if all updates were applied
 func (m *MemStorage) UpdateAll(ctx context.Context, metrics []models.MetricJSON) error {
-	var errs []error
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	var errs []error
+	histograms := make(models.Histograms)
 	for _, metric := range metrics {
+		if err := models.ValidateLabels(metric.ID, metric.Labels); err != nil {
+			errs = append(errs, err)
+			continue
+		}
 		switch {
-		case metric.IsCounter() && metric.Delta != nil:
-			m.counters[metric.ID] += *metric.Delta
-		case metric.IsGauge() && metric.Value != nil:
-			m.gauges[metric.ID] = *metric.Value
+		case metric.IsCounter() && metric.Delta != nil,
+			metric.IsGauge() && metric.Value != nil,
+			metric.IsSummary() && (metric.Observations != nil || metric.Count != nil):
+		case metric.IsHistogram() && metric.Counts != nil:
+			h, ok := histograms[metric.Key()]
+			if !ok {
+				h = m.histograms[metric.Key()].Copy()
+			}
+			if err := h.Merge(metric.Buckets, metric.Counts, metric.HistogramSum()); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", metric.ID, err))
+				continue
+			}
+			histograms[metric.Key()] = h
 		default:
 			errs = append(errs, fmt.Errorf("%s: invalid metric type or value", metric.ID))
 		}
 	}
+	if len(errs) != 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidMetric, errors.Join(errs...))
+	}
+
+	now := time.Now()
+	for _, metric := range metrics {
+		switch {
+		case metric.IsCounter():
+			m.addCounter(metric.Key(), *metric.Delta, now)
+		case metric.IsGauge():
+			m.setGauge(metric.Key(), *metric.Value, now)
+		case metric.IsSummary():
+			s := m.summaries[metric.Key()]
+			sum, count := metric.SummaryTotals()
+			s.Observe(metric.Observations, sum, count, now)
+			m.summaries[metric.Key()] = s
+		}
+	}
+	for key, h := range histograms {
+		m.histograms[key] = h
+	}
+	return nil
+}
 
-	return errors.Join(errs...)
+// histogram returns a copy of the stored state of a histogram series
+func (m *MemStorage) histogram(key string) models.HistogramValue {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.histograms[key].Copy()
+}
+
+// summary returns a copy of the stored state of a summary series
+func (m *MemStorage) summary(key string) models.SummaryValue {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.summaries[key].Copy()
+}
+
+// setHistogram replaces the stored state of a histogram series
+func (m *MemStorage) setHistogram(key string, h models.HistogramValue) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.histograms[key] = h.Copy()
+}
+
+// setSummary replaces the stored state of a summary series
+func (m *MemStorage) setSummary(key string, s models.SummaryValue) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.summaries[key] = s.Copy()
 }
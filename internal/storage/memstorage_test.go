@@ -2,7 +2,9 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/runtime-metrics-course/internal/models"
 )
@@ -165,6 +167,161 @@ func TestGetMetrics(t *testing.T) {
 	}
 }
 
+func TestUpdateAllLabels(t *testing.T) {
+	storage := NewMemStorage()
+	ctx := context.Background()
+
+	web1, web2 := 10.0, 20.0
+	delta := int64(3)
+	err := storage.UpdateAll(ctx, []models.MetricJSON{
+		{ID: "load", MType: models.Gauge, Value: &web1, Labels: models.Labels{"host": "web-1"}},
+		{ID: "load", MType: models.Gauge, Value: &web2, Labels: models.Labels{"host": "web-2"}},
+		{ID: "requests", MType: models.Counter, Delta: &delta, Labels: models.Labels{"host": "web-1"}},
+		{ID: "requests", MType: models.Counter, Delta: &delta, Labels: models.Labels{"host": "web-1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metrics, _ := storage.GetMetrics(ctx)
+	if got := metrics.Gauges[`load{host="web-1"}`]; got != web1 {
+		t.Errorf("Expected web-1 load %v, got %v", web1, got)
+	}
+	if got := metrics.Gauges[`load{host="web-2"}`]; got != web2 {
+		t.Errorf("Expected web-2 load %v, got %v", web2, got)
+	}
+	if got := metrics.Counters[`requests{host="web-1"}`]; got != 2*delta {
+		t.Errorf("Expected requests counter %v, got %v", 2*delta, got)
+	}
+
+	err = storage.UpdateAll(ctx, []models.MetricJSON{
+		{ID: "load", MType: models.Gauge, Value: &web1, Labels: models.Labels{"a=b": "c"}},
+	})
+	if err == nil {
+		t.Error("Expected error for invalid label name")
+	}
+}
+
+func TestUpdateAllDistributions(t *testing.T) {
+	storage := NewMemStorage()
+	ctx := context.Background()
+
+	sum := 3.5
+	err := storage.UpdateAll(ctx, []models.MetricJSON{
+		{ID: "latency", MType: models.Histogram, Buckets: []float64{1, 2}, Counts: []uint64{1, 1, 0}, Sum: &sum},
+		{ID: "latency", MType: models.Histogram, Buckets: []float64{1, 2}, Counts: []uint64{0, 0, 2}, Sum: &sum},
+		{ID: "size", MType: models.Summary, Observations: []float64{1, 2, 3}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metrics, _ := storage.GetMetrics(ctx)
+	h := metrics.Histograms["latency"]
+	if h.Count != 4 || h.Sum != 7 || h.Counts[2] != 2 {
+		t.Errorf("Unexpected histogram %+v", h)
+	}
+	s := metrics.Summaries["size"]
+	if s.Count != 3 || s.Sum != 6 || s.Quantile(0.5) != 2 {
+		t.Errorf("Unexpected summary %+v", s)
+	}
+
+	err = storage.UpdateAll(ctx, []models.MetricJSON{
+		{ID: "latency", MType: models.Histogram, Buckets: []float64{5}, Counts: []uint64{1, 0}},
+	})
+	if err == nil {
+		t.Error("Expected error for mismatched buckets")
+	}
+}
+
+func TestUpdateAllAtomic(t *testing.T) {
+	storage := NewMemStorage()
+	ctx := context.Background()
+
+	delta, value := int64(5), 1.5
+	err := storage.UpdateAll(ctx, []models.MetricJSON{
+		{ID: "latency", MType: models.Histogram, Buckets: []float64{1}, Counts: []uint64{1, 0}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = storage.UpdateAll(ctx, []models.MetricJSON{
+		{ID: "requests", MType: models.Counter, Delta: &delta},
+		{ID: "load", MType: models.Gauge, Value: &value},
+		{ID: "latency", MType: models.Histogram, Buckets: []float64{1}, Counts: []uint64{1, 0}},
+		{ID: "latency", MType: models.Histogram, Buckets: []float64{2}, Counts: []uint64{1, 0}},
+	})
+	if !errors.Is(err, ErrInvalidMetric) {
+		t.Fatalf("Expected ErrInvalidMetric, got %v", err)
+	}
+
+	metrics, _ := storage.GetMetrics(ctx)
+	if len(metrics.Counters) != 0 || len(metrics.Gauges) != 0 {
+		t.Errorf("Expected no updates from a failed batch, got %+v", metrics)
+	}
+	if h := metrics.Histograms["latency"]; h.Count != 1 {
+		t.Errorf("Expected histogram unchanged by a failed batch, got %+v", h)
+	}
+}
+
+func TestGetHistory(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("History disabled", func(t *testing.T) {
+		storage := NewMemStorage()
+		_, err := storage.GetHistory(ctx, models.Gauge, "temperature", time.Time{}, time.Now())
+		if !errors.Is(err, ErrHistoryDisabled) {
+			t.Errorf("Expected ErrHistoryDisabled, got %v", err)
+		}
+	})
+
+	t.Run("Records every update", func(t *testing.T) {
+		storage := NewMemStorage()
+		storage.EnableHistory(0)
+		from := time.Now()
+
+		storage.UpdateGauge(ctx, "temperature", 23.5)
+		storage.UpdateGauge(ctx, "temperature", 25.0)
+		storage.UpdateCounter(ctx, "requests", 10)
+		delta := int64(5)
+		storage.UpdateAll(ctx, []models.MetricJSON{{ID: "requests", MType: models.Counter, Delta: &delta}})
+
+		gauges, err := storage.GetHistory(ctx, models.Gauge, "temperature", from, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(gauges) != 2 || *gauges[0].Value != 23.5 || *gauges[1].Value != 25.0 {
+			t.Errorf("Unexpected gauge history %+v", gauges)
+		}
+
+		counters, err := storage.GetHistory(ctx, models.Counter, "requests", from, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(counters) != 2 || *counters[0].Delta != 10 || *counters[1].Delta != 15 {
+			t.Errorf("Unexpected counter history %+v", counters)
+		}
+
+		empty, _ := storage.GetHistory(ctx, models.Gauge, "temperature", time.Now().Add(time.Hour), time.Now().Add(2*time.Hour))
+		if len(empty) != 0 {
+			t.Errorf("Expected no points outside range, got %+v", empty)
+		}
+	})
+
+	t.Run("Retention drops old points", func(t *testing.T) {
+		h := newHistory(time.Minute)
+		now := time.Now()
+		h.addGauge("temperature", 1, now.Add(-2*time.Minute))
+		h.addGauge("temperature", 2, now)
+
+		points := h.query(models.Gauge, "temperature", now.Add(-time.Hour), now)
+		if len(points) != 1 || *points[0].Value != 2 {
+			t.Errorf("Expected only the recent point, got %+v", points)
+		}
+	})
+}
+
 func BenchmarkUpdateGauge(b *testing.B) {
 	storage := NewMemStorage()
 	ctx := context.Background()
@@ -3,19 +3,37 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/runtime-metrics-course/internal/logger"
 	"github.com/runtime-metrics-course/internal/models"
 )
 
 // PgxStorage implements StorageIface using PostgreSQL as the backend storage
 // with an in-memory cache for faster read operations.
+// Rows are keyed by series key and type, so the name column holds name+labels.
 type PgxStorage struct {
-	cache *MemStorage // In-memory cache for quick access
-	conn  *sql.DB     // PostgreSQL database connection
+	cache     *MemStorage   // In-memory cache for quick access
+	conn      *sql.DB       // PostgreSQL database connection
+	history   bool          // Whether updates are recorded in metrics_history
+	retention time.Duration // How long history rows are kept (0 = forever)
+	pruneMu   sync.Mutex    // Protects lastPrune
+	lastPrune time.Time     // Last time expired history rows were deleted
+	distMu    sync.Mutex    // Serializes read-modify-write of histograms and summaries
 }
 
+// historyPruneInterval limits how often expired history rows are deleted
+const historyPruneInterval = time.Minute
+
+// insertHistoryQuery copies the current state of a series into metrics_history
+const insertHistoryQuery = `
+		INSERT INTO metrics_history (name, type, value, delta, created_at)
+		SELECT name, type, value, delta, $3 FROM metrics WHERE name = $1 AND type = $2
+		`
+
 // NewPgxStorage creates a new PostgreSQL-backed storage with cache.
 // Parameters:
 //   - conn: Established database connection
@@ -31,6 +49,14 @@ func NewPgxStorage(conn *sql.DB) *PgxStorage {
 	return s
 }
 
+// EnableHistory turns on recording of every update into the metrics_history table.
+// Rows older than retention are deleted periodically; zero retention keeps all rows.
+// Must be called before the storage is used concurrently.
+func (s *PgxStorage) EnableHistory(retention time.Duration) {
+	s.history = true
+	s.retention = retention
+}
+
 // Ping checks the database connectivity.
 // Implements StorageIface.Ping.
 func (s *PgxStorage) Ping(ctx context.Context) error {
@@ -40,17 +66,24 @@ func (s *PgxStorage) Ping(ctx context.Context) error {
 // UpdateGauge stores or updates a gauge metric in both database and cache.
 // Implements StorageIface.UpdateGauge.
 func (s *PgxStorage) UpdateGauge(ctx context.Context, name string, value float64) error {
-	_, err := s.conn.ExecContext(ctx,
-		`
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			`
         INSERT INTO metrics (name, type, value, updated_at)
         VALUES ($1, $2, $3, $4)
-        ON CONFLICT (name)
+        ON CONFLICT (name, type)
         DO UPDATE SET value = $3, updated_at = $4
     `,
-		name, models.Gauge, value, time.Now().Format(time.RFC3339))
+			name, models.Gauge, value, time.Now().Format(time.RFC3339))
+		if err != nil {
+			return fmt.Errorf("failed to update gauge in database: %w", err)
+		}
+		return s.recordHistory(ctx, tx, models.Gauge, name)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to update gauge in database: %w", err)
+		return err
 	}
+	s.pruneHistory(ctx)
 
 	return s.cache.UpdateGauge(ctx, name, value)
 }
@@ -58,46 +91,91 @@ func (s *PgxStorage) UpdateGauge(ctx context.Context, name string, value float64
 // UpdateCounter stores or increments a counter metric in both database and cache.
 // Implements StorageIface.UpdateCounter.
 func (s *PgxStorage) UpdateCounter(ctx context.Context, name string, delta int64) error {
-	_, err := s.conn.ExecContext(ctx,
-		`
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			`
 		INSERT INTO metrics (name, type, delta, updated_at)
 		VALUES ($1, $2, $3, $4)
-		ON CONFLICT (name)
+		ON CONFLICT (name, type)
 		DO UPDATE SET delta = metrics.delta + $3, updated_at = $4
 			`,
-		name, models.Counter, delta, time.Now().Format(time.RFC3339))
+			name, models.Counter, delta, time.Now().Format(time.RFC3339))
+		if err != nil {
+			return fmt.Errorf("failed to update counter in database: %w", err)
+		}
+		return s.recordHistory(ctx, tx, models.Counter, name)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to update counter in database: %w", err)
+		return err
 	}
+	s.pruneHistory(ctx)
 
 	return s.cache.UpdateCounter(ctx, name, delta)
 }
 
+// inTx runs fn in a transaction that is committed if fn succeeds, so a
+// failed update leaves neither the metric nor its history changed
+func (s *PgxStorage) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	// Rollback is a no-op after a successful commit
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
 // GetMetrics retrieves all metrics from the cache.
 // Implements StorageIface.GetMetrics.
 func (s *PgxStorage) GetMetrics(ctx context.Context) (models.Metrics, error) {
 	return s.cache.GetMetrics(ctx)
 }
 
+// GetMetric retrieves a single series from the cache.
+// Implements StorageIface.GetMetric.
+func (s *PgxStorage) GetMetric(ctx context.Context, mType, name string) (models.MetricJSON, error) {
+	return s.cache.GetMetric(ctx, mType, name)
+}
+
+// Query selects a page of series from the cache.
+// Implements StorageIface.Query.
+func (s *PgxStorage) Query(ctx context.Context, query models.MetricQuery) (models.MetricPage, error) {
+	return s.cache.Query(ctx, query)
+}
+
 // UpdateAll performs atomic batch updates of multiple metrics.
 // Implements StorageIface.UpdateAll.
+// Histograms and summaries are merged with the cached state and stored as
+// JSON in the distribution column.
 func (s *PgxStorage) UpdateAll(ctx context.Context, metrics []models.MetricJSON) error {
+	for _, metric := range metrics {
+		if metric.IsHistogram() || metric.IsSummary() {
+			s.distMu.Lock()
+			defer s.distMu.Unlock()
+			break
+		}
+	}
+
 	tx, err := s.conn.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-		}
-	}()
+	// Rollback is a no-op after a successful commit
+	defer tx.Rollback()
 
 	// Prepare statements for batch operations
 	stmtCounter, err := tx.PrepareContext(ctx,
 		`
 		INSERT INTO metrics (name, type, delta, updated_at)
 		VALUES ($1, $2, $3, $4)
-		 ON CONFLICT (name) DO UPDATE SET delta = metrics.delta + $3, updated_at = $4
+		 ON CONFLICT (name, type) DO UPDATE SET delta = metrics.delta + $3, updated_at = $4
 		 `)
 	if err != nil {
 		return fmt.Errorf("failed to prepare counter statement: %w", err)
@@ -108,52 +186,204 @@ func (s *PgxStorage) UpdateAll(ctx context.Context, metrics []models.MetricJSON)
 		`
 		INSERT INTO metrics (name, type, value, updated_at)
 		VALUES ($1, $2, $3, $4)
-		ON CONFLICT (name) DO UPDATE SET value = $3, updated_at = $4
+		ON CONFLICT (name, type) DO UPDATE SET value = $3, updated_at = $4
 		 `)
 	if err != nil {
 		return fmt.Errorf("failed to prepare gauge statement: %w", err)
 	}
 	defer stmtGauge.Close()
 
+	var stmtHistory *sql.Stmt
+	if s.history {
+		stmtHistory, err = tx.PrepareContext(ctx, insertHistoryQuery)
+		if err != nil {
+			return fmt.Errorf("failed to prepare history statement: %w", err)
+		}
+		defer stmtHistory.Close()
+	}
+
 	now := time.Now().Format(time.RFC3339)
+	historyTime := time.Now().UTC()
+	histograms := make(models.Histograms)
+	summaries := make(models.Summaries)
 	for _, metric := range metrics {
+		if err = models.ValidateLabels(metric.ID, metric.Labels); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidMetric, err)
+		}
 		switch {
+		case metric.IsHistogram() && metric.Counts != nil:
+			h, ok := histograms[metric.Key()]
+			if !ok {
+				h = s.cache.histogram(metric.Key())
+			}
+			if err = h.Merge(metric.Buckets, metric.Counts, metric.HistogramSum()); err != nil {
+				return fmt.Errorf("%w: %s: %w", ErrInvalidMetric, metric.ID, err)
+			}
+			histograms[metric.Key()] = h
+			continue
+		case metric.IsSummary() && (metric.Observations != nil || metric.Count != nil):
+			sm, ok := summaries[metric.Key()]
+			if !ok {
+				sm = s.cache.summary(metric.Key())
+			}
+			sum, count := metric.SummaryTotals()
+			sm.Observe(metric.Observations, sum, count, historyTime)
+			summaries[metric.Key()] = sm
+			continue
 		case metric.IsCounter() && metric.Delta != nil:
-			if _, err := stmtCounter.ExecContext(ctx, metric.ID, metric.MType, *metric.Delta, now); err != nil {
+			if _, err := stmtCounter.ExecContext(ctx, metric.Key(), metric.MType, *metric.Delta, now); err != nil {
 				return fmt.Errorf("failed to update counter %s: %w", metric.ID, err)
 			}
 		case metric.IsGauge() && metric.Value != nil:
-			if _, err := stmtGauge.ExecContext(ctx, metric.ID, metric.MType, *metric.Value, now); err != nil {
+			if _, err := stmtGauge.ExecContext(ctx, metric.Key(), metric.MType, *metric.Value, now); err != nil {
 				return fmt.Errorf("failed to update gauge %s: %w", metric.ID, err)
 			}
 		default:
-			return fmt.Errorf("%s: invalid metric type or value", metric.ID)
+			return fmt.Errorf("%w: %s: invalid metric type or value", ErrInvalidMetric, metric.ID)
+		}
+		if stmtHistory != nil {
+			if _, err := stmtHistory.ExecContext(ctx, metric.Key(), metric.MType, historyTime); err != nil {
+				return fmt.Errorf("failed to record history of %s: %w", metric.ID, err)
+			}
+		}
+	}
+
+	if len(histograms) != 0 || len(summaries) != 0 {
+		if err = s.storeDistributions(ctx, tx, histograms, summaries, now); err != nil {
+			return err
 		}
 	}
 
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	s.pruneHistory(ctx)
 
 	return s.cache.UpdateAll(ctx, metrics)
 }
 
+// storeDistributions upserts the JSON encoded state of histograms and summaries
+func (s *PgxStorage) storeDistributions(ctx context.Context, tx *sql.Tx, histograms models.Histograms, summaries models.Summaries, now string) error {
+	stmt, err := tx.PrepareContext(ctx,
+		`
+		INSERT INTO metrics (name, type, distribution, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (name, type) DO UPDATE SET distribution = $3, updated_at = $4
+		`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare distribution statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for key, h := range histograms {
+		data, err := json.Marshal(h)
+		if err != nil {
+			return fmt.Errorf("failed to encode histogram %s: %w", key, err)
+		}
+		if _, err := stmt.ExecContext(ctx, key, models.Histogram, data, now); err != nil {
+			return fmt.Errorf("failed to update histogram %s: %w", key, err)
+		}
+	}
+	for key, sm := range summaries {
+		data, err := json.Marshal(sm)
+		if err != nil {
+			return fmt.Errorf("failed to encode summary %s: %w", key, err)
+		}
+		if _, err := stmt.ExecContext(ctx, key, models.Summary, data, now); err != nil {
+			return fmt.Errorf("failed to update summary %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// GetHistory returns the values of a series recorded within [from, to].
+// Implements StorageIface.GetHistory.
+func (s *PgxStorage) GetHistory(ctx context.Context, mType, name string, from, to time.Time) ([]models.Point, error) {
+	if !s.history {
+		return nil, ErrHistoryDisabled
+	}
+
+	rows, err := s.conn.QueryContext(ctx,
+		`
+		SELECT value, delta, created_at FROM metrics_history
+		WHERE name = $1 AND type = $2 AND created_at BETWEEN $3 AND $4
+		ORDER BY created_at, id
+		`,
+		name, mType, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history: %w", err)
+	}
+	defer rows.Close()
+
+	points := make([]models.Point, 0)
+	for rows.Next() {
+		var p models.Point
+		if err := rows.Scan(&p.Value, &p.Delta, &p.Time); err != nil {
+			return nil, fmt.Errorf("failed to scan history row: %w", err)
+		}
+		points = append(points, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+	return points, nil
+}
+
+// recordHistory stores the current state of a series in metrics_history
+// if history is enabled.
+func (s *PgxStorage) recordHistory(ctx context.Context, tx *sql.Tx, mType, name string) error {
+	if !s.history {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, insertHistoryQuery, name, mType, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to record history: %w", err)
+	}
+	return nil
+}
+
+// pruneHistory deletes history rows older than the retention period.
+// Runs at most once per historyPruneInterval; failures are logged only.
+func (s *PgxStorage) pruneHistory(ctx context.Context) {
+	if !s.history || s.retention <= 0 {
+		return
+	}
+
+	s.pruneMu.Lock()
+	if time.Since(s.lastPrune) < historyPruneInterval {
+		s.pruneMu.Unlock()
+		return
+	}
+	s.lastPrune = time.Now()
+	s.pruneMu.Unlock()
+
+	cutoff := time.Now().Add(-s.retention).UTC()
+	if _, err := s.conn.ExecContext(ctx, "DELETE FROM metrics_history WHERE created_at < $1", cutoff); err != nil {
+		logger.Log.Sugar().Errorf("failed to prune metrics history: %v", err)
+	}
+}
+
 // InitCache loads all metrics from the database into memory cache.
 // Called automatically during initialization.
 func (s *PgxStorage) InitCache(ctx context.Context) error {
-	rows, err := s.conn.QueryContext(ctx, "SELECT name, type, value, delta from metrics")
+	rows, err := s.conn.QueryContext(ctx, "SELECT name, type, value, delta, distribution from metrics")
 	if err != nil {
 		return fmt.Errorf("failed to query metrics: %w", err)
 	}
 	defer rows.Close()
 
-	var metrics []models.MetricJSON
+	// distribution holds the JSON encoded state of a histogram or summary row
+	type row struct {
+		metric       models.MetricJSON
+		distribution []byte
+	}
+	var loaded []row
 	for rows.Next() {
-		var m models.MetricJSON
-		if err := rows.Scan(&m.ID, &m.MType, &m.Value, &m.Delta); err != nil {
+		var r row
+		if err := rows.Scan(&r.metric.ID, &r.metric.MType, &r.metric.Value, &r.metric.Delta, &r.distribution); err != nil {
 			return fmt.Errorf("failed to scan metric row: %w", err)
 		}
-		metrics = append(metrics, m)
+		loaded = append(loaded, r)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -161,7 +391,8 @@ func (s *PgxStorage) InitCache(ctx context.Context) error {
 	}
 
 	// Update cache with loaded metrics
-	for _, metric := range metrics {
+	for _, r := range loaded {
+		metric := r.metric
 		switch {
 		case metric.IsCounter():
 			if err := s.cache.UpdateCounter(ctx, metric.ID, *metric.Delta); err != nil {
@@ -171,6 +402,18 @@ func (s *PgxStorage) InitCache(ctx context.Context) error {
 			if err := s.cache.UpdateGauge(ctx, metric.ID, *metric.Value); err != nil {
 				return fmt.Errorf("failed to update gauge in cache: %w", err)
 			}
+		case metric.IsHistogram() && r.distribution != nil:
+			var h models.HistogramValue
+			if err := json.Unmarshal(r.distribution, &h); err != nil {
+				return fmt.Errorf("failed to decode histogram %s: %w", metric.ID, err)
+			}
+			s.cache.setHistogram(metric.ID, h)
+		case metric.IsSummary() && r.distribution != nil:
+			var sm models.SummaryValue
+			if err := json.Unmarshal(r.distribution, &sm); err != nil {
+				return fmt.Errorf("failed to decode summary %s: %w", metric.ID, err)
+			}
+			s.cache.setSummary(metric.ID, sm)
 		}
 	}
 	return nil
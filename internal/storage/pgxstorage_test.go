@@ -27,9 +27,11 @@ func TestUpdateGaugeDB(t *testing.T) {
 	value := 42.5
 	now := time.Now().Format(time.RFC3339)
 
+	mock.ExpectBegin()
 	mock.ExpectExec("INSERT INTO metrics").
 		WithArgs(name, "gauge", value, now).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
 	err = mockStorage.UpdateGauge(context.Background(), name, value)
 	assert.NoError(t, err)
@@ -50,9 +52,11 @@ func TestUpdateCounterDB(t *testing.T) {
 	delta := int64(5)
 	now := time.Now().Format(time.RFC3339)
 
+	mock.ExpectBegin()
 	mock.ExpectExec("INSERT INTO metrics").
 		WithArgs(name, "counter", delta, now).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
 	err = mockStorage.UpdateCounter(context.Background(), name, delta)
 	assert.NoError(t, err)
@@ -71,9 +75,11 @@ func TestUpdateGauge_Error(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
 
+	mock.ExpectBegin()
 	mock.ExpectExec("INSERT INTO metrics").
 		WithArgs(name, models.Gauge, value, sqlmock.AnyArg()).
 		WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
 
 	err = mockStorage.UpdateGauge(ctx, name, value)
 
@@ -93,9 +99,11 @@ func TestUpdateCounter_Error(t *testing.T) {
 	name := "requests_total"
 	delta := int64(5)
 
+	mock.ExpectBegin()
 	mock.ExpectExec("INSERT INTO metrics").
 		WithArgs(name, models.Counter, delta, sqlmock.AnyArg()).
 		WillReturnError(errors.New("failed to execute query"))
+	mock.ExpectRollback()
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
@@ -118,11 +126,14 @@ func TestPgxStorage_InitCache(t *testing.T) {
 
 	ctx := context.Background()
 
-	rows := sqlmock.NewRows([]string{"name", "type", "value", "delta"}).
-		AddRow("metric1", "gauge", 123.45, nil).
-		AddRow("metric2", "counter", nil, 10)
+	rows := sqlmock.NewRows([]string{"name", "type", "value", "delta", "distribution"}).
+		AddRow("metric1", "gauge", 123.45, nil, nil).
+		AddRow("metric2", "counter", nil, 10, nil).
+		AddRow("metric3", "histogram", nil, nil, []byte(`{"bounds":[1],"counts":[2,3],"sum":7.5,"count":5}`)).
+		// A series key shared by metrics of different types
+		AddRow("metric1", "histogram", nil, nil, []byte(`{"bounds":[1],"counts":[1,0],"sum":0.5,"count":1}`))
 
-	mock.ExpectQuery("SELECT name, type, value, delta from metrics").
+	mock.ExpectQuery("SELECT name, type, value, delta, distribution from metrics").
 		WillReturnRows(rows)
 
 	err = s.InitCache(ctx)
@@ -136,6 +147,15 @@ func TestPgxStorage_InitCache(t *testing.T) {
 	assert.True(t, exists)
 	assert.Equal(t, int64(10), counterValue)
 
+	histogramValue, exists := s.cache.histograms["metric3"]
+	assert.True(t, exists)
+	assert.Equal(t, []uint64{2, 3}, histogramValue.Counts)
+	assert.Equal(t, uint64(5), histogramValue.Count)
+
+	histogramValue, exists = s.cache.histograms["metric1"]
+	assert.True(t, exists)
+	assert.Equal(t, uint64(1), histogramValue.Count)
+
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -259,6 +279,59 @@ func TestPgxStorage_UpdateAll(t *testing.T) {
 
 }
 
+func TestPgxStorage_History(t *testing.T) {
+	t.Run("update records history", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		s := &PgxStorage{conn: db, cache: NewMemStorage()}
+		s.EnableHistory(0)
+
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO metrics ").
+			WithArgs("cpu_usage", models.Gauge, 42.5, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT INTO metrics_history").
+			WithArgs("cpu_usage", models.Gauge, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		assert.NoError(t, s.UpdateGauge(context.Background(), "cpu_usage", 42.5))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("range query", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+
+		s := &PgxStorage{conn: db, cache: NewMemStorage()}
+		s.EnableHistory(0)
+
+		from := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+		to := from.Add(time.Hour)
+		rows := sqlmock.NewRows([]string{"value", "delta", "created_at"}).
+			AddRow(nil, 10, from.Add(time.Minute)).
+			AddRow(nil, 15, from.Add(2*time.Minute))
+		mock.ExpectQuery("SELECT value, delta, created_at FROM metrics_history").
+			WithArgs("requests", models.Counter, from, to).
+			WillReturnRows(rows)
+
+		points, err := s.GetHistory(context.Background(), models.Counter, "requests", from, to)
+		require.NoError(t, err)
+		require.Len(t, points, 2)
+		assert.Equal(t, int64(15), *points[1].Delta)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("history disabled", func(t *testing.T) {
+		s := &PgxStorage{cache: NewMemStorage()}
+		_, err := s.GetHistory(context.Background(), models.Gauge, "cpu_usage", time.Time{}, time.Now())
+		assert.ErrorIs(t, err, ErrHistoryDisabled)
+	})
+}
+
 func int64Ptr(i int64) *int64       { return &i }
 func float64Ptr(f float64) *float64 { return &f }
 
@@ -276,9 +349,11 @@ func BenchmarkPgxStorage_UpdateGauge(b *testing.B) {
 
 	// Настраиваем мок для каждого вызова
 	for i := 0; i < b.N; i++ {
+		mock.ExpectBegin()
 		mock.ExpectExec("INSERT INTO metrics").
 			WithArgs(name, models.Gauge, value, sqlmock.AnyArg()).
 			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
 	}
 
 	b.ResetTimer()
@@ -307,9 +382,11 @@ func BenchmarkPgxStorage_UpdateCounter(b *testing.B) {
 
 	// Настраиваем мок для каждого вызова
 	for i := 0; i < b.N; i++ {
+		mock.ExpectBegin()
 		mock.ExpectExec("INSERT INTO metrics").
 			WithArgs(name, models.Counter, delta, sqlmock.AnyArg()).
 			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
 	}
 
 	b.ResetTimer()
@@ -0,0 +1,256 @@
+package storage
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/runtime-metrics-course/internal/models"
+)
+
+// ErrInvalidQuery is returned by Query when the query cannot be executed
+var ErrInvalidQuery = errors.New("invalid query")
+
+// querySeries is a series selected by a query, with the value it is sorted by
+type querySeries struct {
+	mType string
+	key   string
+	value float64
+}
+
+// queryCursor is the position of the last series of a page, encoded in MetricPage.Next
+type queryCursor struct {
+	Sort  string `json:"s"`           // Sort order of the query
+	Type  string `json:"t"`           // Metric type of the series
+	Key   string `json:"k"`           // Series key
+	Value string `json:"v,omitempty"` // Sort value, set when sorting by value
+}
+
+// compiledQuery holds the parsed filters and order of a models.MetricQuery
+type compiledQuery struct {
+	query models.MetricQuery
+	match *regexp.Regexp // Compiled Match glob, nil matches all
+	regex *regexp.Regexp // Compiled Regex, nil matches all
+	sort  string         // Sort order with the default applied
+	field string         // Sort field without the "-" prefix
+	desc  bool           // Whether the order is reversed
+	after *querySeries   // Position of the cursor, nil if not set
+}
+
+// compileQuery validates a query and prepares its filters
+func compileQuery(query models.MetricQuery) (*compiledQuery, error) {
+	c := &compiledQuery{query: query, sort: query.Sort}
+	if c.sort == "" {
+		c.sort = models.SortByName
+	}
+	c.field, c.desc = strings.CutPrefix(c.sort, "-")
+
+	switch c.field {
+	case models.SortByName, models.SortByType, models.SortByValue:
+	default:
+		return nil, fmt.Errorf("%w: unknown sort order %q", ErrInvalidQuery, query.Sort)
+	}
+	switch query.Type {
+	case "", models.Gauge, models.Counter, models.Histogram, models.Summary:
+	default:
+		return nil, fmt.Errorf("%w: unknown metric type %q", ErrInvalidQuery, query.Type)
+	}
+	if query.Limit < 0 || query.Offset < 0 {
+		return nil, fmt.Errorf("%w: limit and offset must not be negative", ErrInvalidQuery)
+	}
+
+	if query.Match != "" {
+		c.match = globRegexp(query.Match)
+	}
+	if query.Regex != "" {
+		regex, err := regexp.Compile(query.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidQuery, err)
+		}
+		c.regex = regex
+	}
+	if query.Cursor != "" {
+		after, err := c.decodeCursor(query.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+		}
+		c.after = after
+	}
+	return c, nil
+}
+
+// globRegexp converts a glob with * and ? wildcards into an anchored regexp
+func globRegexp(glob string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// matches reports whether a series passes the type, name and label filters
+func (c *compiledQuery) matches(mType, key string) bool {
+	if c.query.Type != "" && mType != c.query.Type {
+		return false
+	}
+	if c.match == nil && c.regex == nil && len(c.query.Labels) == 0 {
+		return true
+	}
+
+	name, labels := models.ParseSeriesKey(key)
+	if c.match != nil && !c.match.MatchString(name) {
+		return false
+	}
+	if c.regex != nil && !c.regex.MatchString(name) {
+		return false
+	}
+	for k, v := range c.query.Labels {
+		if value, ok := labels[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+// compare orders two series; the order is total, so cursors are unambiguous
+func (c *compiledQuery) compare(a, b querySeries) int {
+	var r int
+	switch c.field {
+	case models.SortByType:
+		r = cmp.Or(cmp.Compare(a.mType, b.mType), cmp.Compare(a.key, b.key))
+	case models.SortByValue:
+		r = cmp.Or(cmp.Compare(a.value, b.value), cmp.Compare(a.key, b.key), cmp.Compare(a.mType, b.mType))
+	default:
+		r = cmp.Or(cmp.Compare(a.key, b.key), cmp.Compare(a.mType, b.mType))
+	}
+	if c.desc {
+		return -r
+	}
+	return r
+}
+
+// page sorts the matching series and cuts out the requested page.
+// Returns the page, the number of matching series and the next cursor.
+func (c *compiledQuery) page(series []querySeries) ([]querySeries, int, string) {
+	slices.SortFunc(series, c.compare)
+	total := len(series)
+
+	if c.after != nil {
+		i, found := slices.BinarySearchFunc(series, *c.after, c.compare)
+		if found {
+			i++
+		}
+		series = series[i:]
+	}
+	series = series[min(c.query.Offset, len(series)):]
+
+	var next string
+	if c.query.Limit > 0 && len(series) > c.query.Limit {
+		series = series[:c.query.Limit]
+		next = c.encodeCursor(series[len(series)-1])
+	}
+	return series, total, next
+}
+
+// encodeCursor returns an opaque cursor pointing after the series
+func (c *compiledQuery) encodeCursor(s querySeries) string {
+	cursor := queryCursor{Sort: c.sort, Type: s.mType, Key: s.key}
+	if c.field == models.SortByValue {
+		cursor.Value = strconv.FormatFloat(s.value, 'g', -1, 64)
+	}
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a cursor returned by a query with the same sort order
+func (c *compiledQuery) decodeCursor(s string) (*querySeries, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var cursor queryCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	if cursor.Sort != c.sort {
+		return nil, errors.New("cursor of another sort order")
+	}
+
+	after := &querySeries{mType: cursor.Type, key: cursor.Key}
+	if c.field == models.SortByValue {
+		if after.value, err = strconv.ParseFloat(cursor.Value, 64); err != nil {
+			return nil, err
+		}
+	}
+	return after, nil
+}
+
+// Query returns the page of stored series selected by the query.
+// Implements StorageIface.Query.
+// Counters are sorted by their total, histograms and summaries by the sum
+// of observations.
+// Returns:
+//   - models.MetricPage: copies of the selected series
+//   - error: wrapping ErrInvalidQuery if the query is malformed
+func (m *MemStorage) Query(ctx context.Context, query models.MetricQuery) (models.MetricPage, error) {
+	c, err := compileQuery(query)
+	if err != nil {
+		return models.MetricPage{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var series []querySeries
+	for key, v := range m.gauges {
+		if c.matches(models.Gauge, key) {
+			series = append(series, querySeries{mType: models.Gauge, key: key, value: v})
+		}
+	}
+	for key, v := range m.counters {
+		if c.matches(models.Counter, key) {
+			series = append(series, querySeries{mType: models.Counter, key: key, value: float64(v)})
+		}
+	}
+	for key, v := range m.histograms {
+		if c.matches(models.Histogram, key) {
+			series = append(series, querySeries{mType: models.Histogram, key: key, value: v.Sum})
+		}
+	}
+	for key, v := range m.summaries {
+		if c.matches(models.Summary, key) {
+			series = append(series, querySeries{mType: models.Summary, key: key, value: v.Sum})
+		}
+	}
+
+	selected, total, next := c.page(series)
+	page := models.MetricPage{
+		Metrics: make([]models.MetricJSON, 0, len(selected)),
+		Next:    next,
+		Total:   total,
+	}
+	for _, s := range selected {
+		metric, err := m.metric(s.mType, s.key)
+		if err != nil {
+			return models.MetricPage{}, err
+		}
+		page.Metrics = append(page.Metrics, *metric)
+	}
+	return page, nil
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/runtime-metrics-course/internal/models"
+)
+
+// queryStorage returns a storage with series of every metric type
+func queryStorage(t *testing.T) *MemStorage {
+	t.Helper()
+	storage := NewMemStorage()
+	heapAlloc, heapSys, load1, load2 := 30.0, 50.0, 1.5, 2.5
+	polls, sum := int64(40), 3.5
+	err := storage.UpdateAll(context.Background(), []models.MetricJSON{
+		{ID: "HeapAlloc", MType: models.Gauge, Value: &heapAlloc},
+		{ID: "HeapSys", MType: models.Gauge, Value: &heapSys},
+		{ID: "load", MType: models.Gauge, Value: &load1, Labels: models.Labels{"host": "web-1"}},
+		{ID: "load", MType: models.Gauge, Value: &load2, Labels: models.Labels{"host": "web-2"}},
+		{ID: "PollCount", MType: models.Counter, Delta: &polls},
+		{ID: "latency", MType: models.Histogram, Buckets: []float64{1}, Counts: []uint64{1, 1}, Sum: &sum},
+		{ID: "size", MType: models.Summary, Observations: []float64{1, 2}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return storage
+}
+
+func TestGetMetric(t *testing.T) {
+	storage := queryStorage(t)
+	ctx := context.Background()
+
+	metric, err := storage.GetMetric(ctx, models.Gauge, `load{host="web-2"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metric.ID != "load" || metric.Labels["host"] != "web-2" || *metric.Value != 2.5 {
+		t.Errorf("Unexpected gauge %+v", metric)
+	}
+
+	metric, err = storage.GetMetric(ctx, models.Histogram, "latency")
+	if err != nil {
+		t.Fatal(err)
+	}
+	metric.Counts[0] = 100
+	if metrics, _ := storage.GetMetrics(ctx); metrics.Histograms["latency"].Counts[0] != 1 {
+		t.Error("Returned histogram shares memory with the storage")
+	}
+
+	if _, err := storage.GetMetric(ctx, models.Counter, "HeapAlloc"); !errors.Is(err, ErrMetricNotFound) {
+		t.Errorf("Expected ErrMetricNotFound, got %v", err)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query models.MetricQuery
+		keys  []string
+		total int
+	}{
+		{
+			name:  "All series ordered by name",
+			query: models.MetricQuery{},
+			keys:  []string{"HeapAlloc", "HeapSys", "PollCount", "latency", `load{host="web-1"}`, `load{host="web-2"}`, "size"},
+			total: 7,
+		},
+		{
+			name:  "Glob and type",
+			query: models.MetricQuery{Match: "Heap*", Type: models.Gauge},
+			keys:  []string{"HeapAlloc", "HeapSys"},
+			total: 2,
+		},
+		{
+			name:  "Single character wildcard",
+			query: models.MetricQuery{Match: "Heap???"},
+			keys:  []string{"HeapSys"},
+			total: 1,
+		},
+		{
+			name:  "Regex",
+			query: models.MetricQuery{Regex: "^(Poll|si)"},
+			keys:  []string{"PollCount", "size"},
+			total: 2,
+		},
+		{
+			name:  "Labels",
+			query: models.MetricQuery{Labels: models.Labels{"host": "web-2"}},
+			keys:  []string{`load{host="web-2"}`},
+			total: 1,
+		},
+		{
+			name:  "Descending value with limit and offset",
+			query: models.MetricQuery{Sort: "-value", Limit: 2, Offset: 1},
+			keys:  []string{"PollCount", "HeapAlloc"},
+			total: 7,
+		},
+		{
+			name:  "Type order",
+			query: models.MetricQuery{Sort: models.SortByType, Match: "*e*"},
+			keys:  []string{"HeapAlloc", "HeapSys", "latency", "size"},
+			total: 4,
+		},
+	}
+
+	storage := queryStorage(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := storage.Query(context.Background(), tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			keys := make([]string, len(page.Metrics))
+			for i, m := range page.Metrics {
+				keys[i] = m.Key()
+			}
+			if !reflect.DeepEqual(keys, tt.keys) {
+				t.Errorf("Expected series %v, got %v", tt.keys, keys)
+			}
+			if page.Total != tt.total {
+				t.Errorf("Expected total %d, got %d", tt.total, page.Total)
+			}
+		})
+	}
+}
+
+func TestQueryCursor(t *testing.T) {
+	storage := queryStorage(t)
+	ctx := context.Background()
+
+	for _, sort := range []string{models.SortByName, "-" + models.SortByValue} {
+		all, err := storage.Query(ctx, models.MetricQuery{Sort: sort})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var paged []models.MetricJSON
+		query := models.MetricQuery{Sort: sort, Limit: 3}
+		for {
+			page, err := storage.Query(ctx, query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			paged = append(paged, page.Metrics...)
+			if page.Next == "" {
+				break
+			}
+			query.Cursor = page.Next
+		}
+		if !reflect.DeepEqual(paged, all.Metrics) {
+			t.Errorf("Sort %s: pages differ from the full result", sort)
+		}
+	}
+
+	page, _ := storage.Query(ctx, models.MetricQuery{Limit: 1})
+	_, err := storage.Query(ctx, models.MetricQuery{Sort: models.SortByValue, Cursor: page.Next})
+	if !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("Expected ErrInvalidQuery for cursor of another sort order, got %v", err)
+	}
+}
+
+func TestQueryInvalid(t *testing.T) {
+	storage := NewMemStorage()
+	for _, query := range []models.MetricQuery{
+		{Sort: "size"},
+		{Type: "meter"},
+		{Regex: "("},
+		{Limit: -1},
+		{Cursor: "not a cursor"},
+	} {
+		if _, err := storage.Query(context.Background(), query); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("Query %+v: expected ErrInvalidQuery, got %v", query, err)
+		}
+	}
+}
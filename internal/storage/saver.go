@@ -62,12 +62,17 @@ func (sw *StorageWorker) LoadFromFile() error {
 	for _, metric := range data {
 		switch {
 		case metric.IsCounter():
-			err := sw.storage.UpdateCounter(context.Background(), metric.ID, *metric.Delta)
+			err := sw.storage.UpdateCounter(context.Background(), metric.Key(), *metric.Delta)
 			if err != nil {
 				return err
 			}
 		case metric.IsGauge():
-			err := sw.storage.UpdateGauge(context.Background(), metric.ID, *metric.Value)
+			err := sw.storage.UpdateGauge(context.Background(), metric.Key(), *metric.Value)
+			if err != nil {
+				return err
+			}
+		case metric.IsHistogram(), metric.IsSummary():
+			err := sw.storage.UpdateAll(context.Background(), []models.MetricJSON{*metric})
 			if err != nil {
 				return err
 			}
@@ -111,6 +116,25 @@ func (sw *StorageWorker) SaveToFile() error {
 		data = append(data, jm)
 	}
 
+	// Serialize histogram metrics
+	for name, val := range metrics.Histograms {
+		jm, err := models.MarshalMetricToJSON(models.Histogram, name, val)
+		if err != nil {
+			continue
+		}
+		data = append(data, jm)
+	}
+
+	// Serialize summary metrics, the sliding window is saved as observations
+	for name, val := range metrics.Summaries {
+		jm, err := models.MarshalMetricToJSON(models.Summary, name, val)
+		if err != nil {
+			continue
+		}
+		jm.Quantiles = nil
+		data = append(data, jm)
+	}
+
 	encoder := json.NewEncoder(file)
 	return encoder.Encode(data)
 }
@@ -2,22 +2,34 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/runtime-metrics-course/internal/models"
 )
 
+// ErrMetricNotFound is returned by GetMetric when the series is not stored
+var ErrMetricNotFound = errors.New("metric not found")
+
+// ErrInvalidMetric is wrapped by the UpdateAll errors of batches with invalid
+// metrics (labels, value or histogram bucket layout)
+var ErrInvalidMetric = errors.New("invalid metric")
+
 // StorageIface defines the interface for metrics storage operations.
 //
 // Implementations should provide thread-safe access to the underlying storage
 // and handle all data persistence operations. The interface supports:
 //   - Basic metric updates (gauges and counters)
+//   - Labeled series, keyed by name+labels (see models.SeriesKey)
 //   - Bulk updates
-//   - Metrics retrieval
+//   - Metrics retrieval, point lookups and filtered, paginated queries
+//   - Time range queries over recorded history
 //   - Storage health checks
 //
 //go:generate go run github.com/vektra/mockery/v2@v2.20.2 --name=StorageIface --output=../mocks --outpkg=mocks --filename=storage_mock.go
 type StorageIface interface {
 	// UpdateGauge stores a gauge metric with the given name and value.
+	// The name is a series key, so labeled series are stored independently.
 	UpdateGauge(ctx context.Context, name string, value float64) error
 
 	// UpdateCounter stores a counter metric with the given name and value.
@@ -28,8 +40,22 @@ type StorageIface interface {
 	// Returns Metrics struct containing all gauges and counters,
 	GetMetrics(ctx context.Context) (models.Metrics, error)
 
+	// GetMetric retrieves a single series by metric type and series key.
+	// Returns ErrMetricNotFound if the series is not stored.
+	GetMetric(ctx context.Context, mType, name string) (models.MetricJSON, error)
+
+	// Query returns the page of series selected by the query (see models.MetricQuery).
+	// Returns an error wrapping ErrInvalidQuery if the query is malformed.
+	Query(ctx context.Context, query models.MetricQuery) (models.MetricPage, error)
+
+	// GetHistory returns the values of a series recorded within [from, to],
+	// ordered by time. Counter points hold the counter total after each update.
+	// Returns ErrHistoryDisabled if the storage does not record history.
+	GetHistory(ctx context.Context, mType, name string, from, to time.Time) ([]models.Point, error)
+
 	// UpdateAll performs a batch update of multiple metrics.
 	// Should be atomic - either all updates succeed or none are applied.
+	// Returns an error wrapping ErrInvalidMetric if the batch has invalid metrics.
 	UpdateAll(ctx context.Context, metrics []models.MetricJSON) error
 
 	// Ping checks the storage connectivity.
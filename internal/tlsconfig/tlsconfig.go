@@ -0,0 +1,107 @@
+// Package tlsconfig builds TLS configurations for the server and the agent
+// from certificate files, including mutual TLS with client certificates.
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Config contains paths to PEM encoded TLS material
+type Config struct {
+	CertFile          string // Certificate presented to the peer
+	KeyFile           string // Private key of the certificate
+	CAFile            string // CA bundle used to verify the peer (system roots if empty, client side)
+	RequireClientCert bool   // Server only: reject clients without a certificate signed by CAFile
+}
+
+// Enabled reports whether any TLS setting is configured
+func (c Config) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != "" || c.CAFile != "" || c.RequireClientCert
+}
+
+// Server returns the TLS configuration of a server.
+// CertFile and KeyFile are required. With CAFile set, client certificates
+// signed by it are verified if presented, or always required with RequireClientCert.
+func Server(c Config) (*tls.Config, error) {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, errors.New("TLS certificate and key are required")
+	}
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	tlsCfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if c.CAFile != "" {
+		pool, err := loadCertPool(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	if c.RequireClientCert {
+		if tlsCfg.ClientCAs == nil {
+			return nil, errors.New("a CA bundle is required to verify client certificates")
+		}
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsCfg, nil
+}
+
+// Client returns the TLS configuration of a client. The server certificate is
+// verified against CAFile (system roots if empty); CertFile and KeyFile, if
+// set, are presented as the client certificate.
+func Client(c Config) (*tls.Config, error) {
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if c.CAFile != "" {
+		pool, err := loadCertPool(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	return tlsCfg, nil
+}
+
+// Identity returns the name of a peer certificate: its subject common name,
+// or the first DNS name if the common name is empty
+func Identity(cert *x509.Certificate) string {
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName
+	}
+	if len(cert.DNSNames) != 0 {
+		return cert.DNSNames[0]
+	}
+	return ""
+}
+
+// loadCertPool reads a PEM CA bundle
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,165 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPKI holds the paths of a CA and of a server and a client certificate signed by it
+type testPKI struct {
+	caFile, serverCert, serverKey, clientCert, clientKey string
+}
+
+// newTestPKI writes a CA, a server certificate for 127.0.0.1 and a client
+// certificate with common name "agent-1" into a temporary directory
+func newTestPKI(t *testing.T) testPKI {
+	t.Helper()
+	dir := t.TempDir()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	pki := testPKI{caFile: filepath.Join(dir, "ca.pem")}
+	writePEM(t, pki.caFile, "CERTIFICATE", caDER)
+
+	issue := func(name string, serial int64, usage x509.ExtKeyUsage, ips []net.IP) (string, string) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			IPAddresses:  ips,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+		require.NoError(t, err)
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		require.NoError(t, err)
+
+		certFile := filepath.Join(dir, name+".pem")
+		keyFile := filepath.Join(dir, name+"-key.pem")
+		writePEM(t, certFile, "CERTIFICATE", der)
+		writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+		return certFile, keyFile
+	}
+
+	pki.serverCert, pki.serverKey = issue("server", 2, x509.ExtKeyUsageServerAuth, []net.IP{net.ParseIP("127.0.0.1")})
+	pki.clientCert, pki.clientKey = issue("agent-1", 3, x509.ExtKeyUsageClientAuth, nil)
+	return pki
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+}
+
+func TestServer(t *testing.T) {
+	pki := newTestPKI(t)
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "Server certificate only", cfg: Config{CertFile: pki.serverCert, KeyFile: pki.serverKey}},
+		{name: "Optional client certificates", cfg: Config{CertFile: pki.serverCert, KeyFile: pki.serverKey, CAFile: pki.caFile}},
+		{name: "Required client certificates", cfg: Config{CertFile: pki.serverCert, KeyFile: pki.serverKey, CAFile: pki.caFile, RequireClientCert: true}},
+		{name: "Missing key", cfg: Config{CertFile: pki.serverCert}, wantErr: true},
+		{name: "Require without CA", cfg: Config{CertFile: pki.serverCert, KeyFile: pki.serverKey, RequireClientCert: true}, wantErr: true},
+		{name: "Invalid CA bundle", cfg: Config{CertFile: pki.serverCert, KeyFile: pki.serverKey, CAFile: pki.serverKey}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Server(tt.cfg)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	pki := newTestPKI(t)
+
+	serverCfg, err := Server(Config{CertFile: pki.serverCert, KeyFile: pki.serverKey, CAFile: pki.caFile, RequireClientCert: true})
+	require.NoError(t, err)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, Identity(r.TLS.VerifiedChains[0][0]))
+	}))
+	ts.TLS = serverCfg
+	ts.StartTLS()
+	defer ts.Close()
+
+	tests := []struct {
+		name         string
+		cfg          Config
+		wantIdentity string
+		wantErr      bool
+	}{
+		{name: "Client certificate", cfg: Config{CAFile: pki.caFile, CertFile: pki.clientCert, KeyFile: pki.clientKey}, wantIdentity: "agent-1"},
+		{name: "No client certificate", cfg: Config{CAFile: pki.caFile}, wantErr: true},
+		{name: "Unknown server CA", cfg: Config{CertFile: pki.clientCert, KeyFile: pki.clientKey}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientCfg, err := Client(tt.cfg)
+			require.NoError(t, err)
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientCfg}}
+
+			resp, err := client.Get(ts.URL)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			body := make([]byte, 64)
+			n, _ := resp.Body.Read(body)
+			assert.Equal(t, tt.wantIdentity, string(body[:n]))
+		})
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	assert.Equal(t, "agent-1", Identity(&x509.Certificate{Subject: pkix.Name{CommonName: "agent-1"}, DNSNames: []string{"host"}}))
+	assert.Equal(t, "host", Identity(&x509.Certificate{DNSNames: []string{"host"}}))
+	assert.Empty(t, Identity(&x509.Certificate{}))
+}